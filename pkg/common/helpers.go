@@ -38,6 +38,11 @@ func NewFloat64(val float64) *float64 {
 	return &val
 }
 
+// NewBool returns a pointer to a bool.
+func NewBool(val bool) *bool {
+	return &val
+}
+
 // GetTimestamp returns the current unix timestamp in milliseconds
 func GetTimestamp() int64 {
 	return time.Now().UnixNano() / (int64(time.Millisecond) / int64(time.Nanosecond))
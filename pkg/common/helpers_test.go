@@ -27,6 +27,12 @@ func TestNewString(t *testing.T) {
 	assert.NotNil(NewString("test"))
 }
 
+// TestNewBool asserts that the NewBool function returns a pointer to a valid input bool.
+func TestNewBool(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(true, *NewBool(true))
+}
+
 // TestGetTimestamp asserts that the GetTimestamp function returns a correct length timestamp.
 func TestGetTimestamp(t *testing.T) {
 	assert := assert.New(t)
@@ -0,0 +1,108 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/session/sessioniface"
+)
+
+const (
+	DEFAULT_TTL = 24 * time.Hour
+)
+
+// SessionStoreMemory keeps sessions in memory, so they are lost when the
+// process exits.
+//
+// A session is evicted once it has been inactive for longer than the TTL, so
+// that memory is only held for recently active users. A user whose session
+// was evicted starts again from the first session index, so the TTL should
+// be well above the session timeout. A TTL of 0 never evicts sessions.
+type SessionStoreMemory struct {
+	TTL      time.Duration
+	lock     sync.RWMutex
+	sessions map[string]sessioniface.Session
+	sweptAt  time.Time
+	now      func() time.Time
+}
+
+// Init creates a new, empty SessionStoreMemory.
+func Init(options ...func(*SessionStoreMemory)) *SessionStoreMemory {
+	s := &SessionStoreMemory{sessions: map[string]sessioniface.Session{}, now: time.Now}
+
+	// Set Defaults
+	s.TTL = DEFAULT_TTL
+
+	// Option parameters
+	for _, op := range options {
+		op(s)
+	}
+
+	if s.TTL < 0 {
+		panic("FATAL: TTL cannot be negative.")
+	}
+	s.sweptAt = s.now()
+
+	return s
+}
+
+// --- Option
+
+// OptionTTL sets how long a session is kept after the last activity of its user, 0 to keep it forever.
+func OptionTTL(ttl time.Duration) func(s *SessionStoreMemory) {
+	return func(s *SessionStoreMemory) { s.TTL = ttl }
+}
+
+// GetSession returns the session of the user, or nil if there is none.
+func (s *SessionStoreMemory) GetSession(userId string) (*sessioniface.Session, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	session, ok := s.sessions[userId]
+	if !ok || s.expired(session, s.now()) {
+		return nil, nil
+	}
+	return &session, nil
+}
+
+// SetSession replaces the session of the user, evicting the expired sessions
+// at most once every TTL.
+func (s *SessionStoreMemory) SetSession(session sessioniface.Session) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.sessions[session.UserId] = session
+
+	now := s.now()
+	if s.TTL > 0 && now.Sub(s.sweptAt) >= s.TTL {
+		for userId, session := range s.sessions {
+			if s.expired(session, now) {
+				delete(s.sessions, userId)
+			}
+		}
+		s.sweptAt = now
+	}
+	return nil
+}
+
+// StorageMechanism returns STORAGE_MECHANISM_LOCAL_STORAGE, the closest of the
+// mechanisms defined by the client_session schema.
+func (s *SessionStoreMemory) StorageMechanism() string {
+	return sessioniface.STORAGE_MECHANISM_LOCAL_STORAGE
+}
+
+// expired checks whether the session has been inactive for longer than the TTL.
+func (s *SessionStoreMemory) expired(session sessioniface.Session, now time.Time) bool {
+	return s.TTL > 0 && now.Sub(session.LastActivity) > s.TTL
+}
@@ -0,0 +1,82 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/session/sessioniface"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/session/sessiontest"
+)
+
+// TestSessionStoreMemoryConformance runs the session store conformance suite.
+func TestSessionStoreMemoryConformance(t *testing.T) {
+	sessiontest.Run(t, sessiontest.Factory{
+		New: func(t *testing.T) sessioniface.SessionStore { return Init() },
+	})
+}
+
+// TestSessionStoreMemoryInit asserts behaviour of memory session store functions.
+func TestSessionStoreMemoryInit(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(DEFAULT_TTL, Init().TTL)
+	assert.Equal(time.Hour, Init(OptionTTL(time.Hour)).TTL)
+	assert.Equal(time.Duration(0), Init(OptionTTL(0)).TTL)
+	assert.PanicsWithValue("FATAL: TTL cannot be negative.", func() { Init(OptionTTL(-time.Second)) })
+}
+
+// TestSessionStoreMemoryTTL asserts that inactive sessions expire and are evicted.
+func TestSessionStoreMemoryTTL(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := Init(OptionTTL(time.Hour), func(s *SessionStoreMemory) { s.now = func() time.Time { return now } })
+
+	active := sessiontest.NewSession("user-1", 1)
+	active.LastActivity = now
+	idle := sessiontest.NewSession("user-2", 1)
+	idle.LastActivity = now
+	assert.Nil(store.SetSession(active))
+	assert.Nil(store.SetSession(idle))
+
+	now = now.Add(50 * time.Minute)
+	active.LastActivity = now
+	assert.Nil(store.SetSession(active))
+
+	// Expired sessions are no longer returned, and are evicted once a TTL has passed since the last sweep
+	now = now.Add(20 * time.Minute)
+	session, err := store.GetSession("user-2")
+	assert.Nil(err)
+	assert.Nil(session)
+	assert.Equal(2, len(store.sessions))
+
+	assert.Nil(store.SetSession(sessiontest.NewSession("user-3", 1)))
+	assert.Equal(2, len(store.sessions))
+	_, evicted := store.sessions["user-2"]
+	assert.False(evicted)
+	session, err = store.GetSession("user-1")
+	assert.Nil(err)
+	assert.Equal(active.SessionId, session.SessionId)
+
+	// Without a TTL sessions are kept forever
+	store = Init(OptionTTL(0), func(s *SessionStoreMemory) { s.now = func() time.Time { return now } })
+	assert.Nil(store.SetSession(idle))
+	now = now.Add(365 * 24 * time.Hour)
+	assert.Nil(store.SetSession(active))
+	session, err = store.GetSession("user-2")
+	assert.Nil(err)
+	assert.Equal(idle.SessionId, session.SessionId)
+}
@@ -0,0 +1,62 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package sessioniface
+
+import (
+	"time"
+)
+
+const (
+	DB_TABLE_NAME                 = "sessions"
+	DB_COLUMN_USER_ID             = "user_id"
+	DB_COLUMN_SESSION_ID          = "session_id"
+	DB_COLUMN_SESSION_INDEX       = "session_index"
+	DB_COLUMN_PREVIOUS_SESSION_ID = "previous_session_id"
+	DB_COLUMN_FIRST_EVENT_ID      = "first_event_id"
+	DB_COLUMN_FIRST_EVENT_TIME    = "first_event_time"
+	DB_COLUMN_EVENT_INDEX         = "event_index"
+	DB_COLUMN_LAST_ACTIVITY       = "last_activity"
+
+	// Storage mechanisms as defined by the client_session schema
+	STORAGE_MECHANISM_SQLITE        = "SQLITE"
+	STORAGE_MECHANISM_LOCAL_STORAGE = "LOCAL_STORAGE"
+)
+
+// Session is the current client session of a user.
+type Session struct {
+	UserId            string    // The Subject user ID the session belongs to
+	SessionId         string    // A UUID identifying the session
+	SessionIndex      int       // The number of sessions the user has had, starting at 1
+	PreviousSessionId string    // The SessionId of the previous session, empty for the first
+	FirstEventId      string    // The id of the first event in the session
+	FirstEventTime    time.Time // When the first event in the session was tracked
+	EventIndex        int       // The number of events in the session, starting at 1
+	LastActivity      time.Time // When the last event in the session was tracked
+}
+
+// SessionStore keeps the current session of every user.
+//
+// A SessionStore must be safe for concurrent use, although the Tracker never
+// updates the session of one user concurrently.
+type SessionStore interface {
+	// GetSession returns the session of the user, or nil if there is none.
+	GetSession(userId string) (*Session, error)
+
+	// SetSession replaces the session of the user.
+	SetSession(session Session) error
+
+	// StorageMechanism returns how sessions are stored, as one of the
+	// STORAGE_MECHANISM constants.
+	StorageMechanism() string
+}
@@ -0,0 +1,149 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+// Package sessiontest provides a conformance suite for implementations of sessioniface.SessionStore.
+//
+// Stores call Run from their own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		sessiontest.Run(t, sessiontest.Factory{
+//			New: func(t *testing.T) sessioniface.SessionStore { return mystore.Init() },
+//		})
+//	}
+package sessiontest
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/session/sessioniface"
+)
+
+// Factory creates the stores under test.
+type Factory struct {
+	// New returns a new, empty store. Any clean up should be registered with t.Cleanup.
+	New func(t *testing.T) sessioniface.SessionStore
+
+	// Reopen is set for persistent stores. It closes the store and returns
+	// a new one reading the same underlying data.
+	Reopen func(t *testing.T, store sessioniface.SessionStore) sessioniface.SessionStore
+}
+
+// Run runs every conformance test against stores created by the factory.
+func Run(t *testing.T, f Factory) {
+	t.Run("GetSet", func(t *testing.T) { TestGetSet(t, f) })
+	t.Run("Concurrency", func(t *testing.T) { TestConcurrency(t, f) })
+	t.Run("Reopen", func(t *testing.T) { TestReopen(t, f) })
+}
+
+// TestGetSet asserts that sessions are stored and replaced per user.
+func TestGetSet(t *testing.T, f Factory) {
+	assert := assert.New(t)
+	store := f.New(t)
+
+	session, err := store.GetSession("user-1")
+	assert.Nil(err)
+	assert.Nil(session)
+
+	first := NewSession("user-1", 1)
+	assert.Nil(store.SetSession(first))
+	assert.Nil(store.SetSession(NewSession("user-2", 1)))
+	session, err = store.GetSession("user-1")
+	assert.Nil(err)
+	assertSession(assert, first, session)
+
+	second := NewSession("user-1", 2)
+	second.PreviousSessionId = first.SessionId
+	assert.Nil(store.SetSession(second))
+	session, err = store.GetSession("user-1")
+	assert.Nil(err)
+	assertSession(assert, second, session)
+
+	session, err = store.GetSession("user-2")
+	assert.Nil(err)
+	assert.Equal(1, session.SessionIndex)
+
+	assert.Contains([]string{sessioniface.STORAGE_MECHANISM_SQLITE, sessioniface.STORAGE_MECHANISM_LOCAL_STORAGE}, store.StorageMechanism())
+}
+
+// TestConcurrency asserts that sessions of different users can be set concurrently.
+func TestConcurrency(t *testing.T, f Factory) {
+	assert := assert.New(t)
+	store := f.New(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for index := 1; index <= 10; index++ {
+				assert.Nil(store.SetSession(NewSession("user-"+common.IntToString(i), index)))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < 20; i++ {
+		session, err := store.GetSession("user-" + common.IntToString(i))
+		assert.Nil(err)
+		assert.Equal(10, session.SessionIndex)
+	}
+}
+
+// TestReopen asserts that a persistent store keeps sessions across restarts.
+func TestReopen(t *testing.T, f Factory) {
+	if f.Reopen == nil {
+		t.Skip("store is not persistent")
+	}
+	assert := assert.New(t)
+	store := f.New(t)
+
+	expected := NewSession("user-1", 3)
+	assert.Nil(store.SetSession(expected))
+	store = f.Reopen(t, store)
+
+	session, err := store.GetSession("user-1")
+	assert.Nil(err)
+	assertSession(assert, expected, session)
+}
+
+// NewSession returns a session of the user with every field set.
+func NewSession(userId string, index int) sessioniface.Session {
+	now := time.UnixMilli(common.GetTimestamp())
+	return sessioniface.Session{
+		UserId:            userId,
+		SessionId:         common.GetUUID(),
+		SessionIndex:      index,
+		PreviousSessionId: common.GetUUID(),
+		FirstEventId:      common.GetUUID(),
+		FirstEventTime:    now.Add(-time.Minute),
+		EventIndex:        index * 2,
+		LastActivity:      now,
+	}
+}
+
+// assertSession asserts that the stored session matches the expected one to
+// the millisecond.
+func assertSession(assert *assert.Assertions, expected sessioniface.Session, actual *sessioniface.Session) {
+	if !assert.NotNil(actual) {
+		return
+	}
+	assert.True(expected.FirstEventTime.Equal(actual.FirstEventTime))
+	assert.True(expected.LastActivity.Equal(actual.LastActivity))
+	expected.FirstEventTime, expected.LastActivity = actual.FirstEventTime, actual.LastActivity
+	assert.Equal(expected, *actual)
+}
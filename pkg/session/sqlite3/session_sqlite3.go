@@ -0,0 +1,157 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package sqlite3
+
+import (
+	"database/sql"
+	"regexp"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/session/sessioniface"
+)
+
+// SessionStoreSQLite3 keeps sessions in a SQLite database so that they
+// survive restarts. It can share a database file with the event storage.
+type SessionStoreSQLite3 struct {
+	DbName    string
+	TableName string
+	db        *sql.DB
+	get       *sql.Stmt
+	set       *sql.Stmt
+}
+
+var validTableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Init opens a long-lived connection to the database, creates the sessions
+// table if it does not exist and prepares the statements used by the store.
+//
+// The connection is held until Close is called.
+func Init(dbName string, options ...func(*SessionStoreSQLite3)) *SessionStoreSQLite3 {
+	s := &SessionStoreSQLite3{DbName: dbName}
+
+	// Set Defaults
+	s.TableName = sessioniface.DB_TABLE_NAME
+
+	// Option parameters
+	for _, op := range options {
+		op(s)
+	}
+
+	if !validTableName.MatchString(s.TableName) {
+		panic("FATAL: TableName must only contain letters, digits and underscores.")
+	}
+
+	db, err := sql.Open("sqlite3", dbName)
+	common.CheckErr(err)
+
+	// A single connection avoids "database is locked" errors on concurrent writes
+	// and ensures in-memory databases are shared by every statement
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("PRAGMA journal_mode=WAL;")
+	common.CheckErr(err)
+
+	columns := sessioniface.DB_COLUMN_USER_ID + ", " +
+		sessioniface.DB_COLUMN_SESSION_ID + ", " +
+		sessioniface.DB_COLUMN_SESSION_INDEX + ", " +
+		sessioniface.DB_COLUMN_PREVIOUS_SESSION_ID + ", " +
+		sessioniface.DB_COLUMN_FIRST_EVENT_ID + ", " +
+		sessioniface.DB_COLUMN_FIRST_EVENT_TIME + ", " +
+		sessioniface.DB_COLUMN_EVENT_INDEX + ", " +
+		sessioniface.DB_COLUMN_LAST_ACTIVITY
+
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS " + s.TableName + "(" +
+		sessioniface.DB_COLUMN_USER_ID + " TEXT PRIMARY KEY, " +
+		sessioniface.DB_COLUMN_SESSION_ID + " TEXT NOT NULL, " +
+		sessioniface.DB_COLUMN_SESSION_INDEX + " INTEGER NOT NULL, " +
+		sessioniface.DB_COLUMN_PREVIOUS_SESSION_ID + " TEXT NOT NULL, " +
+		sessioniface.DB_COLUMN_FIRST_EVENT_ID + " TEXT NOT NULL, " +
+		sessioniface.DB_COLUMN_FIRST_EVENT_TIME + " INTEGER NOT NULL, " +
+		sessioniface.DB_COLUMN_EVENT_INDEX + " INTEGER NOT NULL, " +
+		sessioniface.DB_COLUMN_LAST_ACTIVITY + " INTEGER NOT NULL" +
+		");")
+	common.CheckErr(err)
+
+	s.db = db
+	s.get, err = db.Prepare("SELECT " + columns + " FROM " + s.TableName + " WHERE " + sessioniface.DB_COLUMN_USER_ID + " = ?;")
+	common.CheckErr(err)
+	s.set, err = db.Prepare("INSERT OR REPLACE INTO " + s.TableName + "(" + columns + ") values(?, ?, ?, ?, ?, ?, ?, ?);")
+	common.CheckErr(err)
+
+	return s
+}
+
+// --- Option
+
+// OptionTableName sets the name of the table sessions are stored in.
+func OptionTableName(tableName string) func(s *SessionStoreSQLite3) {
+	return func(s *SessionStoreSQLite3) { s.TableName = tableName }
+}
+
+// Close releases the prepared statements and closes the database connection.
+//
+// The store cannot be used after it has been closed.
+func (s *SessionStoreSQLite3) Close() error {
+	s.get.Close()
+	s.set.Close()
+	return s.db.Close()
+}
+
+// GetSession returns the session of the user, or nil if there is none.
+func (s *SessionStoreSQLite3) GetSession(userId string) (*sessioniface.Session, error) {
+	session := sessioniface.Session{}
+	var firstEventTime, lastActivity int64
+	err := s.get.QueryRow(userId).Scan(
+		&session.UserId,
+		&session.SessionId,
+		&session.SessionIndex,
+		&session.PreviousSessionId,
+		&session.FirstEventId,
+		&firstEventTime,
+		&session.EventIndex,
+		&lastActivity,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	session.FirstEventTime = time.UnixMilli(firstEventTime)
+	session.LastActivity = time.UnixMilli(lastActivity)
+	return &session, nil
+}
+
+// SetSession replaces the session of the user.
+func (s *SessionStoreSQLite3) SetSession(session sessioniface.Session) error {
+	_, err := s.set.Exec(
+		session.UserId,
+		session.SessionId,
+		session.SessionIndex,
+		session.PreviousSessionId,
+		session.FirstEventId,
+		session.FirstEventTime.UnixMilli(),
+		session.EventIndex,
+		session.LastActivity.UnixMilli(),
+	)
+	return err
+}
+
+// StorageMechanism returns STORAGE_MECHANISM_SQLITE.
+func (s *SessionStoreSQLite3) StorageMechanism() string {
+	return sessioniface.STORAGE_MECHANISM_SQLITE
+}
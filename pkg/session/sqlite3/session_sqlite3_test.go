@@ -0,0 +1,62 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package sqlite3
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/session/sessioniface"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/session/sessiontest"
+)
+
+// TestSessionStoreSQLite3Conformance runs the session store conformance suite.
+func TestSessionStoreSQLite3Conformance(t *testing.T) {
+	sessiontest.Run(t, sessiontest.Factory{
+		New: func(t *testing.T) sessioniface.SessionStore {
+			return openTestStore(t, filepath.Join(t.TempDir(), "sessions.db"))
+		},
+		Reopen: func(t *testing.T, store sessioniface.SessionStore) sessioniface.SessionStore {
+			s := store.(*SessionStoreSQLite3)
+			s.Close()
+			return openTestStore(t, s.DbName)
+		},
+	})
+}
+
+// TestSessionStoreSQLite3TableName asserts that stores with different tables
+// in one database are independent.
+func TestSessionStoreSQLite3TableName(t *testing.T) {
+	assert := assert.New(t)
+	dbName := filepath.Join(t.TempDir(), "sessions.db")
+	first := openTestStore(t, dbName)
+	second := openTestStore(t, dbName, OptionTableName("other_sessions"))
+
+	assert.Nil(first.SetSession(sessiontest.NewSession("user-1", 1)))
+	session, err := second.GetSession("user-1")
+	assert.Nil(err)
+	assert.Nil(session)
+
+	assert.PanicsWithValue("FATAL: TableName must only contain letters, digits and underscores.", func() {
+		Init(dbName, OptionTableName("sessions; DROP TABLE sessions"))
+	})
+}
+
+func openTestStore(t *testing.T, dbName string, options ...func(*SessionStoreSQLite3)) *SessionStoreSQLite3 {
+	store := Init(dbName, options...)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
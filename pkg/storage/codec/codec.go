@@ -0,0 +1,121 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package codec
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+)
+
+const (
+	NAME_JSON = "json"
+	NAME_GOB  = "gob"
+)
+
+// Codec converts event payloads to and from the bytes persisted by a storage backend.
+//
+// The name of a Codec is stored alongside every row it encodes so that rows
+// can still be decoded after the default Codec of a storage has changed.
+type Codec interface {
+	Name() string
+	Encode(pairs map[string]string) ([]byte, error)
+	Decode(b []byte) (map[string]string, error)
+}
+
+var (
+	JSON Codec = jsonCodec{}
+	Gob  Codec = gobCodec{}
+
+	registryLock sync.RWMutex
+	registry     = map[string]Codec{
+		NAME_JSON: JSON,
+		NAME_GOB:  Gob,
+	}
+)
+
+// Register makes a custom Codec available for decoding rows by name.
+// Registering a Codec with the name of an existing Codec replaces it.
+func Register(c Codec) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	registry[c.Name()] = c
+}
+
+// Lookup returns the registered Codec with the given name.
+func Lookup(name string) (Codec, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	c, ok := registry[name]
+	return c, ok
+}
+
+// Decode decodes the bytes using the registered Codec with the given name.
+func Decode(name string, b []byte) (map[string]string, error) {
+	c, ok := Lookup(name)
+	if !ok {
+		return nil, &UnknownCodecError{Name: name}
+	}
+	return c.Decode(b)
+}
+
+// UnknownCodecError is returned when a row was written with a Codec that has not been registered.
+type UnknownCodecError struct {
+	Name string
+}
+
+func (e *UnknownCodecError) Error() string {
+	return "codec: unknown codec \"" + e.Name + "\""
+}
+
+// --- JSON
+
+// jsonCodec encodes payloads as a JSON object and is the default for all storage backends.
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string {
+	return NAME_JSON
+}
+
+func (jsonCodec) Encode(pairs map[string]string) ([]byte, error) {
+	return json.Marshal(pairs)
+}
+
+func (jsonCodec) Decode(b []byte) (map[string]string, error) {
+	var pairs map[string]string
+	err := json.Unmarshal(b, &pairs)
+	if err != nil {
+		return nil, err
+	}
+	return pairs, nil
+}
+
+// --- Gob
+
+// gobCodec encodes payloads with encoding/gob, which was used by earlier versions of the tracker.
+// It is kept so that rows persisted by those versions can still be read.
+type gobCodec struct{}
+
+func (gobCodec) Name() string {
+	return NAME_GOB
+}
+
+func (gobCodec) Encode(pairs map[string]string) ([]byte, error) {
+	return common.SerializeMap(pairs), nil
+}
+
+func (gobCodec) Decode(b []byte) (map[string]string, error) {
+	return common.DeserializeMap(b)
+}
@@ -0,0 +1,98 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package codec
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+)
+
+// TestCodecRoundTrip asserts that the built-in codecs can decode what they encode.
+func TestCodecRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	pairs := map[string]string{"e": "pv", "url": "https://acme.com/?q=\"quoted\"&x=ü"}
+
+	for _, c := range []Codec{JSON, Gob} {
+		b, err := c.Encode(pairs)
+		assert.Nil(err)
+		decoded, err := c.Decode(b)
+		assert.Nil(err)
+		assert.Equal(pairs, decoded, c.Name())
+	}
+
+	b, _ := JSON.Encode(pairs)
+	assert.Equal("{\"e\":\"pv\",\"url\":\"https://acme.com/?q=\\\"quoted\\\"\\u0026x=ü\"}", string(b))
+}
+
+// TestCodecDecodeErrors asserts that malformed input is reported as an error.
+func TestCodecDecodeErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := JSON.Decode([]byte("not json"))
+	assert.NotNil(err)
+	_, err = Gob.Decode([]byte("not gob"))
+	assert.NotNil(err)
+}
+
+// TestCodecLookup asserts lookup of built-in and registered codecs by name.
+func TestCodecLookup(t *testing.T) {
+	assert := assert.New(t)
+
+	c, ok := Lookup(NAME_JSON)
+	assert.True(ok)
+	assert.Equal(JSON, c)
+	c, ok = Lookup(NAME_GOB)
+	assert.True(ok)
+	assert.Equal(Gob, c)
+
+	_, ok = Lookup("upper")
+	assert.False(ok)
+	_, err := Decode("upper", []byte{})
+	var unknown *UnknownCodecError
+	assert.True(errors.As(err, &unknown))
+	assert.Equal("codec: unknown codec \"upper\"", err.Error())
+
+	Register(upperCodec{})
+	t.Cleanup(func() {
+		registryLock.Lock()
+		defer registryLock.Unlock()
+		delete(registry, "upper")
+	})
+	decoded, err := Decode("upper", []byte("pv"))
+	assert.Nil(err)
+	assert.Equal(map[string]string{"e": "PV"}, decoded)
+
+	legacy := common.SerializeMap(map[string]string{"e": "pv"})
+	decoded, err = Decode(NAME_GOB, legacy)
+	assert.Nil(err)
+	assert.Equal(map[string]string{"e": "pv"}, decoded)
+}
+
+type upperCodec struct{}
+
+func (upperCodec) Name() string { return "upper" }
+
+func (upperCodec) Encode(pairs map[string]string) ([]byte, error) { return []byte(pairs["e"]), nil }
+
+func (upperCodec) Decode(b []byte) (map[string]string, error) {
+	out := []byte{}
+	for _, c := range b {
+		out = append(out, c-32)
+	}
+	return map[string]string{"e": string(out)}, nil
+}
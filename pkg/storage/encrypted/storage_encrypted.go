@@ -0,0 +1,257 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package encrypted
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/codec"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storageiface"
+)
+
+const (
+	KEY_ID     = "kid" // Id of the key an event was encrypted with
+	CIPHERTEXT = "ct"  // Base64 encoded nonce followed by the sealed event
+)
+
+// StorageEncrypted encrypts events with AES-GCM before handing them to
+// another Storage, and decrypts them again when they are read back.
+//
+// Each stored event is tagged with the id of the key it was encrypted with.
+// To rotate keys, initialise the storage with the new key and pass the
+// previous keys with OptionDecryptionKey; new events are encrypted with the
+// new key while events already queued stay readable. A previous key can be
+// dropped once every event encrypted with it has been sent.
+type StorageEncrypted struct {
+	Storage storageiface.Storage
+	KeyId   string
+	keys    map[string]cipher.AEAD
+}
+
+// Init wraps the storage so that events are encrypted with the key before
+// they are stored. The key must be 16, 24 or 32 bytes long to select
+// AES-128, AES-192 or AES-256.
+func Init(storage storageiface.Storage, keyId string, key []byte, options ...func(*StorageEncrypted)) *StorageEncrypted {
+	s := &StorageEncrypted{Storage: storage, KeyId: keyId, keys: map[string]cipher.AEAD{}}
+
+	if storage == nil {
+		panic("FATAL: Storage must be defined.")
+	}
+	if keyId == "" {
+		panic("FATAL: KeyId cannot be empty.")
+	}
+	s.keys[keyId] = newAEAD(key)
+
+	// Option parameters
+	for _, op := range options {
+		op(s)
+	}
+
+	return s
+}
+
+// newAEAD creates an AES-GCM cipher for the key.
+func newAEAD(key []byte) cipher.AEAD {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic("FATAL: Key must be 16, 24 or 32 bytes long.")
+	}
+	aead, err := cipher.NewGCM(block)
+	common.CheckErr(err)
+	return aead
+}
+
+// --- Option
+
+// OptionDecryptionKey adds a previous key which is only used to decrypt events stored with it.
+func OptionDecryptionKey(keyId string, key []byte) func(s *StorageEncrypted) {
+	return func(s *StorageEncrypted) {
+		if _, ok := s.keys[keyId]; !ok {
+			s.keys[keyId] = newAEAD(key)
+		}
+	}
+}
+
+// Close closes the wrapped storage if it can be closed.
+func (s StorageEncrypted) Close() error {
+	if closer, ok := s.Storage.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// --- ADD
+
+// AddEventRow encrypts the event payload and adds it to the wrapped storage.
+func (s StorageEncrypted) AddEventRow(payload payload.Payload) bool {
+	sealed, err := s.seal(payload)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+	return s.Storage.AddEventRow(sealed)
+}
+
+// AddEventRows encrypts a batch of event payloads and adds them to the wrapped
+// storage in one operation if it supports it.
+func (s StorageEncrypted) AddEventRows(payloads []payload.Payload) bool {
+	sealed := []payload.Payload{}
+	for _, p := range payloads {
+		sp, err := s.seal(p)
+		if err != nil {
+			log.Println(err)
+			return false
+		}
+		sealed = append(sealed, sp)
+	}
+
+	if bulk, ok := s.Storage.(storageiface.BulkStorage); ok {
+		return bulk.AddEventRows(sealed)
+	}
+	for _, sp := range sealed {
+		if !s.Storage.AddEventRow(sp) {
+			return false
+		}
+	}
+	return true
+}
+
+// --- DELETE
+
+// DeleteAllEventRows removes all events from the wrapped storage.
+func (s StorageEncrypted) DeleteAllEventRows() int64 {
+	return s.Storage.DeleteAllEventRows()
+}
+
+// DeleteEventRows removes the events with matching identifiers from the wrapped storage.
+func (s StorageEncrypted) DeleteEventRows(ids []int) int64 {
+	return s.Storage.DeleteEventRows(ids)
+}
+
+// --- GET
+
+// GetAllEventRows returns all events in the wrapped storage which can be decrypted, in the order of the wrapped storage.
+func (s StorageEncrypted) GetAllEventRows() []storageiface.EventRow {
+	return s.openRows(s.Storage.GetAllEventRows())
+}
+
+// GetEventRowsWithinRange returns up to eventRange events which can be decrypted, in the order of the wrapped
+// storage. Rows which cannot be decrypted are read past, so that they do not hold up the events behind them.
+func (s StorageEncrypted) GetEventRowsWithinRange(eventRange int) []storageiface.EventRow {
+	if eventRange <= 0 {
+		return s.openRows(s.Storage.GetEventRowsWithinRange(eventRange))
+	}
+
+	want := eventRange
+	for {
+		eventRows := s.Storage.GetEventRowsWithinRange(want)
+		opened := s.openRows(eventRows)
+		if len(opened) >= eventRange || len(eventRows) < want {
+			if len(opened) > eventRange {
+				opened = opened[:eventRange]
+			}
+			return opened
+		}
+		want += eventRange - len(opened)
+	}
+}
+
+// CountEventRows returns the number of events in the wrapped storage, including
+// any which cannot be decrypted, without decrypting them.
+func (s StorageEncrypted) CountEventRows() int {
+	if counting, ok := s.Storage.(storageiface.CountingStorage); ok {
+		return counting.CountEventRows()
+	}
+	return len(s.Storage.GetAllEventRows())
+}
+
+// --- Helpers
+
+// seal encrypts the payload with the current key.
+//
+// The key id is used as additional authenticated data so that a row cannot be
+// re-tagged to be decrypted with a different key.
+func (s StorageEncrypted) seal(p payload.Payload) (payload.Payload, error) {
+	plaintext, err := codec.JSON.Encode(p.Get())
+	if err != nil {
+		return payload.Payload{}, err
+	}
+
+	aead := s.keys[s.KeyId]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return payload.Payload{}, err
+	}
+	ciphertext := aead.Seal(nonce, nonce, plaintext, []byte(s.KeyId))
+
+	sealed := *payload.Init()
+	sealed.Add(KEY_ID, common.NewString(s.KeyId))
+	sealed.Add(CIPHERTEXT, common.NewString(base64.StdEncoding.EncodeToString(ciphertext)))
+	return sealed, nil
+}
+
+// open decrypts a payload sealed by seal.
+func (s StorageEncrypted) open(p payload.Payload) (map[string]string, error) {
+	keyId := p.Get()[KEY_ID]
+	aead, ok := s.keys[keyId]
+	if !ok {
+		return nil, errors.New("encrypted: no key with id \"" + keyId + "\"")
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(p.Get()[CIPHERTEXT])
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("encrypted: ciphertext too short")
+	}
+
+	nonce := ciphertext[:aead.NonceSize()]
+	plaintext, err := aead.Open(nil, nonce, ciphertext[aead.NonceSize():], []byte(keyId))
+	if err != nil {
+		return nil, err
+	}
+	return codec.JSON.Decode(plaintext)
+}
+
+// openRows decrypts the rows. Rows which cannot be decrypted, for example
+// because their key is no longer configured, are logged and left out of the
+// result; they stay in the wrapped storage until the key is supplied again or
+// they are deleted.
+func (s StorageEncrypted) openRows(eventRows []storageiface.EventRow) []storageiface.EventRow {
+	opened := []storageiface.EventRow{}
+	var failed []int
+	var lastErr error
+	for _, row := range eventRows {
+		pairs, err := s.open(row.Event)
+		if err != nil {
+			failed = append(failed, row.Id)
+			lastErr = err
+			continue
+		}
+		opened = append(opened, storageiface.EventRow{Id: row.Id, Event: payload.Payload{Pairs: pairs}})
+	}
+	if len(failed) > 0 {
+		log.Printf("encrypted: skipped %d rows which could not be decrypted %v: %v", len(failed), failed, lastErr)
+	}
+	return opened
+}
@@ -0,0 +1,234 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package encrypted
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/filelog"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/memory"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storageiface"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storagetest"
+)
+
+var (
+	keyA = bytes.Repeat([]byte{'a'}, 32)
+	keyB = bytes.Repeat([]byte{'b'}, 16)
+)
+
+// TestStorageEncryptedInit asserts behaviour of encrypted storage functions.
+func TestStorageEncryptedInit(t *testing.T) {
+	assert := assert.New(t)
+	inner := *memory.Init()
+	storage := *Init(inner, "a", keyA, OptionDecryptionKey("b", keyB))
+
+	assert.Equal(inner, storage.Storage)
+	assert.Equal("a", storage.KeyId)
+	assert.Equal(2, len(storage.keys))
+	assert.Nil(storage.Close())
+
+	assert.PanicsWithValue("FATAL: Storage must be defined.", func() { Init(nil, "a", keyA) })
+	assert.PanicsWithValue("FATAL: KeyId cannot be empty.", func() { Init(inner, "", keyA) })
+	assert.PanicsWithValue("FATAL: Key must be 16, 24 or 32 bytes long.", func() { Init(inner, "a", []byte("short")) })
+	assert.PanicsWithValue("FATAL: Key must be 16, 24 or 32 bytes long.", func() { Init(inner, "a", keyA, OptionDecryptionKey("b", nil)) })
+}
+
+// TestEncryptedAddGetDeletePayload asserts ability to add, delete and get payloads.
+func TestEncryptedAddGetDeletePayload(t *testing.T) {
+	assert := assert.New(t)
+	storage := *Init(*memory.Init(), "a", keyA)
+	assertDatabaseAddGetDeletePayload(assert, storage)
+}
+
+// TestEncryptedConformance runs the storage conformance suite.
+func TestEncryptedConformance(t *testing.T) {
+	storagetest.Run(t, storagetest.Factory{
+		New: func(t *testing.T) storageiface.Storage {
+			return *Init(*filelog.Init(t.TempDir()), "a", keyA)
+		},
+		Reopen: func(t *testing.T, storage storageiface.Storage) storageiface.Storage {
+			storage.(StorageEncrypted).Close()
+			return *Init(*filelog.Init(storage.(StorageEncrypted).Storage.(filelog.StorageFileLog).Dir), "a", keyA)
+		},
+	})
+}
+
+// TestEncryptedAtRest asserts that nothing but the key id is stored in plain text.
+func TestEncryptedAtRest(t *testing.T) {
+	assert := assert.New(t)
+	inner := *memory.Init()
+	storage := *Init(inner, "a", keyA)
+
+	p := *payload.Init()
+	p.Add("uid", common.NewString("user@acme.com"))
+	p.Add("ip", common.NewString("10.0.0.1"))
+	assert.True(storage.AddEventRow(p))
+	assert.True(storage.AddEventRow(p))
+
+	stored := inner.GetAllEventRows()
+	assert.Equal(2, len(stored))
+	assert.Equal([]string{CIPHERTEXT, KEY_ID}, sortedKeys(stored[0].Event.Get()))
+	assert.Equal("a", stored[0].Event.Get()[KEY_ID])
+	assert.False(strings.Contains(stored[0].Event.String(), "acme.com"))
+	assert.False(strings.Contains(stored[0].Event.String(), "10.0.0.1"))
+
+	// A fresh nonce is used for every event
+	assert.NotEqual(stored[0].Event.Get()[CIPHERTEXT], stored[1].Event.Get()[CIPHERTEXT])
+
+	eventRows := storage.GetAllEventRows()
+	assert.Equal("user@acme.com", eventRows[0].Event.Get()["uid"])
+	assert.Equal("10.0.0.1", eventRows[1].Event.Get()["ip"])
+}
+
+// TestEncryptedKeyRotation asserts that events stored with a previous key remain readable after rotation.
+func TestEncryptedKeyRotation(t *testing.T) {
+	assert := assert.New(t)
+	inner := *filelog.Init(t.TempDir())
+	defer inner.Close()
+
+	p := *payload.Init()
+	p.Add("e", common.NewString("pv"))
+	assert.True(Init(inner, "a", keyA).AddEventRow(p))
+
+	storage := *Init(inner, "b", keyB, OptionDecryptionKey("a", keyA))
+	p.Add("e", common.NewString("se"))
+	assert.True(storage.AddEventRows([]payload.Payload{p, p}))
+
+	stored := inner.GetAllEventRows()
+	assert.Equal([]string{"a", "b", "b"}, []string{stored[0].Event.Get()[KEY_ID], stored[1].Event.Get()[KEY_ID], stored[2].Event.Get()[KEY_ID]})
+
+	eventRows := storage.GetEventRowsWithinRange(2)
+	assert.Equal("pv", eventRows[0].Event.Get()["e"])
+	assert.Equal("se", eventRows[1].Event.Get()["e"])
+
+	// Without the previous key its events are left out but stay stored
+	eventRows = Init(inner, "b", keyB).GetAllEventRows()
+	assert.Equal(2, len(eventRows))
+	assert.Equal(stored[1].Id, eventRows[0].Id)
+	assert.Equal("se", eventRows[0].Event.Get()["e"])
+	assert.Equal(3, len(inner.GetAllEventRows()))
+
+	// Reading a range skips past them to the events which can be sent
+	eventRows = Init(inner, "b", keyB).GetEventRowsWithinRange(1)
+	assert.Equal(1, len(eventRows))
+	assert.Equal(stored[1].Id, eventRows[0].Id)
+	eventRows = Init(inner, "b", keyB).GetEventRowsWithinRange(5)
+	assert.Equal(2, len(eventRows))
+}
+
+// TestEncryptedRangeSkipsUndecryptable asserts that a run of undecryptable rows
+// at the head of the storage does not hide the events behind it.
+func TestEncryptedRangeSkipsUndecryptable(t *testing.T) {
+	assert := assert.New(t)
+	inner := *memory.Init()
+
+	p := *payload.Init()
+	p.Add("e", common.NewString("pv"))
+	old := Init(inner, "a", keyA)
+	for i := 0; i < 25; i++ {
+		assert.True(old.AddEventRow(p))
+	}
+	storage := *Init(inner, "b", keyB)
+	for i := 0; i < 3; i++ {
+		assert.True(storage.AddEventRow(p))
+	}
+
+	eventRows := storage.GetEventRowsWithinRange(2)
+	assert.Equal(2, len(eventRows))
+	assert.Equal("pv", eventRows[0].Event.Get()["e"])
+	assert.Equal(3, len(storage.GetEventRowsWithinRange(10)))
+}
+
+// TestEncryptedTampering asserts that modified or re-tagged rows fail authentication.
+func TestEncryptedTampering(t *testing.T) {
+	assert := assert.New(t)
+	storage := *Init(*memory.Init(), "a", keyA, OptionDecryptionKey("b", keyA))
+
+	p := *payload.Init()
+	p.Add("e", common.NewString("pv"))
+	sealed, err := storage.seal(p)
+	assert.Nil(err)
+
+	pairs, err := storage.open(sealed)
+	assert.Nil(err)
+	assert.Equal("pv", pairs["e"])
+
+	// Re-tagging with another key id which happens to hold the same key material
+	retagged := payload.Payload{Pairs: map[string]string{KEY_ID: "b", CIPHERTEXT: sealed.Get()[CIPHERTEXT]}}
+	_, err = storage.open(retagged)
+	assert.NotNil(err)
+
+	ciphertext := []byte(sealed.Get()[CIPHERTEXT])
+	ciphertext[20] ^= 1
+	_, err = storage.open(payload.Payload{Pairs: map[string]string{KEY_ID: "a", CIPHERTEXT: string(ciphertext)}})
+	assert.NotNil(err)
+
+	_, err = storage.open(payload.Payload{Pairs: map[string]string{KEY_ID: "a", CIPHERTEXT: "AAAA"}})
+	assert.Equal("encrypted: ciphertext too short", err.Error())
+
+	_, err = storage.open(payload.Payload{Pairs: map[string]string{KEY_ID: "c"}})
+	assert.Equal("encrypted: no key with id \"c\"", err.Error())
+}
+
+// --- Common
+
+func assertDatabaseAddGetDeletePayload(assert *assert.Assertions, storage storageiface.Storage) {
+	storage.DeleteAllEventRows()
+	payload := *payload.Init()
+	payload.Add("e", common.NewString("pv"))
+
+	// Add a Payload
+	assert.True(storage.AddEventRow(payload))
+	eventRows := storage.GetAllEventRows()
+	assert.Equal(1, len(eventRows))
+	assert.Equal("pv", eventRows[0].Event.Get()["e"])
+
+	// Delete the added row
+	assert.Equal(int64(1), storage.DeleteEventRows([]int{eventRows[0].Id}))
+	eventRows = storage.GetAllEventRows()
+	assert.Equal(0, len(eventRows))
+
+	// Add 20 payloads
+	for i := 0; i < 20; i++ {
+		result := storage.AddEventRow(payload)
+		assert.True(result)
+	}
+
+	eventRows = storage.GetEventRowsWithinRange(10)
+	assert.Equal(10, len(eventRows))
+	eventRows = storage.GetEventRowsWithinRange(30)
+	assert.Equal(20, len(eventRows))
+	eventRows = storage.GetAllEventRows()
+	assert.Equal(20, len(eventRows))
+	assert.Equal(int64(20), storage.DeleteAllEventRows())
+	eventRows = storage.GetAllEventRows()
+	assert.Equal(0, len(eventRows))
+	assert.Equal(int64(0), storage.DeleteEventRows([]int{}))
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,265 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package filelog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	SEGMENT_EXTENSION   = ".seg"
+	RECORD_HEADER_BYTES = 8  // length (uint32) + crc32 (uint32) of the record body
+	ACK_RECORD_BYTES    = 12 // id (uint64) + crc32 (uint32)
+)
+
+var errCorruptRecord = errors.New("filelog: corrupt record")
+
+// segment is a single append-only file of event records.
+//
+// The events in a segment have contiguous ids starting at base, so the
+// position of an event within the segment is always id - base.
+type segment struct {
+	base    int
+	path    string
+	file    *os.File
+	size    int64
+	offsets []int64
+	acked   []bool
+	live    int
+	head    int
+}
+
+// segmentPath returns the path of the segment starting at base within dir.
+// Names are zero-padded so that they sort in id order.
+func segmentPath(dir string, base int) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d", base)+SEGMENT_EXTENSION)
+}
+
+// listSegments returns the base ids of every segment within dir in ascending order.
+func listSegments(dir string) ([]int, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+SEGMENT_EXTENSION))
+	if err != nil {
+		return nil, err
+	}
+	bases := []int{}
+	for _, match := range matches {
+		base, err := strconv.Atoi(strings.TrimSuffix(filepath.Base(match), SEGMENT_EXTENSION))
+		if err == nil {
+			bases = append(bases, base)
+		}
+	}
+	// Glob returns matches in lexical order which is id order for zero-padded names
+	return bases, nil
+}
+
+// createSegment creates a new empty segment starting at base.
+func createSegment(dir string, base int) (*segment, error) {
+	path := segmentPath(dir, base)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &segment{base: base, path: path, file: file}, nil
+}
+
+// openSegment opens an existing segment and indexes its records.
+//
+// Scanning stops at the first torn or corrupt record, which can only be the
+// result of a crash part way through an append, and the file is truncated so
+// that new records are appended after the last good one.
+func openSegment(dir string, base int) (*segment, error) {
+	path := segmentPath(dir, base)
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	s := &segment{base: base, path: path, file: file}
+
+	for {
+		id, body, err := s.readRecord(s.size, info.Size())
+		if err != nil || id != s.nextId() {
+			break
+		}
+		s.offsets = append(s.offsets, s.size)
+		s.acked = append(s.acked, false)
+		s.live++
+		s.size += RECORD_HEADER_BYTES + int64(len(body))
+	}
+
+	if info.Size() > s.size {
+		if err := file.Truncate(s.size); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// nextId returns the id the next record appended to the segment will have.
+func (s *segment) nextId() int {
+	return s.base + len(s.offsets)
+}
+
+// contains checks whether the id belongs to a record within the segment.
+func (s *segment) contains(id int) bool {
+	return id >= s.base && id < s.nextId()
+}
+
+// append writes a record to the end of the segment.
+func (s *segment) append(codecName string, data []byte) (int, error) {
+	id := s.nextId()
+	body := make([]byte, 0, 9+len(codecName)+len(data))
+	body = binary.BigEndian.AppendUint64(body, uint64(id))
+	body = append(body, byte(len(codecName)))
+	body = append(body, codecName...)
+	body = append(body, data...)
+
+	record := make([]byte, RECORD_HEADER_BYTES, RECORD_HEADER_BYTES+len(body))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(body)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(body))
+	record = append(record, body...)
+
+	if _, err := s.file.WriteAt(record, s.size); err != nil {
+		return 0, err
+	}
+	s.offsets = append(s.offsets, s.size)
+	s.acked = append(s.acked, false)
+	s.live++
+	s.size += int64(len(record))
+
+	return id, nil
+}
+
+// read returns the codec name and encoded data of the record with the given id.
+func (s *segment) read(id int) (string, []byte, error) {
+	_, body, err := s.readRecord(s.offsets[id-s.base], s.size)
+	if err != nil {
+		return "", nil, err
+	}
+	nameLength := int(body[8])
+	return string(body[9 : 9+nameLength]), body[9+nameLength:], nil
+}
+
+// readRecord reads and verifies the record at offset, returning its id and
+// body. A record whose length runs past end, the size of the segment, is
+// treated as torn rather than trusting the length in its header.
+func (s *segment) readRecord(offset int64, end int64) (int, []byte, error) {
+	header := make([]byte, RECORD_HEADER_BYTES)
+	if _, err := s.file.ReadAt(header, offset); err != nil {
+		return 0, nil, err
+	}
+	length := int64(binary.BigEndian.Uint32(header[0:4]))
+	if length < 9 || length > end-offset-RECORD_HEADER_BYTES {
+		return 0, nil, errCorruptRecord
+	}
+
+	body := make([]byte, length)
+	if _, err := s.file.ReadAt(body, offset+RECORD_HEADER_BYTES); err != nil {
+		if err == io.EOF {
+			return 0, nil, errCorruptRecord
+		}
+		return 0, nil, err
+	}
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(header[4:8]) || 9+int64(body[8]) > length {
+		return 0, nil, errCorruptRecord
+	}
+
+	return int(binary.BigEndian.Uint64(body[0:8])), body, nil
+}
+
+// ack marks the record with the given id as acknowledged, returning false if it already was.
+func (s *segment) ack(id int) bool {
+	i := id - s.base
+	if s.acked[i] {
+		return false
+	}
+	s.acked[i] = true
+	s.live--
+	for s.head < len(s.acked) && s.acked[s.head] {
+		s.head++
+	}
+	return true
+}
+
+// acquire opens the segment file for reading if it is not already open.
+func (s *segment) acquire() error {
+	if s.file != nil {
+		return nil
+	}
+	file, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	s.file = file
+	return nil
+}
+
+// release closes the segment file. Sealed segments only hold their file open
+// while they are being read so that large backlogs do not exhaust file descriptors.
+func (s *segment) release() {
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+}
+
+// remove closes and deletes the segment file.
+func (s *segment) remove() error {
+	s.release()
+	return os.Remove(s.path)
+}
+
+// --- Acks
+
+// encodeAck serialises an acknowledged id into an ack index record.
+func encodeAck(buf []byte, id int) []byte {
+	record := binary.BigEndian.AppendUint64(nil, uint64(id))
+	buf = append(buf, record...)
+	return binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(record))
+}
+
+// readAcks returns every valid acknowledged id within the ack index.
+// A torn record at the end of the file is ignored.
+func readAcks(path string) ([]int, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return []int{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	ids := []int{}
+	for offset := 0; offset+ACK_RECORD_BYTES <= len(b); offset += ACK_RECORD_BYTES {
+		record := b[offset : offset+8]
+		if crc32.ChecksumIEEE(record) != binary.BigEndian.Uint32(b[offset+8:offset+ACK_RECORD_BYTES]) {
+			break
+		}
+		ids = append(ids, int(binary.BigEndian.Uint64(record)))
+	}
+	return ids, nil
+}
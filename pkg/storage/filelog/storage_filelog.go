@@ -0,0 +1,587 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package filelog
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/codec"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storageiface"
+)
+
+// FsyncPolicy controls when writes are flushed to stable storage.
+type FsyncPolicy int
+
+const (
+	FSYNC_ALWAYS   FsyncPolicy = iota // Flush after every add and delete
+	FSYNC_INTERVAL                    // Flush in the background every FsyncInterval
+	FSYNC_NEVER                       // Leave flushing to the operating system
+)
+
+const (
+	DEFAULT_SEGMENT_BYTES  = 8 * 1024 * 1024
+	DEFAULT_FSYNC_POLICY   = FSYNC_ALWAYS
+	DEFAULT_FSYNC_INTERVAL = time.Second
+	ACK_INDEX_NAME         = "ack.idx"
+	CHECKPOINT_ACKS        = 4096 // Number of acks for removed segments the index may hold before it is rewritten
+)
+
+// StorageFileLog stores events in append-only segment files within a directory
+// and requires no cgo.
+//
+// Deleted events are recorded in an ack index rather than being removed from
+// their segment; once every event in a segment has been deleted the whole
+// segment file is removed. The ack index is rewritten (checkpointed) once it
+// holds CHECKPOINT_ACKS records for segments which no longer exist.
+//
+// Only one StorageFileLog may use a directory at a time.
+type StorageFileLog struct {
+	Dir           string
+	SegmentBytes  int64
+	FsyncPolicy   FsyncPolicy
+	FsyncInterval time.Duration
+	Codec         codec.Codec
+	Order         storageiface.Order
+	state         *logState
+}
+
+// logState is the mutable state of the log shared by every copy of the storage.
+type logState struct {
+	lock       sync.Mutex
+	segments   []*segment
+	ackFile    *os.File
+	ackRecords int
+	dirty      bool
+	closed     bool
+	stop       chan struct{}
+	stopped    sync.WaitGroup
+}
+
+// Init opens the log within dir, creating the directory if required.
+//
+// Any torn record left by a crash is discarded and segments whose events have
+// all been deleted are removed before the storage is returned.
+func Init(dir string, options ...func(*StorageFileLog)) *StorageFileLog {
+	s := &StorageFileLog{Dir: dir}
+
+	// Set Defaults
+	s.SegmentBytes = DEFAULT_SEGMENT_BYTES
+	s.FsyncPolicy = DEFAULT_FSYNC_POLICY
+	s.FsyncInterval = DEFAULT_FSYNC_INTERVAL
+	s.Codec = codec.JSON
+
+	// Option parameters
+	for _, op := range options {
+		op(s)
+	}
+
+	if s.Codec == nil {
+		panic("FATAL: Codec cannot be nil.")
+	}
+	if s.FsyncPolicy < FSYNC_ALWAYS || s.FsyncPolicy > FSYNC_NEVER {
+		panic("FATAL: FsyncPolicy did not match ALWAYS, INTERVAL or NEVER.")
+	}
+	if s.FsyncPolicy == FSYNC_INTERVAL && s.FsyncInterval <= 0 {
+		panic("FATAL: FsyncInterval must be above 0.")
+	}
+
+	common.CheckErr(os.MkdirAll(dir, 0755))
+	s.state = &logState{stop: make(chan struct{})}
+	common.CheckErr(s.load())
+
+	if s.FsyncPolicy == FSYNC_INTERVAL {
+		s.state.stopped.Add(1)
+		go s.syncLoop()
+	}
+
+	return s
+}
+
+// load rebuilds the state of the log from the segment files and ack index.
+func (s StorageFileLog) load() error {
+	bases, err := listSegments(s.Dir)
+	if err != nil {
+		return err
+	}
+	for _, base := range bases {
+		seg, err := openSegment(s.Dir, base)
+		if err != nil {
+			return err
+		}
+		s.state.segments = append(s.state.segments, seg)
+	}
+
+	// Only the active segment keeps its file open
+	for i := 0; i < len(s.state.segments)-1; i++ {
+		s.state.segments[i].release()
+	}
+
+	if len(s.state.segments) == 0 {
+		seg, err := createSegment(s.Dir, 1)
+		if err != nil {
+			return err
+		}
+		s.state.segments = append(s.state.segments, seg)
+	}
+
+	acks, err := readAcks(filepath.Join(s.Dir, ACK_INDEX_NAME))
+	if err != nil {
+		return err
+	}
+	for _, id := range acks {
+		if seg := s.findSegment(id); seg != nil {
+			seg.ack(id)
+		}
+	}
+
+	if err := s.compact(); err != nil {
+		return err
+	}
+	return s.checkpoint()
+}
+
+// --- Option
+
+// OptionSegmentBytes sets the size a segment may grow to before a new one is started.
+func OptionSegmentBytes(segmentBytes int64) func(s *StorageFileLog) {
+	return func(s *StorageFileLog) { s.SegmentBytes = segmentBytes }
+}
+
+// OptionFsyncPolicy sets when writes are flushed to stable storage.
+func OptionFsyncPolicy(policy FsyncPolicy) func(s *StorageFileLog) {
+	return func(s *StorageFileLog) { s.FsyncPolicy = policy }
+}
+
+// OptionFsyncInterval sets how often writes are flushed when using FSYNC_INTERVAL.
+func OptionFsyncInterval(interval time.Duration) func(s *StorageFileLog) {
+	return func(s *StorageFileLog) { s.FsyncInterval = interval }
+}
+
+// OptionCodec sets the Codec used to encode new events.
+func OptionCodec(c codec.Codec) func(s *StorageFileLog) {
+	return func(s *StorageFileLog) { s.Codec = c }
+}
+
+// OptionOrder sets the order in which events are returned.
+func OptionOrder(order storageiface.Order) func(s *StorageFileLog) {
+	return func(s *StorageFileLog) { s.Order = order }
+}
+
+// Close checkpoints the ack index, flushes all pending writes and closes every file.
+//
+// The storage cannot be used after it has been closed.
+func (s StorageFileLog) Close() error {
+	s.state.lock.Lock()
+	if s.state.closed {
+		s.state.lock.Unlock()
+		return nil
+	}
+	s.state.closed = true
+	close(s.state.stop)
+	s.state.lock.Unlock()
+
+	s.state.stopped.Wait()
+
+	s.state.lock.Lock()
+	defer s.state.lock.Unlock()
+
+	err := s.checkpoint()
+	active := s.active()
+	s.syncNow(active.file)
+	active.release()
+	if s.state.ackFile != nil {
+		s.state.ackFile.Close()
+	}
+	return err
+}
+
+// --- ADD
+
+// AddEventRow appends an event payload to the active segment.
+func (s StorageFileLog) AddEventRow(event payload.Payload) bool {
+	return s.AddEventRows([]payload.Payload{event})
+}
+
+// AddEventRows appends a batch of event payloads to the log, flushing once for the whole batch.
+//
+// Either all of the payloads are stored or none of them are.
+func (s StorageFileLog) AddEventRows(payloads []payload.Payload) (ok bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Println(err)
+			ok = false
+		}
+	}()
+
+	s.state.lock.Lock()
+	defer s.state.lock.Unlock()
+	if s.state.closed {
+		return false
+	}
+
+	ids := []int{}
+	defer func() {
+		// Events from a partially written batch are acknowledged so that they are never sent
+		if !ok && len(ids) > 0 {
+			s.ack(ids)
+		}
+	}()
+
+	for _, p := range payloads {
+		data, err := s.Codec.Encode(p.Get())
+		common.CheckErr(err)
+		common.CheckErr(s.rotateIfFull())
+		id, err := s.active().append(s.Codec.Name(), data)
+		common.CheckErr(err)
+		ids = append(ids, id)
+	}
+
+	common.CheckErr(s.sync(s.active().file))
+	return true
+}
+
+// rotateIfFull seals the active segment and starts a new one once it has reached SegmentBytes.
+func (s StorageFileLog) rotateIfFull() error {
+	active := s.active()
+	if active.size < s.SegmentBytes || len(active.offsets) == 0 {
+		return nil
+	}
+	return s.rotate()
+}
+
+// rotate seals the active segment and starts a new one after it.
+func (s StorageFileLog) rotate() error {
+	active := s.active()
+	if err := s.syncNow(active.file); err != nil {
+		return err
+	}
+	seg, err := createSegment(s.Dir, active.nextId())
+	if err != nil {
+		return err
+	}
+	active.release()
+	s.state.segments = append(s.state.segments, seg)
+	s.syncDir()
+	return nil
+}
+
+// --- DELETE
+
+// DeleteAllEventRows acknowledges every event in the log.
+func (s StorageFileLog) DeleteAllEventRows() int64 {
+	s.state.lock.Lock()
+	defer s.state.lock.Unlock()
+
+	ids := []int{}
+	for _, seg := range s.state.segments {
+		for i := seg.head; i < len(seg.acked); i++ {
+			if !seg.acked[i] {
+				ids = append(ids, seg.base+i)
+			}
+		}
+	}
+	return s.ack(ids)
+}
+
+// DeleteEventRows acknowledges the events with matching identifiers.
+func (s StorageFileLog) DeleteEventRows(ids []int) int64 {
+	s.state.lock.Lock()
+	defer s.state.lock.Unlock()
+	return s.ack(ids)
+}
+
+// ack records the ids in the ack index and removes any segments which no longer hold events.
+func (s StorageFileLog) ack(ids []int) (affected int64) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	if s.state.closed || len(ids) == 0 {
+		return 0
+	}
+
+	buf := []byte{}
+	for _, id := range ids {
+		if seg := s.findSegment(id); seg != nil && seg.ack(id) {
+			buf = encodeAck(buf, id)
+			affected++
+		}
+	}
+	if affected == 0 {
+		return 0
+	}
+
+	_, err := s.state.ackFile.Write(buf)
+	common.CheckErr(err)
+	s.state.ackRecords += int(affected)
+	common.CheckErr(s.sync(s.state.ackFile))
+
+	common.CheckErr(s.compact())
+	if acked := s.ackedInSegments(); s.state.ackRecords-acked >= CHECKPOINT_ACKS || acked == 0 {
+		common.CheckErr(s.checkpoint())
+	}
+
+	return affected
+}
+
+// compact removes every segment whose events have all been acknowledged.
+//
+// A fully acknowledged active segment is replaced with a new empty one so that
+// event ids are never re-used.
+func (s StorageFileLog) compact() error {
+	if active := s.active(); active.live == 0 && len(active.offsets) > 0 {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	segments := []*segment{}
+	for i, seg := range s.state.segments {
+		if seg.live == 0 && i < len(s.state.segments)-1 {
+			if err := seg.remove(); err != nil {
+				return err
+			}
+			continue
+		}
+		segments = append(segments, seg)
+	}
+	if len(segments) < len(s.state.segments) {
+		s.syncDir()
+	}
+	s.state.segments = segments
+	return nil
+}
+
+// checkpoint rewrites the ack index so that it only holds acks for events in existing segments.
+func (s StorageFileLog) checkpoint() error {
+	buf := []byte{}
+	for _, seg := range s.state.segments {
+		for i := 0; i < len(seg.acked); i++ {
+			if seg.acked[i] {
+				buf = encodeAck(buf, seg.base+i)
+			}
+		}
+	}
+
+	path := filepath.Join(s.Dir, ACK_INDEX_NAME)
+	tmp, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := s.syncNow(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	tmp.Close()
+
+	if s.state.ackFile != nil {
+		s.state.ackFile.Close()
+		s.state.ackFile = nil
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	s.syncDir()
+
+	ackFile, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	s.state.ackFile = ackFile
+	s.state.ackRecords = len(buf) / ACK_RECORD_BYTES
+	return nil
+}
+
+// ackedInSegments counts the acknowledged events which are still held in segments.
+func (s StorageFileLog) ackedInSegments() int {
+	count := 0
+	for _, seg := range s.state.segments {
+		count += len(seg.offsets) - seg.live
+	}
+	return count
+}
+
+// --- GET
+
+// GetAllEventRows returns all events in the log in the configured Order.
+func (s StorageFileLog) GetAllEventRows() []storageiface.EventRow {
+	return s.getEventRows(-1)
+}
+
+// GetEventRowsWithinRange returns up to eventRange events from the log, taken in the configured Order.
+func (s StorageFileLog) GetEventRowsWithinRange(eventRange int) []storageiface.EventRow {
+	return s.getEventRows(eventRange)
+}
+
+// CountEventRows returns the number of events in the log without reading them.
+func (s StorageFileLog) CountEventRows() int {
+	s.state.lock.Lock()
+	defer s.state.lock.Unlock()
+
+	count := 0
+	if s.state.closed {
+		return count
+	}
+	for _, seg := range s.state.segments {
+		count += seg.live
+	}
+	return count
+}
+
+// getEventRows reads events from the segments in id order, stopping once limit
+// events have been read if limit is not negative.
+func (s StorageFileLog) getEventRows(limit int) []storageiface.EventRow {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	s.state.lock.Lock()
+	defer s.state.lock.Unlock()
+
+	eventItems := []storageiface.EventRow{}
+	if s.state.closed {
+		return eventItems
+	}
+
+	segments := s.state.segments
+	for n := range segments {
+		if limit >= 0 && len(eventItems) >= limit {
+			break
+		}
+		seg := segments[n]
+		if s.Order == storageiface.ORDER_NEWEST_FIRST {
+			seg = segments[len(segments)-1-n]
+		}
+		if seg.live == 0 {
+			continue
+		}
+		common.CheckErr(seg.acquire())
+		eventItems = append(eventItems, readSegment(seg, limit-len(eventItems), s.Order)...)
+		if seg != s.active() {
+			seg.release()
+		}
+	}
+
+	return eventItems
+}
+
+// readSegment reads the unacknowledged events from a segment, stopping once
+// limit events have been read if limit is not negative.
+func readSegment(seg *segment, limit int, order storageiface.Order) []storageiface.EventRow {
+	eventItems := []storageiface.EventRow{}
+	for n := 0; n < len(seg.acked)-seg.head && (limit < 0 || len(eventItems) < limit); n++ {
+		i := seg.head + n
+		if order == storageiface.ORDER_NEWEST_FIRST {
+			i = len(seg.acked) - 1 - n
+		}
+		if seg.acked[i] {
+			continue
+		}
+		name, data, err := seg.read(seg.base + i)
+		common.CheckErr(err)
+		eventMap, err := codec.Decode(name, data)
+		if err != nil {
+			log.Println(err)
+		}
+		eventItems = append(eventItems, storageiface.EventRow{Id: seg.base + i, Event: payload.Payload{Pairs: eventMap}})
+	}
+	return eventItems
+}
+
+// --- Helpers
+
+// active returns the segment new events are appended to.
+func (s StorageFileLog) active() *segment {
+	return s.state.segments[len(s.state.segments)-1]
+}
+
+// findSegment returns the segment holding the id or nil if no segment does.
+func (s StorageFileLog) findSegment(id int) *segment {
+	segments := s.state.segments
+	i := sort.Search(len(segments), func(i int) bool { return segments[i].base > id }) - 1
+	if i < 0 || !segments[i].contains(id) {
+		return nil
+	}
+	return segments[i]
+}
+
+// sync flushes the file according to the fsync policy.
+func (s StorageFileLog) sync(file *os.File) error {
+	switch s.FsyncPolicy {
+	case FSYNC_ALWAYS:
+		return file.Sync()
+	case FSYNC_INTERVAL:
+		s.state.dirty = true
+	}
+	return nil
+}
+
+// syncNow flushes the file immediately unless the policy is FSYNC_NEVER.
+// It is used for sealed segments and the checkpointed ack index which the
+// background sync loop does not track.
+func (s StorageFileLog) syncNow(file *os.File) error {
+	if s.FsyncPolicy == FSYNC_NEVER {
+		return nil
+	}
+	return file.Sync()
+}
+
+// syncDir flushes directory entries so that created, renamed and removed files survive a crash.
+// Not every platform supports this so errors are ignored.
+func (s StorageFileLog) syncDir() {
+	if s.FsyncPolicy == FSYNC_NEVER {
+		return
+	}
+	if dir, err := os.Open(s.Dir); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+}
+
+// syncLoop flushes the active segment and ack index every FsyncInterval until the storage is closed.
+func (s StorageFileLog) syncLoop() {
+	defer s.state.stopped.Done()
+	ticker := time.NewTicker(s.FsyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.state.stop:
+			return
+		case <-ticker.C:
+			s.state.lock.Lock()
+			if s.state.dirty {
+				s.active().file.Sync()
+				s.state.ackFile.Sync()
+				s.state.dirty = false
+			}
+			s.state.lock.Unlock()
+		}
+	}
+}
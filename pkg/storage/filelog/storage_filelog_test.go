@@ -0,0 +1,334 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package filelog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/codec"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storageiface"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storagetest"
+)
+
+// TestStorageFileLogInit asserts behaviour of file log storage functions.
+func TestStorageFileLogInit(t *testing.T) {
+	assert := assert.New(t)
+	dir := filepath.Join(t.TempDir(), "events")
+	storage := *Init(dir)
+	defer storage.Close()
+
+	assert.Equal(dir, storage.Dir)
+	assert.Equal(int64(DEFAULT_SEGMENT_BYTES), storage.SegmentBytes)
+	assert.Equal(FSYNC_ALWAYS, storage.FsyncPolicy)
+	assert.Equal(DEFAULT_FSYNC_INTERVAL, storage.FsyncInterval)
+	assert.Equal(codec.JSON, storage.Codec)
+	assert.Equal([]string{"00000000000000000001.seg", "ack.idx"}, listDir(t, dir))
+
+	assert.PanicsWithValue("FATAL: FsyncPolicy did not match ALWAYS, INTERVAL or NEVER.", func() { Init(dir, OptionFsyncPolicy(FsyncPolicy(3))) })
+	assert.PanicsWithValue("FATAL: FsyncInterval must be above 0.", func() { Init(dir, OptionFsyncPolicy(FSYNC_INTERVAL), OptionFsyncInterval(0)) })
+	assert.PanicsWithValue("FATAL: FsyncInterval must be above 0.", func() { Init(dir, OptionFsyncPolicy(FSYNC_INTERVAL), OptionFsyncInterval(-time.Second)) })
+	assert.NotPanics(func() { Init(t.TempDir(), OptionFsyncInterval(0)).Close() })
+}
+
+// TestFileLogAddGetDeletePayload asserts ability to add, delete and get payloads.
+func TestFileLogAddGetDeletePayload(t *testing.T) {
+	assert := assert.New(t)
+	storage := *Init(t.TempDir())
+	defer storage.Close()
+	assertDatabaseAddGetDeletePayload(assert, storage)
+}
+
+// TestFileLogAddGetDeletePayload_WithFsyncPolicies asserts behaviour is the same under every fsync policy.
+func TestFileLogAddGetDeletePayload_WithFsyncPolicies(t *testing.T) {
+	assert := assert.New(t)
+	for _, policy := range []FsyncPolicy{FSYNC_INTERVAL, FSYNC_NEVER} {
+		storage := *Init(t.TempDir(), OptionFsyncPolicy(policy), OptionFsyncInterval(time.Millisecond), OptionSegmentBytes(256))
+		assertDatabaseAddGetDeletePayload(assert, storage)
+		time.Sleep(5 * time.Millisecond)
+		assert.Nil(storage.Close())
+	}
+}
+
+// TestFileLogConformance runs the storage conformance suite in each order.
+func TestFileLogConformance(t *testing.T) {
+	for _, order := range []storageiface.Order{storageiface.ORDER_OLDEST_FIRST, storageiface.ORDER_NEWEST_FIRST} {
+		order := order
+		t.Run(order.String(), func(t *testing.T) {
+			storagetest.Run(t, storagetest.Factory{
+				New: func(t *testing.T) storageiface.Storage {
+					return openTestStorage(t, t.TempDir(), OptionOrder(order))
+				},
+				Reopen: func(t *testing.T, storage storageiface.Storage) storageiface.Storage {
+					storage.(StorageFileLog).Close()
+					return openTestStorage(t, storage.(StorageFileLog).Dir, OptionOrder(order))
+				},
+				Order: order,
+			})
+		})
+	}
+}
+
+// TestFileLogReopen asserts that only unacknowledged events survive closing and re-opening the log.
+func TestFileLogReopen(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	storage := *Init(dir, OptionCodec(codec.Gob))
+	assert.True(storage.AddEventRows(newPayloads(5)))
+	assert.Equal(int64(2), storage.DeleteEventRows([]int{2, 4}))
+	assert.Nil(storage.Close())
+	assert.Nil(storage.Close())
+
+	storage = *Init(dir)
+	defer storage.Close()
+	assert.Equal([]int{1, 3, 5}, rowIds(storage.GetAllEventRows()))
+	assert.Equal("0", storage.GetAllEventRows()[0].Event.Get()["eid"])
+
+	// Ids continue from where the log left off
+	assert.True(storage.AddEventRow(newPayloads(1)[0]))
+	assert.Equal([]int{1, 3, 5, 6}, rowIds(storage.GetAllEventRows()))
+}
+
+// TestFileLogCrashRecovery asserts that torn writes left by a crash are discarded on startup.
+func TestFileLogCrashRecovery(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	storage := *Init(dir)
+	assert.True(storage.AddEventRows(newPayloads(3)))
+	assert.Equal(int64(1), storage.DeleteEventRows([]int{1}))
+	storage.Close()
+
+	// Simulate a crash part way through appending an event and an ack
+	appendToFile(t, filepath.Join(dir, "00000000000000000001.seg"), []byte{0, 0, 0, 40, 1, 2, 3, 4, 0, 0})
+	appendToFile(t, filepath.Join(dir, ACK_INDEX_NAME), []byte{0, 0, 0})
+
+	storage = *Init(dir)
+	assert.Equal([]int{2, 3}, rowIds(storage.GetAllEventRows()))
+	assert.True(storage.AddEventRow(newPayloads(1)[0]))
+	assert.Equal(int64(1), storage.DeleteEventRows([]int{2}))
+	storage.Close()
+
+	storage = *Init(dir)
+	defer storage.Close()
+	assert.Equal([]int{3, 4}, rowIds(storage.GetAllEventRows()))
+}
+
+// TestFileLogTornLength asserts that a record whose header claims more bytes
+// than the segment holds is discarded as a torn tail.
+func TestFileLogTornLength(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	storage := *Init(dir)
+	assert.True(storage.AddEventRows(newPayloads(2)))
+	storage.Close()
+
+	path := filepath.Join(dir, "00000000000000000001.seg")
+	appendToFile(t, path, []byte{0xff, 0xff, 0xff, 0xf0, 1, 2, 3, 4, 0, 0, 0, 0, 0, 0, 0, 3})
+
+	storage = *Init(dir)
+	defer storage.Close()
+	assert.Equal([]int{1, 2}, rowIds(storage.GetAllEventRows()))
+	assert.True(storage.AddEventRow(newPayloads(1)[0]))
+	assert.Equal([]int{1, 2, 3}, rowIds(storage.GetAllEventRows()))
+}
+
+// TestFileLogCorruptRecord asserts that a record failing its checksum and everything after it is discarded.
+func TestFileLogCorruptRecord(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	storage := *Init(dir)
+	assert.True(storage.AddEventRows(newPayloads(3)))
+	storage.Close()
+
+	path := filepath.Join(dir, "00000000000000000001.seg")
+	b, err := os.ReadFile(path)
+	assert.Nil(err)
+	b[len(b)-1] ^= 0xff
+	assert.Nil(os.WriteFile(path, b, 0644))
+
+	storage = *Init(dir)
+	defer storage.Close()
+	assert.Equal([]int{1, 2}, rowIds(storage.GetAllEventRows()))
+}
+
+// TestFileLogCompaction asserts that segments are removed once all of their events are acknowledged.
+func TestFileLogCompaction(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	storage := *Init(dir, OptionSegmentBytes(1))
+	assert.True(storage.AddEventRows(newPayloads(3)))
+	assert.Equal([]string{"00000000000000000001.seg", "00000000000000000002.seg", "00000000000000000003.seg", "ack.idx"}, listDir(t, dir))
+
+	assert.Equal(int64(1), storage.DeleteEventRows([]int{2}))
+	assert.Equal(int64(0), storage.DeleteEventRows([]int{2, 7, -1}))
+	assert.Equal([]string{"00000000000000000001.seg", "00000000000000000003.seg", "ack.idx"}, listDir(t, dir))
+
+	// Acks for removed segments are dropped from the index at a checkpoint
+	assert.Equal(int64(2), storage.DeleteAllEventRows())
+	assert.Equal([]string{"00000000000000000004.seg", "ack.idx"}, listDir(t, dir))
+	info, err := os.Stat(filepath.Join(dir, ACK_INDEX_NAME))
+	assert.Nil(err)
+	assert.Equal(int64(0), info.Size())
+	storage.Close()
+
+	// Ids are never re-used after every segment has been compacted
+	storage = *Init(dir)
+	defer storage.Close()
+	assert.True(storage.AddEventRow(newPayloads(1)[0]))
+	assert.Equal([]int{4}, rowIds(storage.GetAllEventRows()))
+}
+
+// TestFileLogCheckpoint asserts that the ack index is rewritten once it holds CHECKPOINT_ACKS records for removed segments.
+func TestFileLogCheckpoint(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+
+	// Size segments to hold exactly two events each
+	p := *payload.Init()
+	p.Add("e", common.NewString("pv"))
+	data, _ := codec.JSON.Encode(p.Get())
+	recordBytes := int64(RECORD_HEADER_BYTES + 9 + len(codec.NAME_JSON) + len(data))
+	storage := *Init(dir, OptionFsyncPolicy(FSYNC_NEVER), OptionSegmentBytes(2*recordBytes-1))
+	defer storage.Close()
+
+	payloads := []payload.Payload{}
+	for i := 0; i < 2*CHECKPOINT_ACKS+10; i++ {
+		payloads = append(payloads, p)
+	}
+	assert.True(storage.AddEventRows(payloads))
+	assert.Equal(CHECKPOINT_ACKS+5, len(storage.state.segments))
+
+	// Acks for events in segments which still exist are kept
+	assert.Equal(int64(1), storage.DeleteEventRows([]int{2}))
+	assert.Equal(1, storage.state.ackRecords)
+
+	ids := []int{}
+	for i := 3; i <= CHECKPOINT_ACKS+1; i++ {
+		ids = append(ids, i)
+	}
+	assert.Equal(int64(CHECKPOINT_ACKS-1), storage.DeleteEventRows(ids))
+	assert.Equal(CHECKPOINT_ACKS, storage.state.ackRecords)
+	assert.Equal(CHECKPOINT_ACKS/2+6, len(storage.state.segments))
+
+	// Removing one more segment takes the index over the limit
+	assert.Equal(int64(1), storage.DeleteEventRows([]int{CHECKPOINT_ACKS + 2}))
+	assert.Equal(1, storage.state.ackRecords)
+	assert.Equal(CHECKPOINT_ACKS+9, len(storage.GetAllEventRows()))
+	assert.Equal(3, len(storage.GetEventRowsWithinRange(3)))
+	assert.Equal(1, storage.GetEventRowsWithinRange(1)[0].Id)
+}
+
+// TestFileLogClosed asserts that the storage fails safely once it has been closed.
+func TestFileLogClosed(t *testing.T) {
+	assert := assert.New(t)
+	storage := *Init(t.TempDir())
+	assert.True(storage.AddEventRow(newPayloads(1)[0]))
+	assert.Nil(storage.Close())
+
+	assert.False(storage.AddEventRow(newPayloads(1)[0]))
+	assert.Equal(0, len(storage.GetAllEventRows()))
+	assert.Equal(0, len(storage.GetEventRowsWithinRange(10)))
+	assert.Equal(int64(0), storage.DeleteEventRows([]int{1}))
+	assert.Equal(int64(0), storage.DeleteAllEventRows())
+}
+
+// --- Common
+
+func assertDatabaseAddGetDeletePayload(assert *assert.Assertions, storage storageiface.Storage) {
+	storage.DeleteAllEventRows()
+	payload := *payload.Init()
+	payload.Add("e", common.NewString("pv"))
+
+	// Add a Payload
+	assert.True(storage.AddEventRow(payload))
+	eventRows := storage.GetAllEventRows()
+	assert.Equal(1, len(eventRows))
+	assert.Equal("pv", eventRows[0].Event.Get()["e"])
+
+	// Delete the added row
+	assert.Equal(int64(1), storage.DeleteEventRows([]int{eventRows[0].Id}))
+	eventRows = storage.GetAllEventRows()
+	assert.Equal(0, len(eventRows))
+
+	// Add 20 payloads
+	for i := 0; i < 20; i++ {
+		result := storage.AddEventRow(payload)
+		assert.True(result)
+	}
+
+	eventRows = storage.GetEventRowsWithinRange(10)
+	assert.Equal(10, len(eventRows))
+	eventRows = storage.GetEventRowsWithinRange(30)
+	assert.Equal(20, len(eventRows))
+	eventRows = storage.GetAllEventRows()
+	assert.Equal(20, len(eventRows))
+	assert.Equal(int64(20), storage.DeleteAllEventRows())
+	eventRows = storage.GetAllEventRows()
+	assert.Equal(0, len(eventRows))
+	assert.Equal(int64(0), storage.DeleteEventRows([]int{}))
+}
+
+func newPayloads(count int) []payload.Payload {
+	payloads := []payload.Payload{}
+	for i := 0; i < count; i++ {
+		p := *payload.Init()
+		p.Add("e", common.NewString("pv"))
+		p.Add("eid", common.NewString(common.IntToString(i)))
+		payloads = append(payloads, p)
+	}
+	return payloads
+}
+
+func rowIds(eventRows []storageiface.EventRow) []int {
+	ids := []int{}
+	for _, row := range eventRows {
+		ids = append(ids, row.Id)
+	}
+	return ids
+}
+
+func listDir(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func appendToFile(t *testing.T, path string, b []byte) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.Write(b); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func openTestStorage(t *testing.T, dir string, options ...func(*StorageFileLog)) StorageFileLog {
+	storage := *Init(dir, append([]func(*StorageFileLog){OptionSegmentBytes(512)}, options...)...)
+	t.Cleanup(func() { storage.Close() })
+	return storage
+}
@@ -0,0 +1,363 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package hybrid
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/memory"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storageiface"
+)
+
+const (
+	DEFAULT_THRESHOLD = 1000
+)
+
+// StorageHybrid keeps events in memory and spills them to a durable storage
+// once more than Threshold events are queued.
+//
+// Events in memory are always older than events in the durable storage: once
+// events have spilled, new events keep going to the durable storage until it
+// has been drained. Before the first event spills, and on Close, the events
+// held only in memory are copied to the durable storage. Init reads the oldest
+// persisted events back into memory. Events in memory keep their durable copy
+// until they are deleted, so they are only lost in a crash if they were added
+// since the last spill or Close.
+//
+// Rows from the durable storage are returned with negated ids so the durable
+// storage must only use positive ids, which is true of every storage in this
+// module. The durable storage must not be shared with anything else and must
+// return events ORDER_OLDEST_FIRST; the order of the hybrid storage itself is
+// set with OptionOrder.
+type StorageHybrid struct {
+	Memory    memory.StorageMemory
+	Durable   storageiface.Storage
+	Threshold int
+	Order     storageiface.Order
+	state     *hybridState
+}
+
+// hybridState is the mutable state shared by every copy of the storage.
+type hybridState struct {
+	lock         sync.Mutex
+	memoryCount  int
+	durableCount int         // Events only held by the durable storage
+	copies       map[int]int // Durable ids of the copies of events in memory, by memory id
+}
+
+// Init creates a hybrid storage in front of the durable storage and reloads
+// up to Threshold of the oldest events persisted within it into memory.
+func Init(durable storageiface.Storage, options ...func(*StorageHybrid)) *StorageHybrid {
+	s := &StorageHybrid{Memory: *memory.Init(), Durable: durable}
+
+	// Set Defaults
+	s.Threshold = DEFAULT_THRESHOLD
+
+	// Option parameters
+	for _, op := range options {
+		op(s)
+	}
+
+	if s.Durable == nil {
+		panic("FATAL: Durable storage must be defined.")
+	}
+	if s.Threshold < 0 {
+		panic("FATAL: Threshold cannot be negative.")
+	}
+
+	s.state = &hybridState{copies: map[int]int{}}
+	s.reload()
+
+	return s
+}
+
+// reload reads the oldest events from the durable storage into memory,
+// leaving them in the durable storage as the copies of the events in memory.
+func (s StorageHybrid) reload() {
+	eventRows := s.Durable.GetEventRowsWithinRange(s.Threshold)
+	for _, row := range eventRows {
+		if s.Memory.AddEventRow(row.Event) {
+			s.state.copies[s.lastMemoryId()] = row.Id
+			s.state.memoryCount++
+		}
+	}
+	s.state.durableCount = len(s.Durable.GetAllEventRows()) - len(s.state.copies)
+}
+
+// persist copies the events held only in memory to the durable storage. It
+// must only be called while no events have spilled, so that the copies are
+// written ahead of every spilled event.
+func (s StorageHybrid) persist() bool {
+	pending := []storageiface.EventRow{}
+	for _, row := range s.Memory.GetAllEventRows() {
+		if _, ok := s.state.copies[row.Id]; !ok {
+			pending = append(pending, row)
+		}
+	}
+	if len(pending) == 0 {
+		return true
+	}
+
+	payloads := []payload.Payload{}
+	for _, row := range pending {
+		payloads = append(payloads, row.Event)
+	}
+	if !addEventRows(s.Durable, payloads) {
+		return false
+	}
+
+	// Nothing has spilled, so the rows which are not yet copies are the new ones
+	copyIds := s.copyIds()
+	i := 0
+	for _, row := range s.Durable.GetAllEventRows() {
+		if !copyIds[row.Id] && i < len(pending) {
+			s.state.copies[pending[i].Id] = row.Id
+			i++
+		}
+	}
+	return true
+}
+
+// --- Option
+
+// OptionThreshold sets how many events are kept in memory before spilling to the durable storage.
+func OptionThreshold(threshold int) func(s *StorageHybrid) {
+	return func(s *StorageHybrid) { s.Threshold = threshold }
+}
+
+// OptionOrder sets the order in which events are returned.
+func OptionOrder(order storageiface.Order) func(s *StorageHybrid) {
+	return func(s *StorageHybrid) { s.Order = order }
+}
+
+// Close copies the events held only in memory to the durable storage and
+// closes the durable storage if it can be closed.
+//
+// Events are only held in memory alone while none have spilled, so the
+// copies are appended after every persisted event without reordering them.
+func (s StorageHybrid) Close() error {
+	s.state.lock.Lock()
+	defer s.state.lock.Unlock()
+
+	if s.state.durableCount == 0 && !s.persist() {
+		return errors.New("hybrid: failed to persist events held in memory")
+	}
+
+	if closer, ok := s.Durable.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// --- ADD
+
+// AddEventRow stores the event in memory, or in the durable storage if the
+// memory threshold has been reached or earlier events have already spilled.
+func (s StorageHybrid) AddEventRow(payload payload.Payload) bool {
+	s.state.lock.Lock()
+	defer s.state.lock.Unlock()
+
+	if s.state.durableCount == 0 && s.state.memoryCount < s.Threshold {
+		if s.Memory.AddEventRow(payload) {
+			s.state.memoryCount++
+			return true
+		}
+		return false
+	}
+
+	// The events in memory must reach the durable storage before any event spills past them
+	if s.state.durableCount == 0 && !s.persist() {
+		return false
+	}
+	if s.Durable.AddEventRow(payload) {
+		s.state.durableCount++
+		return true
+	}
+	return false
+}
+
+// --- DELETE
+
+// DeleteAllEventRows removes all events from memory and the durable storage.
+func (s StorageHybrid) DeleteAllEventRows() int64 {
+	s.state.lock.Lock()
+	defer s.state.lock.Unlock()
+
+	deleted := s.Memory.DeleteAllEventRows() + s.Durable.DeleteAllEventRows() - int64(len(s.state.copies))
+	s.state.memoryCount = 0
+	s.state.durableCount = 0
+	s.state.copies = map[int]int{}
+	return deleted
+}
+
+// DeleteEventRows removes the events with matching identifiers from wherever
+// they are stored, along with the durable copies of events in memory.
+func (s StorageHybrid) DeleteEventRows(ids []int) int64 {
+	s.state.lock.Lock()
+	defer s.state.lock.Unlock()
+
+	memoryIds := []int{}
+	durableIds := []int{}
+	copyIds := []int{}
+	for _, id := range ids {
+		if id < 0 {
+			durableIds = append(durableIds, -id)
+		} else {
+			memoryIds = append(memoryIds, id)
+			if copyId, ok := s.state.copies[id]; ok {
+				copyIds = append(copyIds, copyId)
+			}
+		}
+	}
+
+	var memoryDeleted, durableDeleted int64
+	if len(memoryIds) > 0 {
+		memoryDeleted = s.Memory.DeleteEventRows(memoryIds)
+		s.state.memoryCount -= int(memoryDeleted)
+	}
+	if len(copyIds) > 0 {
+		s.Durable.DeleteEventRows(copyIds)
+		for _, id := range memoryIds {
+			delete(s.state.copies, id)
+		}
+	}
+	if len(durableIds) > 0 {
+		durableDeleted = s.Durable.DeleteEventRows(durableIds)
+		s.state.durableCount -= int(durableDeleted)
+	}
+	return memoryDeleted + durableDeleted
+}
+
+// --- GET
+
+// GetAllEventRows returns all events in memory followed by all spilled
+// events, or the reverse if the storage returns the newest events first.
+func (s StorageHybrid) GetAllEventRows() []storageiface.EventRow {
+	s.state.lock.Lock()
+	defer s.state.lock.Unlock()
+
+	eventRows := s.getAllEventRows()
+	if s.Order == storageiface.ORDER_NEWEST_FIRST {
+		reverse(eventRows)
+	}
+	return eventRows
+}
+
+// GetEventRowsWithinRange returns up to eventRange events, taking them from
+// memory first and then from the durable storage.
+//
+// If the storage returns the newest events first they are taken from the
+// durable storage first, which requires reading every spilled event.
+func (s StorageHybrid) GetEventRowsWithinRange(eventRange int) []storageiface.EventRow {
+	s.state.lock.Lock()
+	defer s.state.lock.Unlock()
+
+	if s.Order == storageiface.ORDER_NEWEST_FIRST {
+		eventRows := s.getAllEventRows()
+		reverse(eventRows)
+		if len(eventRows) > eventRange {
+			eventRows = eventRows[:eventRange]
+		}
+		return eventRows
+	}
+
+	eventRows := s.Memory.GetEventRowsWithinRange(eventRange)
+	if remaining := eventRange - len(eventRows); remaining > 0 && s.state.durableCount > 0 {
+		// The copies of the events in memory are the oldest durable rows, so read past them
+		spilled := s.spilled(s.Durable.GetEventRowsWithinRange(remaining + len(s.state.copies)))
+		if len(spilled) > remaining {
+			spilled = spilled[:remaining]
+		}
+		eventRows = append(eventRows, spilled...)
+	}
+	return eventRows
+}
+
+// CountEventRows returns the number of events in memory and the durable storage.
+func (s StorageHybrid) CountEventRows() int {
+	s.state.lock.Lock()
+	defer s.state.lock.Unlock()
+
+	return s.state.memoryCount + s.state.durableCount
+}
+
+// --- Helpers
+
+// getAllEventRows returns all events in memory followed by all spilled events.
+func (s StorageHybrid) getAllEventRows() []storageiface.EventRow {
+	eventRows := s.Memory.GetAllEventRows()
+	if s.state.durableCount > 0 {
+		eventRows = append(eventRows, s.spilled(s.Durable.GetAllEventRows())...)
+	}
+	return eventRows
+}
+
+// spilled returns the durable rows which are not copies of events in memory,
+// with their ids negated.
+func (s StorageHybrid) spilled(eventRows []storageiface.EventRow) []storageiface.EventRow {
+	copyIds := s.copyIds()
+	spilled := []storageiface.EventRow{}
+	for _, row := range eventRows {
+		if !copyIds[row.Id] {
+			spilled = append(spilled, row)
+		}
+	}
+	return negateIds(spilled)
+}
+
+// copyIds returns the durable ids of the copies of events in memory.
+func (s StorageHybrid) copyIds() map[int]bool {
+	copyIds := map[int]bool{}
+	for _, id := range s.state.copies {
+		copyIds[id] = true
+	}
+	return copyIds
+}
+
+// lastMemoryId returns the id of the event most recently added to memory.
+func (s StorageHybrid) lastMemoryId() int {
+	return int(atomic.LoadUint32(s.Memory.Index))
+}
+
+// reverse reverses the rows in place.
+func reverse(eventRows []storageiface.EventRow) {
+	for i, j := 0, len(eventRows)-1; i < j; i, j = i+1, j-1 {
+		eventRows[i], eventRows[j] = eventRows[j], eventRows[i]
+	}
+}
+
+// addEventRows adds the payloads to the storage in one operation if it supports it.
+func addEventRows(storage storageiface.Storage, payloads []payload.Payload) bool {
+	if bulk, ok := storage.(storageiface.BulkStorage); ok {
+		return bulk.AddEventRows(payloads)
+	}
+	for _, p := range payloads {
+		if !storage.AddEventRow(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// negateIds marks rows as coming from the durable storage.
+func negateIds(eventRows []storageiface.EventRow) []storageiface.EventRow {
+	for i := range eventRows {
+		eventRows[i].Id = -eventRows[i].Id
+	}
+	return eventRows
+}
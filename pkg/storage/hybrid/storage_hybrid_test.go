@@ -0,0 +1,235 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package hybrid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/filelog"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storageiface"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storagetest"
+)
+
+// TestStorageHybridInit asserts behaviour of hybrid storage functions.
+func TestStorageHybridInit(t *testing.T) {
+	assert := assert.New(t)
+	durable := *filelog.Init(t.TempDir())
+	storage := *Init(durable)
+	defer storage.Close()
+
+	assert.NotNil(storage.Memory.Db)
+	assert.Equal(durable, storage.Durable)
+	assert.Equal(DEFAULT_THRESHOLD, storage.Threshold)
+
+	storage2 := *Init(durable, OptionThreshold(5))
+	assert.Equal(5, storage2.Threshold)
+
+	assert.PanicsWithValue("FATAL: Durable storage must be defined.", func() { Init(nil) })
+	assert.PanicsWithValue("FATAL: Threshold cannot be negative.", func() { Init(durable, OptionThreshold(-1)) })
+}
+
+// TestHybridAddGetDeletePayload asserts ability to add, delete and get payloads.
+func TestHybridAddGetDeletePayload(t *testing.T) {
+	assert := assert.New(t)
+	for _, threshold := range []int{0, 5, 100} {
+		storage := *Init(*filelog.Init(t.TempDir()), OptionThreshold(threshold))
+		assertDatabaseAddGetDeletePayload(assert, storage)
+		storage.Close()
+	}
+}
+
+// TestHybridConformance runs the storage conformance suite in each order on either side of the threshold.
+func TestHybridConformance(t *testing.T) {
+	for _, order := range []storageiface.Order{storageiface.ORDER_OLDEST_FIRST, storageiface.ORDER_NEWEST_FIRST} {
+		for _, threshold := range []int{0, 3, 1000} {
+			order, threshold := order, threshold
+			t.Run(order.String()+"/"+common.IntToString(threshold), func(t *testing.T) {
+				storagetest.Run(t, storagetest.Factory{
+					New: func(t *testing.T) storageiface.Storage {
+						return openTestStorage(t, t.TempDir(), OptionThreshold(threshold), OptionOrder(order))
+					},
+					Reopen: func(t *testing.T, storage storageiface.Storage) storageiface.Storage {
+						storage.(StorageHybrid).Close()
+						return openTestStorage(t, storage.(StorageHybrid).Durable.(filelog.StorageFileLog).Dir, OptionThreshold(threshold), OptionOrder(order))
+					},
+					Order: order,
+				})
+			})
+		}
+	}
+}
+
+// TestHybridSpill asserts that events beyond the threshold spill to the durable storage in order.
+func TestHybridSpill(t *testing.T) {
+	assert := assert.New(t)
+	durable := *filelog.Init(t.TempDir())
+	storage := *Init(durable, OptionThreshold(3))
+	defer storage.Close()
+
+	addPayloads(assert, storage, 0, 5)
+	assert.Equal(3, len(storage.Memory.GetAllEventRows()))
+	assert.Equal([]string{"0", "1", "2", "3", "4"}, eventIds(durable.GetAllEventRows()), "events in memory are copied before the first spill")
+	assert.Equal([]string{"0", "1", "2", "3", "4"}, eventIds(storage.GetAllEventRows()))
+	assert.Equal([]string{"0", "1", "2", "3"}, eventIds(storage.GetEventRowsWithinRange(4)))
+
+	eventRows := storage.GetAllEventRows()
+	assert.True(eventRows[2].Id > 0)
+	assert.True(eventRows[3].Id < 0)
+
+	// Once spilled, new events go to the durable storage until it has drained
+	assert.Equal(int64(2), storage.DeleteEventRows([]int{eventRows[0].Id, eventRows[1].Id}))
+	addPayloads(assert, storage, 5, 6)
+	assert.Equal(1, len(storage.Memory.GetAllEventRows()))
+	assert.Equal([]string{"2", "3", "4", "5"}, eventIds(storage.GetAllEventRows()))
+
+	eventRows = storage.GetAllEventRows()
+	assert.Equal(int64(3), storage.DeleteEventRows([]int{eventRows[1].Id, eventRows[2].Id, eventRows[3].Id, -1000}))
+	addPayloads(assert, storage, 6, 7)
+	assert.Equal(2, len(storage.Memory.GetAllEventRows()))
+	assert.Equal([]string{"2"}, eventIds(durable.GetAllEventRows()))
+	assert.Equal([]string{"2", "6"}, eventIds(storage.GetAllEventRows()))
+}
+
+// TestHybridCloseAndReload asserts that events in memory are persisted on Close and reloaded by Init.
+func TestHybridCloseAndReload(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	storage := *Init(*filelog.Init(dir), OptionThreshold(3))
+	addPayloads(assert, storage, 0, 5)
+	assert.Nil(storage.Close())
+
+	durable := *filelog.Init(dir)
+	assert.Equal([]string{"0", "1", "2", "3", "4"}, eventIds(durable.GetAllEventRows()))
+
+	storage = *Init(durable, OptionThreshold(3))
+	defer storage.Close()
+	assert.Equal(3, len(storage.Memory.GetAllEventRows()))
+	assert.Equal([]string{"0", "1", "2", "3", "4"}, eventIds(durable.GetAllEventRows()), "reloaded events keep their durable copy")
+	assert.Equal([]string{"0", "1", "2", "3", "4"}, eventIds(storage.GetAllEventRows()))
+	assert.Equal([]string{"0", "1", "2", "3"}, eventIds(storage.GetEventRowsWithinRange(4)))
+	addPayloads(assert, storage, 5, 6)
+	assert.Equal([]string{"0", "1", "2", "3", "4", "5"}, eventIds(storage.GetAllEventRows()))
+
+	// The copies are deleted along with the events they belong to
+	eventRows := storage.GetEventRowsWithinRange(4)
+	assert.Equal(int64(4), storage.DeleteEventRows(rowIds(eventRows)))
+	assert.Equal([]string{"4", "5"}, eventIds(durable.GetAllEventRows()))
+	assert.Equal([]string{"4", "5"}, eventIds(storage.GetAllEventRows()))
+}
+
+// TestHybridCrashAfterReload asserts that reloaded events survive the process
+// stopping without Close.
+func TestHybridCrashAfterReload(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	storage := *Init(*filelog.Init(dir), OptionThreshold(3))
+	addPayloads(assert, storage, 0, 5)
+	assert.Nil(storage.Close())
+
+	// Only the durable storage is closed, as the process would on exit
+	durable := *filelog.Init(dir)
+	storage = *Init(durable, OptionThreshold(3))
+	assert.Equal(3, len(storage.Memory.GetAllEventRows()))
+	assert.Nil(durable.Close())
+
+	durable = *filelog.Init(dir)
+	defer durable.Close()
+	assert.Equal([]string{"0", "1", "2", "3", "4"}, eventIds(durable.GetAllEventRows()))
+}
+
+// TestHybridCloseKeepsSpilledEvents asserts that Close only writes the events
+// held solely in memory, leaving spilled events where they are.
+func TestHybridCloseKeepsSpilledEvents(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	durable := *filelog.Init(dir)
+	storage := *Init(durable, OptionThreshold(3))
+	addPayloads(assert, storage, 0, 5)
+	persisted := durable.GetAllEventRows()
+	assert.Nil(storage.Close())
+
+	durable = *filelog.Init(dir)
+	defer durable.Close()
+	assert.Equal(persisted, durable.GetAllEventRows())
+}
+
+// --- Common
+
+func assertDatabaseAddGetDeletePayload(assert *assert.Assertions, storage storageiface.Storage) {
+	storage.DeleteAllEventRows()
+	payload := *payload.Init()
+	payload.Add("e", common.NewString("pv"))
+
+	// Add a Payload
+	assert.True(storage.AddEventRow(payload))
+	eventRows := storage.GetAllEventRows()
+	assert.Equal(1, len(eventRows))
+	assert.Equal("pv", eventRows[0].Event.Get()["e"])
+
+	// Delete the added row
+	assert.Equal(int64(1), storage.DeleteEventRows([]int{eventRows[0].Id}))
+	eventRows = storage.GetAllEventRows()
+	assert.Equal(0, len(eventRows))
+
+	// Add 20 payloads
+	for i := 0; i < 20; i++ {
+		result := storage.AddEventRow(payload)
+		assert.True(result)
+	}
+
+	eventRows = storage.GetEventRowsWithinRange(10)
+	assert.Equal(10, len(eventRows))
+	eventRows = storage.GetEventRowsWithinRange(30)
+	assert.Equal(20, len(eventRows))
+	eventRows = storage.GetAllEventRows()
+	assert.Equal(20, len(eventRows))
+	assert.Equal(int64(20), storage.DeleteAllEventRows())
+	eventRows = storage.GetAllEventRows()
+	assert.Equal(0, len(eventRows))
+	assert.Equal(int64(0), storage.DeleteEventRows([]int{}))
+}
+
+func addPayloads(assert *assert.Assertions, storage storageiface.Storage, from int, to int) {
+	for i := from; i < to; i++ {
+		p := *payload.Init()
+		p.Add("eid", common.NewString(common.IntToString(i)))
+		assert.True(storage.AddEventRow(p))
+	}
+}
+
+func rowIds(eventRows []storageiface.EventRow) []int {
+	ids := []int{}
+	for _, row := range eventRows {
+		ids = append(ids, row.Id)
+	}
+	return ids
+}
+
+func eventIds(eventRows []storageiface.EventRow) []string {
+	ids := []string{}
+	for _, row := range eventRows {
+		ids = append(ids, row.Event.Get()["eid"])
+	}
+	return ids
+}
+
+func openTestStorage(t *testing.T, dir string, options ...func(*StorageHybrid)) StorageHybrid {
+	storage := *Init(*filelog.Init(dir), options...)
+	t.Cleanup(func() { storage.Close() })
+	return storage
+}
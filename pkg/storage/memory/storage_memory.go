@@ -26,6 +26,7 @@ import (
 type StorageMemory struct {
 	Db    *memdb.MemDB
 	Index *uint32
+	Order storageiface.Order
 }
 
 type RawEventRowUint struct {
@@ -33,7 +34,7 @@ type RawEventRowUint struct {
 	event []byte
 }
 
-func Init() *StorageMemory {
+func Init(options ...func(*StorageMemory)) *StorageMemory {
 	schema := &memdb.DBSchema{
 		Tables: map[string]*memdb.TableSchema{
 			storageiface.DB_TABLE_NAME: {
@@ -56,7 +57,21 @@ func Init() *StorageMemory {
 	db, err := memdb.NewMemDB(schema)
 	common.CheckErr(err)
 
-	return &StorageMemory{Db: db, Index: new(uint32)}
+	s := &StorageMemory{Db: db, Index: new(uint32)}
+
+	// Option parameters
+	for _, op := range options {
+		op(s)
+	}
+
+	return s
+}
+
+// --- Option
+
+// OptionOrder sets the order in which events are returned.
+func OptionOrder(order storageiface.Order) func(s *StorageMemory) {
+	return func(s *StorageMemory) { s.Order = order }
 }
 
 // AddEventRow adds a new event to the database.
@@ -102,23 +117,41 @@ func (s StorageMemory) DeleteEventRows(ids []int) int64 {
 	return int64(deleteCount)
 }
 
-// GetAllEventRows returns all rows within the memory store
+// GetAllEventRows returns all rows within the memory store in the configured Order
 func (s StorageMemory) GetAllEventRows() []storageiface.EventRow {
 	eventItems := []storageiface.EventRow{}
 	txn := s.Db.Txn(false)
 	defer txn.Abort()
 
-	result, err := txn.Get(storageiface.DB_TABLE_NAME, storageiface.DB_COLUMN_ID)
+	get := txn.Get
+	if s.Order == storageiface.ORDER_NEWEST_FIRST {
+		get = txn.GetReverse
+	}
+	result, err := get(storageiface.DB_TABLE_NAME, storageiface.DB_COLUMN_ID)
 	common.CheckErr(err)
 	for row := result.Next(); row != nil; row = result.Next() {
 		item := row.(*RawEventRowUint)
 		eventMap, _ := common.DeserializeMap(item.event)
-		eventItems = append(eventItems, storageiface.EventRow{int(item.id), payload.Payload{eventMap}})
+		eventItems = append(eventItems, storageiface.EventRow{Id: int(item.id), Event: payload.Payload{Pairs: eventMap}})
 	}
 
 	return eventItems
 }
 
+// CountEventRows returns the number of rows within the memory store without deserialising them
+func (s StorageMemory) CountEventRows() int {
+	txn := s.Db.Txn(false)
+	defer txn.Abort()
+
+	result, err := txn.Get(storageiface.DB_TABLE_NAME, storageiface.DB_COLUMN_ID)
+	common.CheckErr(err)
+	count := 0
+	for row := result.Next(); row != nil; row = result.Next() {
+		count++
+	}
+	return count
+}
+
 // GetEventRowsWithinRange returns all available events or a maximal slice
 func (s StorageMemory) GetEventRowsWithinRange(eventRange int) []storageiface.EventRow {
 	eventItems := s.GetAllEventRows()
@@ -21,6 +21,7 @@ import (
 	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
 	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
 	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storageiface"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storagetest"
 )
 
 // TestStorageMemoryInit asserts behaviour of memdb storage functions.
@@ -50,6 +51,19 @@ func TestMemoryAddGetDeletePayload_WithIndexOverflow(t *testing.T) {
 	assertDatabaseAddGetDeletePayload(assert, storage)
 }
 
+// TestMemoryConformance runs the storage conformance suite in each order.
+func TestMemoryConformance(t *testing.T) {
+	for _, order := range []storageiface.Order{storageiface.ORDER_OLDEST_FIRST, storageiface.ORDER_NEWEST_FIRST} {
+		order := order
+		t.Run(order.String(), func(t *testing.T) {
+			storagetest.Run(t, storagetest.Factory{
+				New:   func(t *testing.T) storageiface.Storage { return *Init(OptionOrder(order)) },
+				Order: order,
+			})
+		})
+	}
+}
+
 // TestMemoryAddPayload_WithUniqueIndexCollision asserts behaviour when we hit a collision in the database which can only happen
 // if we rollover the Index and have not yet deleted the events (creating faster than sending).
 func TestMemoryAddPayload_WithUniqueIndexCollision(t *testing.T) {
@@ -0,0 +1,260 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package ringbuffer
+
+import (
+	"sync"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storageiface"
+)
+
+const (
+	DEFAULT_INITIAL_CAPACITY = 64
+)
+
+// StorageRingBuffer is an in-memory queue of events held in a growable ring buffer.
+//
+// Events have contiguous ids, so the slot holding an event is found from its
+// id directly: adding an event is amortised O(1), reading a batch is
+// O(batch) and deleting a batch is O(batch). Payloads are copied rather than
+// serialised on the way in and out.
+type StorageRingBuffer struct {
+	InitialCapacity int
+	Order           storageiface.Order
+	state           *ringState
+}
+
+// ringState is the mutable state shared by every copy of the storage.
+type ringState struct {
+	lock            sync.Mutex
+	slots           []slot
+	initialCapacity int
+	head            int // Index of the oldest slot
+	headId          int // Id of the event in the oldest slot
+	size            int // Number of slots between the oldest and newest event, including deleted ones
+	live            int // Number of events which have not been deleted
+}
+
+type slot struct {
+	pairs   map[string]string
+	deleted bool
+}
+
+// Init returns a new empty ring buffer.
+func Init(options ...func(*StorageRingBuffer)) *StorageRingBuffer {
+	s := &StorageRingBuffer{}
+
+	// Set Defaults
+	s.InitialCapacity = DEFAULT_INITIAL_CAPACITY
+
+	// Option parameters
+	for _, op := range options {
+		op(s)
+	}
+
+	if s.InitialCapacity < 1 {
+		panic("FATAL: InitialCapacity must be at least 1.")
+	}
+	s.state = &ringState{
+		slots:           make([]slot, s.InitialCapacity),
+		initialCapacity: s.InitialCapacity,
+		headId:          1,
+	}
+
+	return s
+}
+
+// --- Option
+
+// OptionInitialCapacity sets the number of events the buffer can hold before it first grows.
+func OptionInitialCapacity(capacity int) func(s *StorageRingBuffer) {
+	return func(s *StorageRingBuffer) { s.InitialCapacity = capacity }
+}
+
+// OptionOrder sets the order in which events are returned.
+func OptionOrder(order storageiface.Order) func(s *StorageRingBuffer) {
+	return func(s *StorageRingBuffer) { s.Order = order }
+}
+
+// --- ADD
+
+// AddEventRow appends a copy of the event to the end of the buffer.
+func (s StorageRingBuffer) AddEventRow(payload payload.Payload) bool {
+	s.state.lock.Lock()
+	defer s.state.lock.Unlock()
+
+	s.state.push(payload.Get())
+	return true
+}
+
+// AddEventRows appends copies of a batch of events to the end of the buffer.
+func (s StorageRingBuffer) AddEventRows(payloads []payload.Payload) bool {
+	s.state.lock.Lock()
+	defer s.state.lock.Unlock()
+
+	for _, p := range payloads {
+		s.state.push(p.Get())
+	}
+	return true
+}
+
+// --- DELETE
+
+// DeleteAllEventRows removes every event from the buffer. Ids are not re-used.
+func (s StorageRingBuffer) DeleteAllEventRows() int64 {
+	s.state.lock.Lock()
+	defer s.state.lock.Unlock()
+
+	deleted := s.state.live
+	s.state.headId += s.state.size
+	s.state.head = 0
+	s.state.size = 0
+	s.state.live = 0
+	s.state.slots = make([]slot, s.state.initialCapacity)
+
+	return int64(deleted)
+}
+
+// DeleteEventRows removes the events with matching identifiers.
+func (s StorageRingBuffer) DeleteEventRows(ids []int) int64 {
+	s.state.lock.Lock()
+	defer s.state.lock.Unlock()
+
+	var deleted int64
+	for _, id := range ids {
+		if s.state.delete(id) {
+			deleted++
+		}
+	}
+	s.state.advance()
+	s.state.shrink()
+
+	return deleted
+}
+
+// --- GET
+
+// GetAllEventRows returns copies of all events in the buffer in the configured Order.
+func (s StorageRingBuffer) GetAllEventRows() []storageiface.EventRow {
+	s.state.lock.Lock()
+	defer s.state.lock.Unlock()
+
+	return s.state.read(s.state.live, s.Order)
+}
+
+// CountEventRows returns the number of events in the buffer.
+func (s StorageRingBuffer) CountEventRows() int {
+	s.state.lock.Lock()
+	defer s.state.lock.Unlock()
+
+	return s.state.live
+}
+
+// GetEventRowsWithinRange returns copies of up to eventRange events from the buffer, taken in the configured Order.
+func (s StorageRingBuffer) GetEventRowsWithinRange(eventRange int) []storageiface.EventRow {
+	s.state.lock.Lock()
+	defer s.state.lock.Unlock()
+
+	return s.state.read(eventRange, s.Order)
+}
+
+// --- Helpers
+
+// push appends a copy of the pairs, doubling the capacity if the buffer is full.
+func (r *ringState) push(pairs map[string]string) {
+	if r.size == len(r.slots) {
+		r.resize(2 * len(r.slots))
+	}
+	r.slots[r.index(r.size)] = slot{pairs: copyPairs(pairs)}
+	r.size++
+	r.live++
+}
+
+// delete marks the event with the id as deleted, returning false if there is no such event.
+func (r *ringState) delete(id int) bool {
+	offset := id - r.headId
+	if offset < 0 || offset >= r.size {
+		return false
+	}
+	s := &r.slots[r.index(offset)]
+	if s.deleted {
+		return false
+	}
+	s.deleted = true
+	s.pairs = nil
+	r.live--
+	return true
+}
+
+// advance moves the head past deleted slots so that they can be re-used.
+func (r *ringState) advance() {
+	for r.size > 0 && r.slots[r.head].deleted {
+		r.slots[r.head] = slot{}
+		r.head = r.index(1)
+		r.headId++
+		r.size--
+	}
+}
+
+// shrink halves the capacity once the buffer is less than a quarter full.
+func (r *ringState) shrink() {
+	if len(r.slots) > r.initialCapacity && r.size < len(r.slots)/4 {
+		capacity := len(r.slots) / 2
+		if capacity < r.initialCapacity {
+			capacity = r.initialCapacity
+		}
+		r.resize(capacity)
+	}
+}
+
+// resize copies the slots in use into a new buffer with the given capacity.
+func (r *ringState) resize(capacity int) {
+	slots := make([]slot, capacity)
+	for i := 0; i < r.size; i++ {
+		slots[i] = r.slots[r.index(i)]
+	}
+	r.slots = slots
+	r.head = 0
+}
+
+// read returns copies of up to limit events which have not been deleted, taken in the order.
+func (r *ringState) read(limit int, order storageiface.Order) []storageiface.EventRow {
+	eventItems := []storageiface.EventRow{}
+	for n := 0; n < r.size && len(eventItems) < limit; n++ {
+		i := n
+		if order == storageiface.ORDER_NEWEST_FIRST {
+			i = r.size - 1 - n
+		}
+		s := r.slots[r.index(i)]
+		if !s.deleted {
+			eventItems = append(eventItems, storageiface.EventRow{Id: r.headId + i, Event: payload.Payload{Pairs: copyPairs(s.pairs)}})
+		}
+	}
+	return eventItems
+}
+
+// index returns the slot index of the event offset from the head.
+func (r *ringState) index(offset int) int {
+	return (r.head + offset) % len(r.slots)
+}
+
+// copyPairs returns a copy of the map so that stored events cannot be modified by callers.
+func copyPairs(pairs map[string]string) map[string]string {
+	copied := make(map[string]string, len(pairs))
+	for key, value := range pairs {
+		copied[key] = value
+	}
+	return copied
+}
@@ -0,0 +1,232 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package ringbuffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/memory"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storageiface"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storagetest"
+)
+
+// TestStorageRingBufferInit asserts behaviour of ring buffer storage functions.
+func TestStorageRingBufferInit(t *testing.T) {
+	assert := assert.New(t)
+	storage := *Init()
+	assert.Equal(DEFAULT_INITIAL_CAPACITY, storage.InitialCapacity)
+	assert.Equal(DEFAULT_INITIAL_CAPACITY, len(storage.state.slots))
+
+	storage = *Init(OptionInitialCapacity(2))
+	assert.Equal(2, len(storage.state.slots))
+
+	assert.PanicsWithValue("FATAL: InitialCapacity must be at least 1.", func() { Init(OptionInitialCapacity(0)) })
+}
+
+// TestRingBufferAddGetDeletePayload asserts ability to add, delete and get payloads.
+func TestRingBufferAddGetDeletePayload(t *testing.T) {
+	assert := assert.New(t)
+	assertDatabaseAddGetDeletePayload(assert, *Init())
+	assertDatabaseAddGetDeletePayload(assert, *Init(OptionInitialCapacity(1)))
+}
+
+// TestRingBufferConformance runs the storage conformance suite in each order.
+func TestRingBufferConformance(t *testing.T) {
+	for _, order := range []storageiface.Order{storageiface.ORDER_OLDEST_FIRST, storageiface.ORDER_NEWEST_FIRST} {
+		order := order
+		t.Run(order.String(), func(t *testing.T) {
+			storagetest.Run(t, storagetest.Factory{
+				New:   func(t *testing.T) storageiface.Storage { return *Init(OptionInitialCapacity(2), OptionOrder(order)) },
+				Order: order,
+			})
+		})
+	}
+}
+
+// TestRingBufferWrapAround asserts ordering and ids as the buffer wraps, grows and shrinks.
+func TestRingBufferWrapAround(t *testing.T) {
+	assert := assert.New(t)
+	storage := *Init(OptionInitialCapacity(4))
+
+	assert.True(storage.AddEventRows(newPayloads(0, 3)))
+	assert.Equal(int64(2), storage.DeleteEventRows([]int{1, 2}))
+	assert.Equal(2, storage.state.head)
+
+	// Wrap around the end of the buffer without growing
+	assert.True(storage.AddEventRows(newPayloads(3, 6)))
+	assert.Equal(4, len(storage.state.slots))
+	assert.Equal([]int{3, 4, 5, 6}, rowIds(storage.GetAllEventRows()))
+	assert.Equal([]string{"2", "3", "4", "5"}, eventIds(storage.GetAllEventRows()))
+
+	// Grow while wrapped
+	assert.True(storage.AddEventRow(newPayloads(6, 7)[0]))
+	assert.Equal(8, len(storage.state.slots))
+	assert.Equal([]int{3, 4, 5, 6, 7}, rowIds(storage.GetAllEventRows()))
+
+	// Holes in the middle are skipped but do not move the head
+	assert.Equal(int64(2), storage.DeleteEventRows([]int{4, 6, 6, 100, 0}))
+	assert.Equal([]int{3, 5}, rowIds(storage.GetEventRowsWithinRange(2)))
+	assert.Equal(3, storage.state.headId)
+
+	// Deleting the head releases the holes behind it and shrinks the buffer
+	assert.Equal(int64(2), storage.DeleteEventRows([]int{3, 5}))
+	assert.Equal(7, storage.state.headId)
+	assert.Equal(1, storage.state.size)
+	assert.Equal(4, len(storage.state.slots))
+	assert.Equal([]string{"6"}, eventIds(storage.GetAllEventRows()))
+
+	// Ids are not re-used after deleting everything
+	assert.Equal(int64(1), storage.DeleteAllEventRows())
+	assert.True(storage.AddEventRow(newPayloads(7, 8)[0]))
+	assert.Equal([]int{8}, rowIds(storage.GetAllEventRows()))
+}
+
+// TestRingBufferCopiesPayloads asserts that stored events cannot be modified through added or returned payloads.
+func TestRingBufferCopiesPayloads(t *testing.T) {
+	assert := assert.New(t)
+	storage := *Init()
+
+	p := *payload.Init()
+	p.Add("e", common.NewString("pv"))
+	assert.True(storage.AddEventRow(p))
+	p.Add("e", common.NewString("se"))
+
+	eventRows := storage.GetAllEventRows()
+	assert.Equal("pv", eventRows[0].Event.Get()["e"])
+	eventRows[0].Event.Add("stm", common.NewString("1"))
+	assert.Equal(map[string]string{"e": "pv"}, storage.GetAllEventRows()[0].Event.Get())
+}
+
+// --- Benchmarks
+
+// BenchmarkRingBufferGetEventRowsWithinRange measures reading a batch of 500 from a backlog of 100000 events.
+func BenchmarkRingBufferGetEventRowsWithinRange(b *testing.B) {
+	benchmarkGetEventRowsWithinRange(b, *Init())
+}
+
+// BenchmarkMemoryGetEventRowsWithinRange measures the same read against memory.StorageMemory.
+func BenchmarkMemoryGetEventRowsWithinRange(b *testing.B) {
+	benchmarkGetEventRowsWithinRange(b, *memory.Init())
+}
+
+// BenchmarkRingBufferSendCycle measures adding, reading and deleting a batch of 500 events.
+func BenchmarkRingBufferSendCycle(b *testing.B) {
+	benchmarkSendCycle(b, *Init())
+}
+
+// BenchmarkMemorySendCycle measures the same cycle against memory.StorageMemory.
+func BenchmarkMemorySendCycle(b *testing.B) {
+	benchmarkSendCycle(b, *memory.Init())
+}
+
+func benchmarkGetEventRowsWithinRange(b *testing.B, storage storageiface.Storage) {
+	p := benchmarkPayload()
+	for i := 0; i < 100000; i++ {
+		storage.AddEventRow(p)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		storage.GetEventRowsWithinRange(500)
+	}
+}
+
+func benchmarkSendCycle(b *testing.B, storage storageiface.Storage) {
+	p := benchmarkPayload()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 500; j++ {
+			storage.AddEventRow(p)
+		}
+		storage.DeleteEventRows(rowIds(storage.GetEventRowsWithinRange(500)))
+	}
+}
+
+func benchmarkPayload() payload.Payload {
+	payload := *payload.Init()
+	payload.Add("e", common.NewString("pv"))
+	payload.Add("url", common.NewString("https://acme.com/some/page"))
+	payload.Add("eid", common.NewString(common.GetUUID()))
+	payload.Add("dtm", common.NewString(common.GetTimestampString()))
+	return payload
+}
+
+// --- Common
+
+func assertDatabaseAddGetDeletePayload(assert *assert.Assertions, storage storageiface.Storage) {
+	storage.DeleteAllEventRows()
+	payload := *payload.Init()
+	payload.Add("e", common.NewString("pv"))
+
+	// Add a Payload
+	assert.True(storage.AddEventRow(payload))
+	eventRows := storage.GetAllEventRows()
+	assert.Equal(1, len(eventRows))
+	assert.Equal("pv", eventRows[0].Event.Get()["e"])
+
+	// Delete the added row
+	assert.Equal(int64(1), storage.DeleteEventRows([]int{eventRows[0].Id}))
+	eventRows = storage.GetAllEventRows()
+	assert.Equal(0, len(eventRows))
+
+	// Add 20 payloads
+	for i := 0; i < 20; i++ {
+		result := storage.AddEventRow(payload)
+		assert.True(result)
+	}
+
+	eventRows = storage.GetEventRowsWithinRange(10)
+	assert.Equal(10, len(eventRows))
+	eventRows = storage.GetEventRowsWithinRange(30)
+	assert.Equal(20, len(eventRows))
+	eventRows = storage.GetAllEventRows()
+	assert.Equal(20, len(eventRows))
+	assert.Equal(int64(20), storage.DeleteAllEventRows())
+	eventRows = storage.GetAllEventRows()
+	assert.Equal(0, len(eventRows))
+	assert.Equal(int64(0), storage.DeleteEventRows([]int{}))
+}
+
+func newPayloads(from int, to int) []payload.Payload {
+	payloads := []payload.Payload{}
+	for i := from; i < to; i++ {
+		p := *payload.Init()
+		p.Add("eid", common.NewString(common.IntToString(i)))
+		payloads = append(payloads, p)
+	}
+	return payloads
+}
+
+func rowIds(eventRows []storageiface.EventRow) []int {
+	ids := []int{}
+	for _, row := range eventRows {
+		ids = append(ids, row.Id)
+	}
+	return ids
+}
+
+func eventIds(eventRows []storageiface.EventRow) []string {
+	ids := []string{}
+	for _, row := range eventRows {
+		ids = append(ids, row.Event.Get()["eid"])
+	}
+	return ids
+}
@@ -0,0 +1,115 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package sqldb
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect describes the SQL syntax which differs between database engines.
+type Dialect interface {
+	// Placeholder returns the bind parameter for the 1-based index of an argument.
+	Placeholder(index int) string
+
+	// IdColumn returns the definition of an auto-incrementing integer primary key.
+	IdColumn() string
+
+	// BlobColumn returns the type used for encoded events.
+	BlobColumn() string
+
+	// TextColumn returns the type used for short strings, which must be usable as a primary key.
+	TextColumn() string
+
+	// Upsert returns a statement which inserts a row or replaces the values of
+	// an existing row with the same key.
+	Upsert(table string, key string, columns []string) string
+
+	// Limit returns the clause appended to an ordered SELECT to restrict the number of rows.
+	Limit(placeholder string) string
+}
+
+var (
+	SQLite   Dialect = sqliteDialect{}
+	Postgres Dialect = postgresDialect{}
+	MySQL    Dialect = mysqlDialect{}
+)
+
+// --- SQLite
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Placeholder(index int) string { return "?" }
+func (sqliteDialect) IdColumn() string             { return "INTEGER PRIMARY KEY" }
+func (sqliteDialect) BlobColumn() string           { return "BLOB" }
+func (sqliteDialect) TextColumn() string           { return "VARCHAR(255)" }
+func (sqliteDialect) Limit(placeholder string) string {
+	return " LIMIT " + placeholder
+}
+
+func (d sqliteDialect) Upsert(table string, key string, columns []string) string {
+	return "INSERT OR REPLACE INTO " + insertColumns(d, table, key, columns) + ";"
+}
+
+// --- Postgres
+
+type postgresDialect struct{}
+
+func (postgresDialect) Placeholder(index int) string { return "$" + strconv.Itoa(index) }
+func (postgresDialect) IdColumn() string             { return "BIGSERIAL PRIMARY KEY" }
+func (postgresDialect) BlobColumn() string           { return "BYTEA" }
+func (postgresDialect) TextColumn() string           { return "VARCHAR(255)" }
+func (postgresDialect) Limit(placeholder string) string {
+	return " LIMIT " + placeholder
+}
+
+func (d postgresDialect) Upsert(table string, key string, columns []string) string {
+	updates := []string{}
+	for _, column := range columns {
+		updates = append(updates, column+" = EXCLUDED."+column)
+	}
+	return "INSERT INTO " + insertColumns(d, table, key, columns) + " " +
+		"ON CONFLICT (" + key + ") DO UPDATE SET " + strings.Join(updates, ", ") + ";"
+}
+
+// --- MySQL
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Placeholder(index int) string { return "?" }
+func (mysqlDialect) IdColumn() string             { return "BIGINT AUTO_INCREMENT PRIMARY KEY" }
+func (mysqlDialect) BlobColumn() string           { return "LONGBLOB" }
+func (mysqlDialect) TextColumn() string           { return "VARCHAR(255)" }
+func (mysqlDialect) Limit(placeholder string) string {
+	return " LIMIT " + placeholder
+}
+
+func (d mysqlDialect) Upsert(table string, key string, columns []string) string {
+	updates := []string{}
+	for _, column := range columns {
+		updates = append(updates, column+" = VALUES("+column+")")
+	}
+	return "INSERT INTO " + insertColumns(d, table, key, columns) + " " +
+		"ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ") + ";"
+}
+
+// insertColumns builds the "table(columns) VALUES(placeholders)" part of an insert statement.
+func insertColumns(d Dialect, table string, key string, columns []string) string {
+	all := append([]string{key}, columns...)
+	placeholders := []string{}
+	for i := range all {
+		placeholders = append(placeholders, d.Placeholder(i+1))
+	}
+	return table + "(" + strings.Join(all, ", ") + ") VALUES(" + strings.Join(placeholders, ", ") + ")"
+}
@@ -0,0 +1,48 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package sqldb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDialectSQLite asserts the SQL generated for SQLite.
+func TestDialectSQLite(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("?", SQLite.Placeholder(2))
+	assert.Equal(" LIMIT ?", SQLite.Limit(SQLite.Placeholder(1)))
+	assert.Equal("INSERT OR REPLACE INTO versions(name, version, updated) VALUES(?, ?, ?);", SQLite.Upsert("versions", "name", []string{"version", "updated"}))
+	assert.Equal("CREATE TABLE IF NOT EXISTS events(id INTEGER PRIMARY KEY, event BLOB);", migrations[0](SQLite, "events"))
+}
+
+// TestDialectPostgres asserts the SQL generated for Postgres.
+func TestDialectPostgres(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("$2", Postgres.Placeholder(2))
+	assert.Equal(" LIMIT $1", Postgres.Limit(Postgres.Placeholder(1)))
+	assert.Equal("INSERT INTO versions(name, version, updated) VALUES($1, $2, $3) ON CONFLICT (name) DO UPDATE SET version = EXCLUDED.version, updated = EXCLUDED.updated;", Postgres.Upsert("versions", "name", []string{"version", "updated"}))
+	assert.Equal("CREATE TABLE IF NOT EXISTS events(id BIGSERIAL PRIMARY KEY, event BYTEA);", migrations[0](Postgres, "events"))
+	assert.Equal("ALTER TABLE events ADD COLUMN encoding VARCHAR(255) NOT NULL DEFAULT 'gob';", migrations[1](Postgres, "events"))
+}
+
+// TestDialectMySQL asserts the SQL generated for MySQL.
+func TestDialectMySQL(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("?", MySQL.Placeholder(2))
+	assert.Equal(" LIMIT ?", MySQL.Limit(MySQL.Placeholder(1)))
+	assert.Equal("INSERT INTO versions(name, version, updated) VALUES(?, ?, ?) ON DUPLICATE KEY UPDATE version = VALUES(version), updated = VALUES(updated);", MySQL.Upsert("versions", "name", []string{"version", "updated"}))
+	assert.Equal("CREATE TABLE IF NOT EXISTS events(id BIGINT AUTO_INCREMENT PRIMARY KEY, event LONGBLOB);", migrations[0](MySQL, "events"))
+}
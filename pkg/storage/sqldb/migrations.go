@@ -0,0 +1,97 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package sqldb
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/codec"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storageiface"
+)
+
+// migrations bring an events table up to the latest schema version. They are
+// also applied by the sqlite3 storage, which uses the SQLite dialect.
+//
+// The schema version of a table is the number of migrations which have been
+// applied to it, as recorded in the version table. Migrations must never be
+// edited or re-ordered once released; new ones are appended to the end.
+var migrations = []func(d Dialect, table string) string{
+	// 1: Events table as created by versions of the tracker before schema versioning
+	func(d Dialect, table string) string {
+		return "CREATE TABLE IF NOT EXISTS " + table + "(" +
+			storageiface.DB_COLUMN_ID + " " + d.IdColumn() + ", " +
+			storageiface.DB_COLUMN_EVENT + " " + d.BlobColumn() +
+			");"
+	},
+	// 2: Record the codec used to encode each row; existing rows were all written with gob
+	func(d Dialect, table string) string {
+		return "ALTER TABLE " + table + " " +
+			"ADD COLUMN " + storageiface.DB_COLUMN_ENCODING + " " + d.TextColumn() + " NOT NULL DEFAULT '" + codec.NAME_GOB + "';"
+	},
+}
+
+// SchemaVersion returns the latest schema version of the events table.
+func SchemaVersion() int {
+	return len(migrations)
+}
+
+// migrate applies every outstanding migration to the table within a single transaction.
+//
+// Some engines, such as MySQL, commit schema changes implicitly so a failed
+// migration may leave earlier ones applied; the recorded version is only
+// updated once every migration has succeeded.
+func migrate(db *sql.DB, d Dialect, table string) error {
+	_, err := db.Exec(
+		"CREATE TABLE IF NOT EXISTS " + storageiface.DB_VERSION_TABLE_NAME + "(" +
+			storageiface.DB_VERSION_COLUMN_TABLE + " " + d.TextColumn() + " PRIMARY KEY, " +
+			storageiface.DB_VERSION_COLUMN_VERSION + " INTEGER NOT NULL" +
+			");")
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	version := 0
+	err = tx.QueryRow(
+		"SELECT "+storageiface.DB_VERSION_COLUMN_VERSION+" FROM "+storageiface.DB_VERSION_TABLE_NAME+" "+
+			"WHERE "+storageiface.DB_VERSION_COLUMN_TABLE+" = "+d.Placeholder(1)+";", table).Scan(&version)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if version > len(migrations) {
+		return errors.New("FATAL: Table " + table + " is at schema version " + strconv.Itoa(version) +
+			" which is newer than the latest supported version " + strconv.Itoa(len(migrations)) + ".")
+	}
+
+	for ; version < len(migrations); version++ {
+		if _, err := tx.Exec(migrations[version](d, table)); err != nil {
+			return err
+		}
+	}
+
+	upsert := d.Upsert(storageiface.DB_VERSION_TABLE_NAME, storageiface.DB_VERSION_COLUMN_TABLE, []string{storageiface.DB_VERSION_COLUMN_VERSION})
+	if _, err := tx.Exec(upsert, table, version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,318 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package sqldb
+
+import (
+	"database/sql"
+	"log"
+	"regexp"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/codec"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storageiface"
+)
+
+// StorageSQL stores events in a table of any database supported by database/sql.
+//
+// The sqlite3 storage is built on it, so rows are laid out and encoded in the
+// same way. The database connection is owned by the caller and is not closed
+// by Close.
+type StorageSQL struct {
+	Db        *sql.DB
+	Dialect   Dialect
+	TableName string
+	Codec     codec.Codec
+	Order     storageiface.Order
+	stmts     *statements
+}
+
+type RawEventRow struct {
+	id       int
+	event    []byte
+	encoding string
+}
+
+// statements holds the prepared statements which are re-used for
+// the lifetime of the storage.
+type statements struct {
+	add       *sql.Stmt
+	delete    *sql.Stmt
+	deleteAll *sql.Stmt
+	getAll    *sql.Stmt
+	getRange  *sql.Stmt
+	count     *sql.Stmt
+}
+
+var validTableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Init migrates the events table within an existing database to the latest
+// schema version and prepares all of the statements used by the storage.
+func Init(db *sql.DB, dialect Dialect, options ...func(*StorageSQL)) *StorageSQL {
+	s := &StorageSQL{Db: db, Dialect: dialect}
+
+	// Set Defaults
+	s.TableName = storageiface.DB_TABLE_NAME
+	s.Codec = codec.JSON
+
+	// Option parameters
+	for _, op := range options {
+		op(s)
+	}
+
+	if s.Db == nil {
+		panic("FATAL: Db cannot be nil.")
+	}
+	if s.Dialect == nil {
+		panic("FATAL: Dialect cannot be nil.")
+	}
+	if !validTableName.MatchString(s.TableName) {
+		panic("FATAL: TableName must only contain letters, digits and underscores.")
+	}
+	if s.Codec == nil {
+		panic("FATAL: Codec cannot be nil.")
+	}
+
+	common.CheckErr(migrate(db, dialect, s.TableName))
+	s.stmts = prepareStatements(db, dialect, s.TableName, s.Order)
+
+	return s
+}
+
+// prepareStatements prepares every statement used by the storage against the database.
+func prepareStatements(db *sql.DB, d Dialect, table string, order storageiface.Order) *statements {
+	columns := storageiface.DB_COLUMN_ID + ", " + storageiface.DB_COLUMN_EVENT + ", " + storageiface.DB_COLUMN_ENCODING
+	orderBy := "ORDER BY " + storageiface.DB_COLUMN_ID + " " + direction(order)
+	return &statements{
+		add: prepare(db,
+			"INSERT INTO "+table+"("+
+				storageiface.DB_COLUMN_EVENT+", "+storageiface.DB_COLUMN_ENCODING+
+				") VALUES("+d.Placeholder(1)+", "+d.Placeholder(2)+");"),
+		delete: prepare(db,
+			"DELETE FROM "+table+" "+
+				"WHERE "+storageiface.DB_COLUMN_ID+" = "+d.Placeholder(1)+";"),
+		deleteAll: prepare(db,
+			"DELETE FROM "+table+";"),
+		getAll: prepare(db,
+			"SELECT "+columns+" FROM "+table+" "+
+				orderBy+";"),
+		getRange: prepare(db,
+			"SELECT "+columns+" FROM "+table+" "+
+				orderBy+d.Limit(d.Placeholder(1))+";"),
+		count: prepare(db,
+			"SELECT COUNT(*) FROM "+table+";"),
+	}
+}
+
+// direction returns the ORDER BY direction which returns rows in the order.
+func direction(order storageiface.Order) string {
+	if order == storageiface.ORDER_NEWEST_FIRST {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+// prepare creates a prepared statement for the query.
+func prepare(db *sql.DB, query string) *sql.Stmt {
+	stmt, err := db.Prepare(query)
+	common.CheckErr(err)
+	return stmt
+}
+
+// --- Option
+
+// OptionTableName sets the name of the table events are stored in.
+func OptionTableName(tableName string) func(s *StorageSQL) {
+	return func(s *StorageSQL) { s.TableName = tableName }
+}
+
+// OptionCodec sets the Codec used to encode new events.
+// Rows written with any other registered Codec remain readable.
+func OptionCodec(c codec.Codec) func(s *StorageSQL) {
+	return func(s *StorageSQL) { s.Codec = c }
+}
+
+// OptionOrder sets the order in which events are returned.
+func OptionOrder(order storageiface.Order) func(s *StorageSQL) {
+	return func(s *StorageSQL) { s.Order = order }
+}
+
+// Close releases the prepared statements. The database itself is left open.
+func (s StorageSQL) Close() error {
+	if s.stmts != nil {
+		for _, stmt := range []*sql.Stmt{s.stmts.add, s.stmts.delete, s.stmts.deleteAll, s.stmts.getAll, s.stmts.getRange, s.stmts.count} {
+			stmt.Close()
+		}
+	}
+	return nil
+}
+
+// --- ADD
+
+// AddEventRow stores an event payload in the database.
+func (s StorageSQL) AddEventRow(payload payload.Payload) bool {
+	return s.execAddStatement(s.stmts.add, payload)
+}
+
+// AddEventRows stores a batch of event payloads in the database within a single transaction.
+//
+// Either all of the payloads are stored or none of them are.
+func (s StorageSQL) AddEventRows(payloads []payload.Payload) bool {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	if len(payloads) == 0 {
+		return true
+	}
+
+	tx, err := s.Db.Begin()
+	common.CheckErr(err)
+	defer tx.Rollback()
+
+	stmt := tx.Stmt(s.stmts.add)
+	defer stmt.Close()
+
+	for _, p := range payloads {
+		if !s.execAddStatement(stmt, p) {
+			return false
+		}
+	}
+
+	return tx.Commit() == nil
+}
+
+// execAddStatement encodes the payload and executes the add statement passed to it.
+func (s StorageSQL) execAddStatement(stmt *sql.Stmt, payload payload.Payload) bool {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	byteBuffer, err := s.Codec.Encode(payload.Get())
+	common.CheckErr(err)
+	res, err := stmt.Exec(byteBuffer, s.Codec.Name())
+	common.CheckErr(err)
+	affected, err := res.RowsAffected()
+	common.CheckErr(err)
+
+	return affected == 1
+}
+
+// --- DELETE
+
+// DeleteAllEventRows removes all events from the table.
+func (s StorageSQL) DeleteAllEventRows() int64 {
+	return execDeleteStatement(s.stmts.deleteAll)
+}
+
+// DeleteEventRows removes a range of ids from the table within a single transaction.
+func (s StorageSQL) DeleteEventRows(ids []int) int64 {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	if len(ids) == 0 {
+		return 0
+	}
+
+	tx, err := s.Db.Begin()
+	common.CheckErr(err)
+	defer tx.Rollback()
+
+	stmt := tx.Stmt(s.stmts.delete)
+	defer stmt.Close()
+
+	var affected int64
+	for _, id := range ids {
+		affected += execDeleteStatement(stmt, id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return 0
+	}
+	return affected
+}
+
+// execDeleteStatement is used to run statements which remove event rows from the table.
+func execDeleteStatement(stmt *sql.Stmt, args ...interface{}) int64 {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	res, err := stmt.Exec(args...)
+	common.CheckErr(err)
+	affected, err := res.RowsAffected()
+	common.CheckErr(err)
+
+	return affected
+}
+
+// --- GET
+
+// GetAllEventRows returns all events in the table in the configured Order.
+func (s StorageSQL) GetAllEventRows() []storageiface.EventRow {
+	return execGetStatement(s.stmts.getAll)
+}
+
+// GetEventRowsWithinRange returns up to eventRange events from the table, taken in the configured Order.
+func (s StorageSQL) GetEventRowsWithinRange(eventRange int) []storageiface.EventRow {
+	return execGetStatement(s.stmts.getRange, eventRange)
+}
+
+// CountEventRows returns the number of events in the table without decoding them.
+func (s StorageSQL) CountEventRows() int {
+	var count int
+	if err := s.stmts.count.QueryRow().Scan(&count); err != nil {
+		log.Println(err)
+		return 0
+	}
+	return count
+}
+
+// execGetStatement is used to run statements to fetch event rows from the table.
+//
+// Each row is decoded with the Codec it was written with.
+func execGetStatement(stmt *sql.Stmt, args ...interface{}) []storageiface.EventRow {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	eventItems := []storageiface.EventRow{}
+	rows, err := stmt.Query(args...)
+	common.CheckErr(err)
+	defer rows.Close()
+
+	for rows.Next() {
+		item := RawEventRow{}
+		common.CheckErr(rows.Scan(&item.id, &item.event, &item.encoding))
+		eventMap, err := codec.Decode(item.encoding, item.event)
+		if err != nil {
+			log.Println(err)
+		}
+		eventItems = append(eventItems, storageiface.EventRow{Id: item.id, Event: payload.Payload{Pairs: eventMap}})
+	}
+
+	return eventItems
+}
@@ -0,0 +1,191 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package sqldb
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/codec"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storageiface"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storagetest"
+)
+
+// TestStorageSQLInit asserts behaviour of database/sql storage functions.
+func TestStorageSQLInit(t *testing.T) {
+	assert := assert.New(t)
+	db := openTestDb(t)
+	storage := *Init(db, SQLite)
+	defer storage.Close()
+
+	assert.Equal(db, storage.Db)
+	assert.Equal(SQLite, storage.Dialect)
+	assert.Equal("events", storage.TableName)
+	assert.Equal(codec.JSON, storage.Codec)
+
+	var version int
+	assert.Nil(db.QueryRow("SELECT version FROM snowplow_schema_version WHERE table_name = 'events';").Scan(&version))
+	assert.Equal(SchemaVersion(), version)
+
+	// Initialising an already migrated table is a no-op
+	storage2 := *Init(db, SQLite)
+	defer storage2.Close()
+	assert.Nil(db.QueryRow("SELECT version FROM snowplow_schema_version WHERE table_name = 'events';").Scan(&version))
+	assert.Equal(SchemaVersion(), version)
+}
+
+// TestStorageSQLInitFatal asserts that invalid configuration is rejected.
+func TestStorageSQLInitFatal(t *testing.T) {
+	assert := assert.New(t)
+	db := openTestDb(t)
+
+	assert.PanicsWithValue("FATAL: Db cannot be nil.", func() { Init(nil, SQLite) })
+	assert.PanicsWithValue("FATAL: Dialect cannot be nil.", func() { Init(db, nil) })
+	assert.PanicsWithValue("FATAL: TableName must only contain letters, digits and underscores.", func() { Init(db, SQLite, OptionTableName("1events")) })
+	assert.PanicsWithValue("FATAL: Codec cannot be nil.", func() { Init(db, SQLite, OptionCodec(nil)) })
+}
+
+// TestSQLAddGetDeletePayload asserts ability to add, delete and get payloads.
+func TestSQLAddGetDeletePayload(t *testing.T) {
+	assert := assert.New(t)
+	storage := *Init(openTestDb(t), SQLite)
+	defer storage.Close()
+	assertDatabaseAddGetDeletePayload(assert, storage)
+}
+
+// TestSQLConformance runs the storage conformance suite in each order.
+func TestSQLConformance(t *testing.T) {
+	for _, order := range []storageiface.Order{storageiface.ORDER_OLDEST_FIRST, storageiface.ORDER_NEWEST_FIRST} {
+		order := order
+		t.Run(order.String(), func(t *testing.T) {
+			storagetest.Run(t, storagetest.Factory{
+				New: func(t *testing.T) storageiface.Storage {
+					return *Init(openTestDb(t), SQLite, OptionOrder(order))
+				},
+				Reopen: func(t *testing.T, storage storageiface.Storage) storageiface.Storage {
+					storage.(StorageSQL).Close()
+					return *Init(storage.(StorageSQL).Db, SQLite, OptionOrder(order))
+				},
+				Order: order,
+			})
+		})
+	}
+}
+
+// TestSQLAddEventRows asserts ability to add a batch of payloads in a single transaction.
+func TestSQLAddEventRows(t *testing.T) {
+	assert := assert.New(t)
+	storage := *Init(openTestDb(t), SQLite, OptionTableName("batched"), OptionCodec(codec.Gob))
+	defer storage.Close()
+
+	payloads := []payload.Payload{}
+	for i := 0; i < 20; i++ {
+		p := *payload.Init()
+		p.Add("eid", common.NewString(common.IntToString(i)))
+		payloads = append(payloads, p)
+	}
+	assert.True(storage.AddEventRows(payloads))
+	assert.True(storage.AddEventRows([]payload.Payload{}))
+
+	eventRows := storage.GetEventRowsWithinRange(5)
+	assert.Equal(5, len(eventRows))
+	assert.Equal("0", eventRows[0].Event.Get()["eid"])
+	assert.Equal("4", eventRows[4].Event.Get()["eid"])
+	assert.Equal(int64(2), storage.DeleteEventRows([]int{eventRows[0].Id, eventRows[1].Id, -1}))
+	assert.Equal(18, len(storage.GetAllEventRows()))
+}
+
+// TestSQLMigrateNewerSchemaVersion asserts that a table migrated by a newer version of the tracker is not downgraded.
+func TestSQLMigrateNewerSchemaVersion(t *testing.T) {
+	assert := assert.New(t)
+	db := openTestDb(t)
+	Init(db, SQLite).Close()
+
+	_, err := db.Exec("UPDATE snowplow_schema_version SET version = ? WHERE table_name = 'events';", SchemaVersion()+1)
+	assert.Nil(err)
+	assert.NotNil(migrate(db, SQLite, "events"))
+}
+
+// TestSQLClosedDatabase asserts that the storage fails safely once the database is closed.
+func TestSQLClosedDatabase(t *testing.T) {
+	assert := assert.New(t)
+	db := openTestDb(t)
+	storage := *Init(db, SQLite)
+	db.Close()
+
+	p := *payload.Init()
+	p.Add("e", common.NewString("pv"))
+	assert.False(storage.AddEventRow(p))
+	assert.False(storage.AddEventRows([]payload.Payload{p}))
+	assert.Equal(0, len(storage.GetAllEventRows()))
+	assert.Equal(0, len(storage.GetEventRowsWithinRange(10)))
+	assert.Equal(int64(0), storage.DeleteEventRows([]int{1}))
+	assert.Equal(int64(0), storage.DeleteAllEventRows())
+}
+
+// --- Common
+
+func openTestDb(t *testing.T) *sql.DB {
+	return openTestDbAt(t, filepath.Join(t.TempDir(), "test.db"))
+}
+
+func openTestDbAt(t *testing.T, dbName string) *sql.DB {
+	db, err := sql.Open("sqlite3", dbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func assertDatabaseAddGetDeletePayload(assert *assert.Assertions, storage storageiface.Storage) {
+	storage.DeleteAllEventRows()
+	payload := *payload.Init()
+	payload.Add("e", common.NewString("pv"))
+
+	// Add a Payload
+	assert.True(storage.AddEventRow(payload))
+	eventRows := storage.GetAllEventRows()
+	assert.Equal(1, len(eventRows))
+	assert.Equal("pv", eventRows[0].Event.Get()["e"])
+
+	// Delete the added row
+	assert.Equal(int64(1), storage.DeleteEventRows([]int{eventRows[0].Id}))
+	eventRows = storage.GetAllEventRows()
+	assert.Equal(0, len(eventRows))
+
+	// Add 20 payloads
+	for i := 0; i < 20; i++ {
+		result := storage.AddEventRow(payload)
+		assert.True(result)
+	}
+
+	eventRows = storage.GetEventRowsWithinRange(10)
+	assert.Equal(10, len(eventRows))
+	eventRows = storage.GetEventRowsWithinRange(30)
+	assert.Equal(20, len(eventRows))
+	eventRows = storage.GetAllEventRows()
+	assert.Equal(20, len(eventRows))
+	assert.Equal(int64(20), storage.DeleteAllEventRows())
+	eventRows = storage.GetAllEventRows()
+	assert.Equal(0, len(eventRows))
+	assert.Equal(int64(0), storage.DeleteEventRows([]int{}))
+}
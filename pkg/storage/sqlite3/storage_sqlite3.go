@@ -15,44 +15,60 @@ package sqlite3
 
 import (
 	"database/sql"
-	"log"
 
 	_ "github.com/mattn/go-sqlite3"
 
 	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
-	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/codec"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/sqldb"
 	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storageiface"
 )
 
+// StorageSQLite3 stores events in a SQLite database file which it opens and
+// owns. Events are stored with the SQLite dialect of the sqldb storage, so
+// both storages share one table layout and set of migrations.
 type StorageSQLite3 struct {
+	sqldb.StorageSQL
 	DbName string
 }
 
-type RawEventRow struct {
-	id    int
-	event []byte
-}
+// RawEventRow is the row of the events table as read from the database.
+type RawEventRow = sqldb.RawEventRow
+
+// Init opens a long-lived connection to the database, migrates the events
+// table to the latest schema version and prepares all of the statements
+// used by the storage.
+//
+// The connection is held until Close is called.
+func Init(dbName string, options ...func(*StorageSQLite3)) *StorageSQLite3 {
+	s := &StorageSQLite3{DbName: dbName}
+
+	// Set Defaults
+	s.TableName = storageiface.DB_TABLE_NAME
+	s.Codec = codec.JSON
+
+	// Option parameters
+	for _, op := range options {
+		op(s)
+	}
 
-func Init(dbName string) *StorageSQLite3 {
 	db := getDbConn(dbName)
-	defer db.Close()
 
+	// A single connection avoids "database is locked" errors on concurrent writes
+	// and ensures in-memory databases are shared by every statement
 	db.SetMaxOpenConns(1)
 
+	s.StorageSQL = *sqldb.Init(db, sqldb.SQLite,
+		sqldb.OptionTableName(s.TableName),
+		sqldb.OptionCodec(s.Codec),
+		sqldb.OptionOrder(s.Order),
+	)
+
 	// Enable Write-Ahead-Logging for concurrent read and write
-	_, err1 := db.Exec("PRAGMA journal_mode=WAL;")
-	common.CheckErr(err1)
-
-	// Create the Events Table
-	query :=
-		"CREATE TABLE IF NOT EXISTS " + storageiface.DB_TABLE_NAME + "(" +
-			storageiface.DB_COLUMN_ID + " INTEGER PRIMARY KEY, " +
-			storageiface.DB_COLUMN_EVENT + " BLOB" +
-			");"
-	_, err2 := db.Exec(query)
-	common.CheckErr(err2)
-
-	return &StorageSQLite3{DbName: dbName}
+	_, err := db.Exec("PRAGMA journal_mode=WAL;")
+	common.CheckErr(err)
+
+	return s
 }
 
 func getDbConn(dbName string) *sql.DB {
@@ -61,127 +77,37 @@ func getDbConn(dbName string) *sql.DB {
 	return db
 }
 
-// --- ADD
-
-// Add stores an event payload in the database.
-func (s StorageSQLite3) AddEventRow(payload payload.Payload) bool {
-	db := getDbConn(s.DbName)
-	defer db.Close()
-
-	// Prepare Add Statement
-	query :=
-		"INSERT INTO " + storageiface.DB_TABLE_NAME + "(" +
-			storageiface.DB_COLUMN_EVENT +
-			") values(?);"
-	addStmt, err1 := db.Prepare(query)
-	common.CheckErr(err1)
-
-	byteBuffer := common.SerializeMap(payload.Get())
-	return execAddStatement(addStmt, byteBuffer)
-}
-
-// execAddStatement executes the add statement passed to it.
-func execAddStatement(stmt *sql.Stmt, byteBuffer []byte) bool {
-	defer func() {
-		if err := recover(); err != nil {
-			log.Println(err)
-		}
-	}()
-
-	res, err := stmt.Exec(byteBuffer)
-	common.CheckErr(err)
-	affected, err2 := res.RowsAffected()
-	common.CheckErr(err2)
-
-	return affected == 1
-}
-
-// --- DELETE
-
-// DeleteAllEventRows removes all events from the database.
-func (s StorageSQLite3) DeleteAllEventRows() int64 {
-	db := getDbConn(s.DbName)
-	defer db.Close()
-
-	query := "DELETE FROM " + storageiface.DB_TABLE_NAME + ";"
-	return execDeleteQuery(db, query)
+// SchemaVersion returns the latest schema version of the events table.
+func SchemaVersion() int {
+	return sqldb.SchemaVersion()
 }
 
-// DeleteEventRows removes a range of ids from the database.
-func (s StorageSQLite3) DeleteEventRows(ids []int) int64 {
-	db := getDbConn(s.DbName)
-	defer db.Close()
-
-	if len(ids) > 0 {
-		query :=
-			"DELETE FROM " + storageiface.DB_TABLE_NAME + " " +
-				"WHERE " + storageiface.DB_COLUMN_ID + " in(" + common.IntArrayToString(ids, ",") + ");"
-		return execDeleteQuery(db, query)
-	} else {
-		return 0
-	}
-}
-
-// execDeleteQuery is used to run queries which removed event rows from the database.
-func execDeleteQuery(db *sql.DB, query string) int64 {
-	defer func() {
-		if err := recover(); err != nil {
-			log.Println(err)
-		}
-	}()
-
-	stmt, err := db.Prepare(query)
-	common.CheckErr(err)
-	defer stmt.Close()
-	res, err2 := stmt.Exec()
-	common.CheckErr(err2)
-	affected, err3 := res.RowsAffected()
-	common.CheckErr(err3)
+// --- Option
 
-	return affected
+// OptionTableName sets the name of the table events are stored in, allowing
+// several emitters to share one database file.
+func OptionTableName(tableName string) func(s *StorageSQLite3) {
+	return func(s *StorageSQLite3) { s.TableName = tableName }
 }
 
-// --- GET
-
-// GetAllEventRows returns all events in the database.
-func (s StorageSQLite3) GetAllEventRows() []storageiface.EventRow {
-	db := getDbConn(s.DbName)
-	defer db.Close()
-
-	query := "SELECT " + storageiface.DB_COLUMN_ID + ", " + storageiface.DB_COLUMN_EVENT + " FROM " + storageiface.DB_TABLE_NAME + ";"
-	return execGetQuery(db, query)
+// OptionCodec sets the Codec used to encode new events.
+// Rows written with any other registered Codec remain readable.
+func OptionCodec(c codec.Codec) func(s *StorageSQLite3) {
+	return func(s *StorageSQLite3) { s.Codec = c }
 }
 
-// GetEventRowsWithinRange returns a specified range of events from the database.
-func (s StorageSQLite3) GetEventRowsWithinRange(eventRange int) []storageiface.EventRow {
-	db := getDbConn(s.DbName)
-	defer db.Close()
-
-	query :=
-		"SELECT " + storageiface.DB_COLUMN_ID + ", " + storageiface.DB_COLUMN_EVENT + " FROM " + storageiface.DB_TABLE_NAME + " " +
-			"ORDER BY " + storageiface.DB_COLUMN_ID + " DESC LIMIT " + common.IntToString(eventRange) + ";"
-	return execGetQuery(db, query)
+// OptionOrder sets the order in which events are returned.
+func OptionOrder(order storageiface.Order) func(s *StorageSQLite3) {
+	return func(s *StorageSQLite3) { s.Order = order }
 }
 
-// execGetQuery is used to run queries to fetch event rows from the database.
-func execGetQuery(db *sql.DB, query string) []storageiface.EventRow {
-	defer func() {
-		if err := recover(); err != nil {
-			log.Println(err)
-		}
-	}()
-
-	eventItems := []storageiface.EventRow{}
-	rows, err := db.Query(query)
-	common.CheckErr(err)
-	defer rows.Close()
-
-	for rows.Next() {
-		item := RawEventRow{}
-		rows.Scan(&item.id, &item.event)
-		eventMap, _ := common.DeserializeMap(item.event)
-		eventItems = append(eventItems, storageiface.EventRow{item.id, payload.Payload{eventMap}})
+// Close releases the prepared statements and closes the database connection.
+//
+// The storage cannot be used after it has been closed.
+func (s StorageSQLite3) Close() error {
+	s.StorageSQL.Close()
+	if s.Db == nil {
+		return nil
 	}
-
-	return eventItems
+	return s.Db.Close()
 }
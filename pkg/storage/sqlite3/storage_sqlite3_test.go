@@ -14,13 +14,17 @@
 package sqlite3
 
 import (
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
 	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/codec"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/sqldb"
 	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storageiface"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storagetest"
 )
 
 // TestStorageSQLite3Init asserts behaviour of SQLite storage functions.
@@ -29,6 +33,128 @@ func TestStorageSQLite3Init(t *testing.T) {
 	storage := *Init("test.db")
 	assert.NotNil(storage)
 	assert.Equal("test.db", storage.DbName)
+	assert.Equal("events", storage.TableName)
+	assert.Equal(codec.JSON, storage.Codec)
+}
+
+// TestStorageSQLite3InitBadTableName asserts that table names which are not plain identifiers are rejected.
+func TestStorageSQLite3InitBadTableName(t *testing.T) {
+	assert := assert.New(t)
+	defer func() {
+		if err := recover(); err != nil {
+			assert.Equal("FATAL: TableName must only contain letters, digits and underscores.", err)
+		}
+	}()
+
+	storage := Init(filepath.Join(t.TempDir(), "test.db"), OptionTableName("events; DROP TABLE events"))
+	assert.Nil(storage)
+}
+
+// TestSQLite3MigrateLegacyDatabase asserts that gob rows written before schema versioning
+// are still readable once the table has been migrated.
+func TestSQLite3MigrateLegacyDatabase(t *testing.T) {
+	assert := assert.New(t)
+	dbName := filepath.Join(t.TempDir(), "test.db")
+
+	// Create a database the way earlier versions of the tracker did
+	db := getDbConn(dbName)
+	_, err := db.Exec("CREATE TABLE events(id INTEGER PRIMARY KEY, event BLOB);")
+	assert.Nil(err)
+	_, err = db.Exec("INSERT INTO events(event) values(?);", common.SerializeMap(map[string]string{"e": "pv"}))
+	assert.Nil(err)
+	db.Close()
+
+	storage := *Init(dbName)
+	defer storage.Close()
+
+	p := *payload.Init()
+	p.Add("e", common.NewString("se"))
+	assert.True(storage.AddEventRow(p))
+
+	eventRows := storage.GetAllEventRows()
+	assert.Equal(2, len(eventRows))
+	assert.Equal("pv", eventRows[0].Event.Get()["e"])
+	assert.Equal("se", eventRows[1].Event.Get()["e"])
+
+	var encoding string
+	assert.Nil(storage.Db.QueryRow("SELECT encoding FROM events WHERE id = ?;", eventRows[0].Id).Scan(&encoding))
+	assert.Equal(codec.NAME_GOB, encoding)
+	assert.Nil(storage.Db.QueryRow("SELECT encoding FROM events WHERE id = ?;", eventRows[1].Id).Scan(&encoding))
+	assert.Equal(codec.NAME_JSON, encoding)
+
+	var version int
+	assert.Nil(storage.Db.QueryRow("SELECT version FROM snowplow_schema_version WHERE table_name = 'events';").Scan(&version))
+	assert.Equal(SchemaVersion(), version)
+}
+
+// TestSQLite3MigrateNewerSchemaVersion asserts that a table migrated by a newer version of the tracker is not downgraded.
+func TestSQLite3MigrateNewerSchemaVersion(t *testing.T) {
+	assert := assert.New(t)
+	dbName := filepath.Join(t.TempDir(), "test.db")
+	Init(dbName).Close()
+
+	db := getDbConn(dbName)
+	_, err := db.Exec("UPDATE snowplow_schema_version SET version = ? WHERE table_name = 'events';", SchemaVersion()+1)
+	assert.Nil(err)
+	db.Close()
+	assert.Panics(func() { Init(dbName) })
+}
+
+// TestSQLite3SharedWithSQLStorage asserts that the sqldb storage reads and
+// writes the same table as the SQLite storage.
+func TestSQLite3SharedWithSQLStorage(t *testing.T) {
+	assert := assert.New(t)
+	dbName := filepath.Join(t.TempDir(), "test.db")
+
+	// Create a database the way earlier versions of the tracker did
+	db := getDbConn(dbName)
+	_, err := db.Exec("CREATE TABLE events(id INTEGER PRIMARY KEY, event BLOB);")
+	assert.Nil(err)
+	_, err = db.Exec("INSERT INTO events(event) values(?);", common.SerializeMap(map[string]string{"e": "pv"}))
+	assert.Nil(err)
+	db.Close()
+
+	sqliteStorage := *Init(dbName)
+	p := *payload.Init()
+	p.Add("e", common.NewString("se"))
+	assert.True(sqliteStorage.AddEventRow(p))
+	sqliteStorage.Close()
+
+	db = getDbConn(dbName)
+	defer db.Close()
+	storage := *sqldb.Init(db, sqldb.SQLite)
+	defer storage.Close()
+
+	p.Add("e", common.NewString("ue"))
+	assert.True(storage.AddEventRow(p))
+
+	eventRows := storage.GetAllEventRows()
+	assert.Equal(3, len(eventRows))
+	assert.Equal("pv", eventRows[0].Event.Get()["e"])
+	assert.Equal("se", eventRows[1].Event.Get()["e"])
+	assert.Equal("ue", eventRows[2].Event.Get()["e"])
+}
+
+// TestSQLite3SharedDatabase asserts that storages with different table names do not see each others events.
+func TestSQLite3SharedDatabase(t *testing.T) {
+	assert := assert.New(t)
+	dbName := filepath.Join(t.TempDir(), "test.db")
+	storage1 := *Init(dbName, OptionTableName("events_one"))
+	defer storage1.Close()
+	storage2 := *Init(dbName, OptionTableName("events_two"), OptionCodec(codec.Gob))
+	defer storage2.Close()
+
+	p := *payload.Init()
+	p.Add("e", common.NewString("pv"))
+	assert.True(storage1.AddEventRow(p))
+	assert.True(storage2.AddEventRow(p))
+	assert.True(storage2.AddEventRow(p))
+
+	assert.Equal(1, len(storage1.GetAllEventRows()))
+	assert.Equal(2, len(storage2.GetAllEventRows()))
+	assert.Equal("pv", storage2.GetAllEventRows()[0].Event.Get()["e"])
+	assert.Equal(int64(2), storage2.DeleteAllEventRows())
+	assert.Equal(1, len(storage1.GetAllEventRows()))
 }
 
 // TestSQLite3AddGetDeletePayload asserts ability to add, delete and get payloads.
@@ -38,17 +164,177 @@ func TestSQLite3AddGetDeletePayload(t *testing.T) {
 	assertDatabaseAddGetDeletePayload(assert, storage)
 }
 
+// TestSQLite3Conformance runs the storage conformance suite in each order.
+func TestSQLite3Conformance(t *testing.T) {
+	for _, order := range []storageiface.Order{storageiface.ORDER_OLDEST_FIRST, storageiface.ORDER_NEWEST_FIRST} {
+		order := order
+		t.Run(order.String(), func(t *testing.T) {
+			storagetest.Run(t, storagetest.Factory{
+				New: func(t *testing.T) storageiface.Storage {
+					return openTestStorage(t, filepath.Join(t.TempDir(), "test.db"), OptionOrder(order))
+				},
+				Reopen: func(t *testing.T, storage storageiface.Storage) storageiface.Storage {
+					storage.(StorageSQLite3).Close()
+					return openTestStorage(t, storage.(StorageSQLite3).DbName, OptionOrder(order))
+				},
+				Order: order,
+			})
+		})
+	}
+}
+
+// TestSQLite3AddEventRows asserts ability to add a batch of payloads in a single transaction.
+func TestSQLite3AddEventRows(t *testing.T) {
+	assert := assert.New(t)
+	storage := *Init(filepath.Join(t.TempDir(), "test.db"))
+	defer storage.Close()
+
+	payloads := []payload.Payload{}
+	for i := 0; i < 20; i++ {
+		p := *payload.Init()
+		p.Add("e", common.NewString("pv"))
+		p.Add("eid", common.NewString(common.IntToString(i)))
+		payloads = append(payloads, p)
+	}
+
+	assert.True(storage.AddEventRows(payloads))
+	assert.True(storage.AddEventRows([]payload.Payload{}))
+	eventRows := storage.GetAllEventRows()
+	assert.Equal(20, len(eventRows))
+	assert.Equal("0", eventRows[0].Event.Get()["eid"])
+	assert.Equal("19", eventRows[19].Event.Get()["eid"])
+
+	ids := []int{}
+	for _, row := range eventRows {
+		ids = append(ids, row.Id)
+	}
+	assert.Equal(int64(20), storage.DeleteEventRows(ids))
+	assert.Equal(int64(0), storage.DeleteEventRows(ids))
+}
+
+// TestSQLite3Close asserts that the storage fails safely once the connection is closed.
+func TestSQLite3Close(t *testing.T) {
+	assert := assert.New(t)
+	storage := *Init(filepath.Join(t.TempDir(), "test.db"))
+
+	p := *payload.Init()
+	p.Add("e", common.NewString("pv"))
+	assert.True(storage.AddEventRow(p))
+
+	assert.Nil(storage.Close())
+	assert.False(storage.AddEventRow(p))
+	assert.False(storage.AddEventRows([]payload.Payload{p}))
+	assert.Equal(0, len(storage.GetAllEventRows()))
+	assert.Equal(0, len(storage.GetEventRowsWithinRange(10)))
+	assert.Equal(int64(0), storage.DeleteEventRows([]int{1}))
+	assert.Equal(int64(0), storage.DeleteAllEventRows())
+}
+
+// TestSQLite3Reopen asserts that events survive closing and re-opening the database.
+func TestSQLite3Reopen(t *testing.T) {
+	assert := assert.New(t)
+	dbName := filepath.Join(t.TempDir(), "test.db")
+	storage := *Init(dbName)
+
+	payload := *payload.Init()
+	payload.Add("e", common.NewString("pv"))
+	assert.True(storage.AddEventRow(payload))
+	assert.Nil(storage.Close())
+
+	storage = *Init(dbName)
+	defer storage.Close()
+	eventRows := storage.GetAllEventRows()
+	assert.Equal(1, len(eventRows))
+	assert.Equal("pv", eventRows[0].Event.Get()["e"])
+}
+
+// TestSQLite3PanicRecovery asserts that the storage fails safely once it has been closed.
 func TestSQLite3PanicRecovery(t *testing.T) {
 	assert := assert.New(t)
+	storage := *Init(filepath.Join(t.TempDir(), "test.db"))
+	assert.Nil(storage.Close())
 
-	result := execDeleteQuery(nil, "")
-	assert.Equal(int64(0), result)
+	p := *payload.Init()
+	p.Add("e", common.NewString("pv"))
+	assert.False(storage.AddEventRow(p))
+	assert.False(storage.AddEventRows([]payload.Payload{p}))
+	assert.Equal(0, len(storage.GetAllEventRows()))
+	assert.Equal(0, len(storage.GetEventRowsWithinRange(10)))
+	assert.Equal(int64(0), storage.DeleteEventRows([]int{1}))
+	assert.Equal(int64(0), storage.DeleteAllEventRows())
+}
 
-	eventRows := execGetQuery(nil, "")
-	assert.Equal(0, len(eventRows))
+// --- Benchmarks
 
-	addResult := execAddStatement(nil, nil)
-	assert.False(addResult)
+// BenchmarkSQLite3AddEventRow measures adding events through the persistent connection.
+func BenchmarkSQLite3AddEventRow(b *testing.B) {
+	storage := *Init(filepath.Join(b.TempDir(), "bench.db"))
+	defer storage.Close()
+	payload := benchmarkPayload()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		storage.AddEventRow(payload)
+	}
+}
+
+// BenchmarkSQLite3AddEventRowReopen measures adding events the way the storage used to,
+// opening the database and preparing the statement for every call.
+func BenchmarkSQLite3AddEventRowReopen(b *testing.B) {
+	dbName := filepath.Join(b.TempDir(), "bench.db")
+	Init(dbName).Close()
+	payload := benchmarkPayload()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		db := getDbConn(dbName)
+		stmt, err := db.Prepare("INSERT INTO " + storageiface.DB_TABLE_NAME + "(" + storageiface.DB_COLUMN_EVENT + ", " + storageiface.DB_COLUMN_ENCODING + ") values(?, ?);")
+		common.CheckErr(err)
+		_, err = stmt.Exec(common.SerializeMap(payload.Get()), codec.NAME_GOB)
+		common.CheckErr(err)
+		stmt.Close()
+		db.Close()
+	}
+}
+
+// BenchmarkSQLite3AddEventRows measures adding events in batches of 100 per transaction.
+func BenchmarkSQLite3AddEventRows(b *testing.B) {
+	storage := *Init(filepath.Join(b.TempDir(), "bench.db"))
+	defer storage.Close()
+	payloads := []payload.Payload{}
+	for i := 0; i < 100; i++ {
+		payloads = append(payloads, benchmarkPayload())
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i += len(payloads) {
+		storage.AddEventRows(payloads)
+	}
+}
+
+// BenchmarkSQLite3GetEventRowsWithinRange measures fetching a batch of events from the persistent connection.
+func BenchmarkSQLite3GetEventRowsWithinRange(b *testing.B) {
+	storage := *Init(filepath.Join(b.TempDir(), "bench.db"))
+	defer storage.Close()
+	payloads := []payload.Payload{}
+	for i := 0; i < 1000; i++ {
+		payloads = append(payloads, benchmarkPayload())
+	}
+	storage.AddEventRows(payloads)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		storage.GetEventRowsWithinRange(100)
+	}
+}
+
+func benchmarkPayload() payload.Payload {
+	payload := *payload.Init()
+	payload.Add("e", common.NewString("pv"))
+	payload.Add("url", common.NewString("https://acme.com/some/page"))
+	payload.Add("eid", common.NewString(common.GetUUID()))
+	payload.Add("dtm", common.NewString(common.GetTimestampString()))
+	return payload
 }
 
 // --- Common
@@ -86,3 +372,9 @@ func assertDatabaseAddGetDeletePayload(assert *assert.Assertions, storage storag
 	assert.Equal(0, len(eventRows))
 	assert.Equal(int64(0), storage.DeleteEventRows([]int{}))
 }
+
+func openTestStorage(t *testing.T, dbName string, options ...func(*StorageSQLite3)) StorageSQLite3 {
+	storage := *Init(dbName, options...)
+	t.Cleanup(func() { storage.Close() })
+	return storage
+}
@@ -14,13 +14,20 @@
 package storageiface
 
 import (
+	"strconv"
+
 	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
 )
 
 const (
-	DB_TABLE_NAME   = "events"
-	DB_COLUMN_ID    = "id"
-	DB_COLUMN_EVENT = "event"
+	DB_TABLE_NAME      = "events"
+	DB_COLUMN_ID       = "id"
+	DB_COLUMN_EVENT    = "event"
+	DB_COLUMN_ENCODING = "encoding"
+
+	DB_VERSION_TABLE_NAME     = "snowplow_schema_version"
+	DB_VERSION_COLUMN_TABLE   = "table_name"
+	DB_VERSION_COLUMN_VERSION = "version"
 )
 
 type EventRow struct {
@@ -28,6 +35,30 @@ type EventRow struct {
 	Event payload.Payload
 }
 
+// Order is the order in which a Storage returns events from GetAllEventRows
+// and GetEventRowsWithinRange.
+type Order int
+
+const (
+	ORDER_OLDEST_FIRST Order = iota // Events are returned in the order they were added
+	ORDER_NEWEST_FIRST              // The most recently added events are returned first
+)
+
+func (o Order) String() string {
+	switch o {
+	case ORDER_OLDEST_FIRST:
+		return "ORDER_OLDEST_FIRST"
+	case ORDER_NEWEST_FIRST:
+		return "ORDER_NEWEST_FIRST"
+	}
+	return "Order(" + strconv.Itoa(int(o)) + ")"
+}
+
+// Storage queues events until they have been sent.
+//
+// Unless configured otherwise every Storage returns events ORDER_OLDEST_FIRST,
+// so that GetEventRowsWithinRange returns the events which have been waiting
+// the longest and a backlog is delivered in the order it was tracked.
 type Storage interface {
 	AddEventRow(payload payload.Payload) bool
 	DeleteAllEventRows() int64
@@ -35,3 +66,17 @@ type Storage interface {
 	GetAllEventRows() []EventRow
 	GetEventRowsWithinRange(eventRange int) []EventRow
 }
+
+// BulkStorage is implemented by Storage backends which are able to add
+// a batch of events in a single operation.
+type BulkStorage interface {
+	Storage
+	AddEventRows(payloads []payload.Payload) bool
+}
+
+// CountingStorage is implemented by Storage backends which are able to count
+// their events without reading and decoding them.
+type CountingStorage interface {
+	Storage
+	CountEventRows() int
+}
@@ -0,0 +1,314 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+// Package storagetest provides a conformance suite for implementations of storageiface.Storage.
+//
+// Backends call Run from their own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		storagetest.Run(t, storagetest.Factory{
+//			New: func(t *testing.T) storageiface.Storage { return *mystorage.Init() },
+//		})
+//	}
+package storagetest
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storageiface"
+)
+
+const (
+	MARKER = "eid" // Payload key used to identify the events added by the suite
+)
+
+// Factory creates the storages under test.
+type Factory struct {
+	// New returns a new, empty storage. Any clean up should be registered with t.Cleanup.
+	New func(t *testing.T) storageiface.Storage
+
+	// Reopen is set for persistent storages. It closes the storage and returns
+	// a new one reading the same underlying data.
+	Reopen func(t *testing.T, storage storageiface.Storage) storageiface.Storage
+
+	// Order is the order the storages are configured to return events in.
+	Order storageiface.Order
+}
+
+// Run runs every conformance test against storages created by the factory.
+func Run(t *testing.T, f Factory) {
+	t.Run("AddGetDelete", func(t *testing.T) { TestAddGetDelete(t, f) })
+	t.Run("Ordering", func(t *testing.T) { TestOrdering(t, f) })
+	t.Run("RangeLimits", func(t *testing.T) { TestRangeLimits(t, f) })
+	t.Run("Deletes", func(t *testing.T) { TestDeletes(t, f) })
+	t.Run("Count", func(t *testing.T) { TestCount(t, f) })
+	t.Run("PayloadFidelity", func(t *testing.T) { TestPayloadFidelity(t, f) })
+	t.Run("Concurrency", func(t *testing.T) { TestConcurrency(t, f) })
+	t.Run("Reopen", func(t *testing.T) { TestReopen(t, f) })
+}
+
+// TestAddGetDelete asserts the basic lifecycle of events within a storage.
+func TestAddGetDelete(t *testing.T, f Factory) {
+	assert := assert.New(t)
+	storage := f.New(t)
+
+	assert.Equal(0, len(storage.GetAllEventRows()))
+	assert.True(storage.AddEventRow(NewPayload(0)))
+	eventRows := storage.GetAllEventRows()
+	assert.Equal(1, len(eventRows))
+	assert.Equal("pv", eventRows[0].Event.Get()["e"])
+
+	assert.Equal(int64(1), storage.DeleteEventRows([]int{eventRows[0].Id}))
+	assert.Equal(0, len(storage.GetAllEventRows()))
+
+	AddPayloads(t, storage, 0, 20)
+	assert.Equal(20, len(storage.GetAllEventRows()))
+	assert.Equal(int64(20), storage.DeleteAllEventRows())
+	assert.Equal(0, len(storage.GetAllEventRows()))
+	assert.Equal(int64(0), storage.DeleteAllEventRows())
+}
+
+// TestOrdering asserts that events are returned in the configured order and that ids are unique.
+func TestOrdering(t *testing.T, f Factory) {
+	assert := assert.New(t)
+	storage := f.New(t)
+	AddPayloads(t, storage, 0, 10)
+
+	assert.Equal(f.inOrder(Markers(0, 10)), RowMarkers(storage.GetAllEventRows()))
+	assert.Equal(f.inOrder(Markers(0, 10))[:4], RowMarkers(storage.GetEventRowsWithinRange(4)))
+	assert.Equal(RowIds(storage.GetAllEventRows()[:4]), RowIds(storage.GetEventRowsWithinRange(4)))
+
+	ids := map[int]bool{}
+	for _, row := range storage.GetAllEventRows() {
+		assert.False(ids[row.Id], "duplicate id %d", row.Id)
+		ids[row.Id] = true
+	}
+
+	// Deleting the first events returned exposes the next ones
+	assert.Equal(int64(4), storage.DeleteEventRows(RowIds(storage.GetEventRowsWithinRange(4))))
+	AddPayloads(t, storage, 10, 12)
+	if f.Order == storageiface.ORDER_NEWEST_FIRST {
+		assert.Equal([]string{"11", "10", "5", "4"}, RowMarkers(storage.GetEventRowsWithinRange(4)))
+		assert.Equal(append([]string{"11", "10"}, f.inOrder(Markers(0, 6))...), RowMarkers(storage.GetAllEventRows()))
+	} else {
+		assert.Equal(Markers(4, 8), RowMarkers(storage.GetEventRowsWithinRange(4)))
+		assert.Equal(Markers(4, 12), RowMarkers(storage.GetAllEventRows()))
+	}
+}
+
+// TestRangeLimits asserts that range reads never return more than requested.
+func TestRangeLimits(t *testing.T, f Factory) {
+	assert := assert.New(t)
+	storage := f.New(t)
+
+	assert.Equal(0, len(storage.GetEventRowsWithinRange(10)))
+	AddPayloads(t, storage, 0, 5)
+	assert.Equal(0, len(storage.GetEventRowsWithinRange(0)))
+	assert.Equal(1, len(storage.GetEventRowsWithinRange(1)))
+	assert.Equal(5, len(storage.GetEventRowsWithinRange(5)))
+	assert.Equal(5, len(storage.GetEventRowsWithinRange(500)))
+}
+
+// TestDeletes asserts that DeleteEventRows only counts events which existed and were removed.
+func TestDeletes(t *testing.T, f Factory) {
+	assert := assert.New(t)
+	storage := f.New(t)
+	AddPayloads(t, storage, 0, 5)
+	ids := RowIds(storage.GetAllEventRows())
+
+	assert.Equal(int64(0), storage.DeleteEventRows(nil))
+	assert.Equal(int64(0), storage.DeleteEventRows([]int{}))
+
+	// Missing ids are ignored
+	missing := ids[len(ids)-1] + 1000
+	assert.Equal(int64(0), storage.DeleteEventRows([]int{missing}))
+
+	// Each event is only counted once however often its id is given
+	assert.Equal(int64(2), storage.DeleteEventRows([]int{ids[1], ids[3], ids[1], missing}))
+	assert.Equal(int64(0), storage.DeleteEventRows([]int{ids[1], ids[3]}))
+	assert.Equal(f.inOrder([]string{"0", "2", "4"}), RowMarkers(storage.GetAllEventRows()))
+
+	assert.Equal(int64(3), storage.DeleteAllEventRows())
+	assert.Equal(int64(0), storage.DeleteEventRows(ids))
+	assert.Equal(0, len(storage.GetAllEventRows()))
+}
+
+// TestCount asserts that a CountingStorage counts the events GetAllEventRows
+// returns. It is skipped for storages which cannot count their events.
+func TestCount(t *testing.T, f Factory) {
+	assert := assert.New(t)
+	storage, ok := f.New(t).(storageiface.CountingStorage)
+	if !ok {
+		t.Skip("storage does not implement CountingStorage")
+	}
+
+	assert.Equal(0, storage.CountEventRows())
+	AddPayloads(t, storage, 0, 5)
+	assert.Equal(5, storage.CountEventRows())
+	assert.Equal(int64(2), storage.DeleteEventRows(RowIds(storage.GetEventRowsWithinRange(2))))
+	assert.Equal(3, storage.CountEventRows())
+	assert.Equal(len(storage.GetAllEventRows()), storage.CountEventRows())
+	storage.DeleteAllEventRows()
+	assert.Equal(0, storage.CountEventRows())
+}
+
+// TestPayloadFidelity asserts that payloads are returned exactly as they were added.
+func TestPayloadFidelity(t *testing.T, f Factory) {
+	assert := assert.New(t)
+	storage := f.New(t)
+
+	p := NewPayload(0)
+	p.Add("url", common.NewString("https://acme.com/?q=\"quoted\"&emoji=😀#frag"))
+	p.Add("ue_pr", common.NewString("{\"schema\":\"iglu:com.acme/event/jsonschema/1-0-0\",\"data\":{\"a\":[1,2]}}"))
+	p.Add("page", common.NewString("line\nbreak\ttab\\backslash\u0000nul"))
+	p.Add("ip", common.NewString("2001:db8::1"))
+
+	assert.True(storage.AddEventRow(p))
+	eventRows := storage.GetAllEventRows()
+	assert.Equal(1, len(eventRows))
+	assert.Equal(p.Get(), eventRows[0].Event.Get())
+}
+
+// TestConcurrency asserts that concurrent adds, reads and deletes neither lose nor duplicate events.
+func TestConcurrency(t *testing.T, f Factory) {
+	assert := assert.New(t)
+	storage := f.New(t)
+
+	const writers = 4
+	const perWriter = 50
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				storage.AddEventRow(NewPayload(w*perWriter + i))
+			}
+		}(w)
+	}
+
+	var deleted int64
+	var lock sync.Mutex
+	for d := 0; d < 2; d++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				count := storage.DeleteEventRows(RowIds(storage.GetEventRowsWithinRange(5)))
+				lock.Lock()
+				deleted += count
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	remaining := storage.GetAllEventRows()
+	assert.Equal(int64(writers*perWriter), deleted+int64(len(remaining)))
+	assert.Equal(int64(len(remaining)), storage.DeleteAllEventRows())
+}
+
+// TestReopen asserts that persistent storages keep undeleted events, in order, across a reopen.
+// Ids may change across a reopen but must remain valid for deletes.
+func TestReopen(t *testing.T, f Factory) {
+	if f.Reopen == nil {
+		t.Skip("storage is not persistent")
+	}
+	assert := assert.New(t)
+	storage := f.New(t)
+	AddPayloads(t, storage, 0, 5)
+	assert.Equal(int64(2), storage.DeleteEventRows(MarkerIds(storage.GetAllEventRows(), "0", "2")))
+
+	storage = f.Reopen(t, storage)
+	assert.Equal(f.inOrder([]string{"1", "3", "4"}), RowMarkers(storage.GetAllEventRows()))
+
+	AddPayloads(t, storage, 5, 6)
+	assert.Equal(f.inOrder([]string{"1", "3", "4", "5"}), RowMarkers(storage.GetAllEventRows()))
+	assert.Equal(int64(4), storage.DeleteEventRows(RowIds(storage.GetAllEventRows())))
+}
+
+// --- Helpers
+
+// inOrder returns markers, given oldest first, in the order of the factory's storages.
+func (f Factory) inOrder(markers []string) []string {
+	if f.Order != storageiface.ORDER_NEWEST_FIRST {
+		return markers
+	}
+	reversed := make([]string, len(markers))
+	for i, marker := range markers {
+		reversed[len(markers)-1-i] = marker
+	}
+	return reversed
+}
+
+// NewPayload returns a page view payload identified by the marker i.
+func NewPayload(i int) payload.Payload {
+	p := *payload.Init()
+	p.Add("e", common.NewString("pv"))
+	p.Add(MARKER, common.NewString(common.IntToString(i)))
+	return p
+}
+
+// AddPayloads adds payloads with markers from (inclusive) to to (exclusive).
+func AddPayloads(t *testing.T, storage storageiface.Storage, from int, to int) {
+	for i := from; i < to; i++ {
+		if !storage.AddEventRow(NewPayload(i)) {
+			t.Fatalf("failed to add payload %d", i)
+		}
+	}
+}
+
+// Markers returns the markers from (inclusive) to to (exclusive).
+func Markers(from int, to int) []string {
+	markers := []string{}
+	for i := from; i < to; i++ {
+		markers = append(markers, common.IntToString(i))
+	}
+	return markers
+}
+
+// RowMarkers returns the marker of each row.
+func RowMarkers(eventRows []storageiface.EventRow) []string {
+	markers := []string{}
+	for _, row := range eventRows {
+		markers = append(markers, row.Event.Get()[MARKER])
+	}
+	return markers
+}
+
+// MarkerIds returns the ids of the rows with the given markers.
+func MarkerIds(eventRows []storageiface.EventRow, markers ...string) []int {
+	ids := []int{}
+	for _, row := range eventRows {
+		for _, marker := range markers {
+			if row.Event.Get()[MARKER] == marker {
+				ids = append(ids, row.Id)
+			}
+		}
+	}
+	return ids
+}
+
+// RowIds returns the id of each row.
+func RowIds(eventRows []storageiface.EventRow) []int {
+	ids := []int{}
+	for _, row := range eventRows {
+		ids = append(ids, row.Id)
+	}
+	return ids
+}
@@ -0,0 +1,121 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
+)
+
+// Anonymiser pseudonymises and masks the subject fields of every event before
+// it leaves the Tracker.
+//
+// User identifiers are replaced by the hex encoded HMAC-SHA256 of the value
+// keyed with HashKey, so that the same user keeps the same pseudonym without
+// the raw value being sent. The pseudonyms are only as stable as the key:
+// rotating it deliberately breaks the link between the events hashed before
+// and after. To make rotation visible downstream set KeyId, for example to
+// "2024-01", which prefixes every hash as "2024-01:<hash>", and change both
+// together. Keep the key secret, as anyone holding it can test guesses of
+// the raw values against the hashes.
+//
+// IP addresses have their trailing octets zeroed, and an address which
+// cannot be parsed is removed.
+type Anonymiser struct {
+	IpV4MaskedOctets int      // Optional, the trailing octets of an IPv4 address to zero, 0 to 4
+	IpV6MaskedOctets int      // Optional, the trailing octets of an IPv6 address to zero, 0 to 16
+	HashKey          []byte   // Optional, the HMAC key which user identifiers are hashed with
+	KeyId            string   // Optional, identifies the HashKey in each hash
+	HashFields       []string // Optional, the fields to hash, defaults to uid, tnuid and duid
+	RemoveUseragent  bool     // Optional, removes the ua field
+}
+
+// OptionAnonymiser sets the Tracker Anonymiser
+func OptionAnonymiser(anonymiser Anonymiser) func(t *Tracker) {
+	return func(t *Tracker) { t.Anonymiser = &anonymiser }
+}
+
+// check panics if the Anonymiser is misconfigured.
+func (a Anonymiser) check() {
+	if a.IpV4MaskedOctets < 0 || a.IpV4MaskedOctets > net.IPv4len {
+		panic("FATAL: IpV4MaskedOctets must be between 0 and 4.")
+	}
+	if a.IpV6MaskedOctets < 0 || a.IpV6MaskedOctets > net.IPv6len {
+		panic("FATAL: IpV6MaskedOctets must be between 0 and 16.")
+	}
+	if len(a.HashFields) > 0 && len(a.HashKey) == 0 {
+		panic("FATAL: HashFields cannot be set without a HashKey.")
+	}
+}
+
+// anonymise rewrites the subject fields of the payload in place. A nil
+// Anonymiser leaves the payload untouched.
+func (a *Anonymiser) anonymise(p payload.Payload) {
+	if a == nil {
+		return
+	}
+	if ip, ok := p.Pairs[IP_ADDRESS]; ok {
+		if masked := a.maskIp(ip); masked != "" {
+			p.Pairs[IP_ADDRESS] = masked
+		} else {
+			delete(p.Pairs, IP_ADDRESS)
+		}
+	}
+	if len(a.HashKey) > 0 {
+		fields := a.HashFields
+		if len(fields) == 0 {
+			fields = []string{UID, NETWORK_UID, DOMAIN_UID}
+		}
+		for _, field := range fields {
+			if value, ok := p.Pairs[field]; ok {
+				p.Pairs[field] = a.hash(value)
+			}
+		}
+	}
+	if a.RemoveUseragent {
+		delete(p.Pairs, USERAGENT)
+	}
+}
+
+// maskIp zeroes the trailing octets of an IP address, returning an empty
+// string if it cannot be parsed.
+func (a *Anonymiser) maskIp(value string) string {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return ""
+	}
+	masked, octets := ip.To16(), a.IpV6MaskedOctets
+	if v4 := ip.To4(); v4 != nil {
+		masked, octets = v4, a.IpV4MaskedOctets
+	}
+	for i := len(masked) - octets; i < len(masked); i++ {
+		masked[i] = 0
+	}
+	return masked.String()
+}
+
+// hash returns the pseudonym of a user identifier.
+func (a *Anonymiser) hash(value string) string {
+	mac := hmac.New(sha256.New, a.HashKey)
+	mac.Write([]byte(value))
+	hash := hex.EncodeToString(mac.Sum(nil))
+	if a.KeyId != "" {
+		return a.KeyId + ":" + hash
+	}
+	return hash
+}
@@ -0,0 +1,100 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+)
+
+func TestAnonymiserMaskIp(t *testing.T) {
+	assert := assert.New(t)
+	anonymiser := &Anonymiser{IpV4MaskedOctets: 1, IpV6MaskedOctets: 10}
+	assert.Equal("37.157.33.0", anonymiser.maskIp("37.157.33.178"))
+	assert.Equal("37.157.33.0", anonymiser.maskIp("::ffff:37.157.33.178"))
+	assert.Equal("2001:db8:85a3::", anonymiser.maskIp("2001:0db8:85a3:0000:0000:8a2e:0370:7334"))
+	assert.Equal("", anonymiser.maskIp("not-an-ip"))
+
+	anonymiser = &Anonymiser{IpV4MaskedOctets: 4, IpV6MaskedOctets: 16}
+	assert.Equal("0.0.0.0", anonymiser.maskIp("37.157.33.178"))
+	assert.Equal("::", anonymiser.maskIp("2001:db8::1"))
+
+	anonymiser = &Anonymiser{}
+	assert.Equal("37.157.33.178", anonymiser.maskIp("37.157.33.178"))
+}
+
+func TestAnonymiserHash(t *testing.T) {
+	assert := assert.New(t)
+	anonymiser := &Anonymiser{HashKey: []byte("secret")}
+	hash := anonymiser.hash("user-1")
+	assert.Equal(64, len(hash))
+	assert.Equal(hash, anonymiser.hash("user-1"))
+	assert.NotEqual(hash, anonymiser.hash("user-2"))
+
+	rotated := &Anonymiser{HashKey: []byte("rotated"), KeyId: "2024-01"}
+	assert.Equal("2024-01:", rotated.hash("user-1")[:8])
+	assert.NotEqual(hash, rotated.hash("user-1")[8:])
+}
+
+func TestTrackWithAnonymiser(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	subject := InitSubject()
+	subject.SetIpAddress("37.157.33.178")
+	subject.SetUserId("user-1")
+	subject.SetNetworkUserId("network-1")
+	subject.SetDomainUserId("domain-1")
+	subject.SetUseragent("Mozilla/5.0")
+	subject.SetLanguage("en")
+
+	anonymiser := Anonymiser{IpV4MaskedOctets: 2, HashKey: []byte("secret"), RemoveUseragent: true}
+	tracker, sent := initCapturingTracker(OptionSubject(subject), OptionAnonymiser(anonymiser))
+	assert.Nil(tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com")}))
+
+	event := (*sent)[0]
+	assert.Equal("37.157.0.0", event[IP_ADDRESS])
+	assert.Equal(anonymiser.hash("user-1"), event[UID])
+	assert.Equal(anonymiser.hash("network-1"), event[NETWORK_UID])
+	assert.Equal(anonymiser.hash("domain-1"), event[DOMAIN_UID])
+	assert.Equal("", event[USERAGENT])
+	assert.Equal("en", event[LANGUAGE])
+	assert.Equal("user-1", subject.Get()[UID], "the subject is not modified")
+
+	// Only the configured fields are hashed
+	anonymiser.HashFields = []string{UID}
+	tracker.Anonymiser = &anonymiser
+	assert.Nil(tracker.TrackStructEvent(StructuredEvent{Category: common.NewString("shop"), Action: common.NewString("add-to-basket")}))
+	event = (*sent)[1]
+	assert.Equal(anonymiser.hash("user-1"), event[UID])
+	assert.Equal("network-1", event[NETWORK_UID])
+}
+
+func TestAnonymiserPanics(t *testing.T) {
+	assert := assert.New(t)
+	assert.PanicsWithValue("FATAL: IpV4MaskedOctets must be between 0 and 4.", func() {
+		InitTracker(RequireEmitter(initSynchronousEmitter()), OptionAnonymiser(Anonymiser{IpV4MaskedOctets: 5}))
+	})
+	assert.PanicsWithValue("FATAL: IpV6MaskedOctets must be between 0 and 16.", func() {
+		InitTracker(RequireEmitter(initSynchronousEmitter()), OptionAnonymiser(Anonymiser{IpV6MaskedOctets: -1}))
+	})
+	assert.PanicsWithValue("FATAL: HashFields cannot be set without a HashKey.", func() {
+		InitTracker(RequireEmitter(initSynchronousEmitter()), OptionAnonymiser(Anonymiser{HashFields: []string{UID}}))
+	})
+}
@@ -0,0 +1,96 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"os"
+	"runtime"
+	"runtime/debug"
+)
+
+// OptionBuiltinEntities attaches the entities to every event, for example:
+//
+//	OptionBuiltinEntities(HostEntity(), RuntimeEntity(), ApplicationEntity("", ""))
+//
+// They are added as a GlobalContext tagged BUILTIN_ENTITIES_TAG. The schemas
+// of the built-in entities are published in the schemas directory of this
+// repository.
+func OptionBuiltinEntities(entities ...SelfDescribingJson) func(t *Tracker) {
+	return OptionGlobalContexts(GlobalContext{Tag: BUILTIN_ENTITIES_TAG, Contexts: entities})
+}
+
+// HostEntity returns a SCHEMA_HOST entity describing the machine the process
+// runs on. The hostname is left out if it cannot be read.
+func HostEntity() SelfDescribingJson {
+	data := map[string]interface{}{}
+	if hostname, err := os.Hostname(); err == nil {
+		data[HOST_HOSTNAME] = hostname
+	}
+	return *InitSelfDescribingJson(SCHEMA_HOST, data)
+}
+
+// RuntimeEntity returns a SCHEMA_RUNTIME entity describing the Go runtime.
+func RuntimeEntity() SelfDescribingJson {
+	return *InitSelfDescribingJson(SCHEMA_RUNTIME, map[string]interface{}{
+		RT_OS:         runtime.GOOS,
+		RT_ARCH:       runtime.GOARCH,
+		RT_GO_VERSION: runtime.Version(),
+	})
+}
+
+// ApplicationEntity returns a SCHEMA_APPLICATION entity describing the
+// application. An empty name or version is read from the build info of the
+// main module, and the version control fields are set when the binary was
+// built from a repository.
+func ApplicationEntity(name string, version string) SelfDescribingJson {
+	return *InitSelfDescribingJson(SCHEMA_APPLICATION, applicationData(name, version, readBuildInfo()))
+}
+
+func readBuildInfo() *debug.BuildInfo {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return info
+	}
+	return nil
+}
+
+// applicationData builds the data of the application entity, leaving out the
+// fields which are unknown.
+func applicationData(name string, version string, info *debug.BuildInfo) map[string]interface{} {
+	data := map[string]interface{}{}
+	if info != nil {
+		if name == "" {
+			name = info.Main.Path
+		}
+		if version == "" && info.Main.Version != "(devel)" {
+			version = info.Main.Version
+		}
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				data[APP_VCS_REVISION] = setting.Value
+			case "vcs.time":
+				data[APP_VCS_TIME] = setting.Value
+			case "vcs.modified":
+				data[APP_VCS_MODIFIED] = setting.Value == "true"
+			}
+		}
+	}
+	if name != "" {
+		data[APP_NAME] = name
+	}
+	if version != "" {
+		data[APP_VERSION] = version
+	}
+	return data
+}
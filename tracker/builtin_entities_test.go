@@ -0,0 +1,142 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+)
+
+func TestBuiltinEntities(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	tracker, sent := initCapturingTracker(OptionBuiltinEntities(HostEntity(), RuntimeEntity(), ApplicationEntity("shop", "1.2.3")))
+	assert.Nil(tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com")}))
+	assert.Equal([]string{SCHEMA_HOST, SCHEMA_RUNTIME, SCHEMA_APPLICATION}, contextSchemas(t, (*sent)[0]))
+
+	tracker.RemoveGlobalContexts(BUILTIN_ENTITIES_TAG)
+	assert.Nil(tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com")}))
+	assert.Equal("", (*sent)[1][CONTEXT])
+
+	hostname, _ := os.Hostname()
+	assert.Equal(hostname, HostEntity().Get()[DATA].(map[string]interface{})[HOST_HOSTNAME])
+	assert.Equal(map[string]interface{}{
+		RT_OS:         runtime.GOOS,
+		RT_ARCH:       runtime.GOARCH,
+		RT_GO_VERSION: runtime.Version(),
+	}, RuntimeEntity().Get()[DATA])
+}
+
+func TestApplicationData(t *testing.T) {
+	assert := assert.New(t)
+	info := &debug.BuildInfo{
+		Main: debug.Module{Path: "github.com/acme/shop", Version: "v1.4.0"},
+		Settings: []debug.BuildSetting{
+			{Key: "GOOS", Value: "linux"},
+			{Key: "vcs.revision", Value: "2d9a3c1"},
+			{Key: "vcs.time", Value: "2023-06-01T12:00:00Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+	assert.Equal(map[string]interface{}{
+		APP_NAME:         "github.com/acme/shop",
+		APP_VERSION:      "v1.4.0",
+		APP_VCS_REVISION: "2d9a3c1",
+		APP_VCS_TIME:     "2023-06-01T12:00:00Z",
+		APP_VCS_MODIFIED: true,
+	}, applicationData("", "", info))
+
+	info.Main.Version = "(devel)"
+	data := applicationData("shop", "", info)
+	assert.Equal("shop", data[APP_NAME])
+	assert.Nil(data[APP_VERSION])
+
+	assert.Equal(map[string]interface{}{APP_NAME: "shop", APP_VERSION: "1.2.3"}, applicationData("shop", "1.2.3", nil))
+	assert.Equal(map[string]interface{}{}, applicationData("", "", nil))
+}
+
+// TestPublishedSchemas asserts that the entities built by the tracker match
+// the schemas published in the schemas directory.
+func TestPublishedSchemas(t *testing.T) {
+	assert := assert.New(t)
+	application := *InitSelfDescribingJson(SCHEMA_APPLICATION, applicationData("shop", "1.2.3", &debug.BuildInfo{
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "2d9a3c1"},
+			{Key: "vcs.time", Value: "2023-06-01T12:00:00Z"},
+			{Key: "vcs.modified", Value: "false"},
+		},
+	}))
+
+	for _, entity := range []SelfDescribingJson{HostEntity(), RuntimeEntity(), application, samplingEntity(0.1)} {
+		assertMatchesPublishedSchema(t, assert, entity)
+	}
+}
+
+// assertMatchesPublishedSchema checks the entity against the subset of JSON
+// schema used by the published schemas.
+func assertMatchesPublishedSchema(t *testing.T, assert *assert.Assertions, entity SelfDescribingJson) {
+	uri := strings.TrimPrefix(entity.schema, "iglu:")
+	b, err := os.ReadFile(filepath.Join("..", "schemas", filepath.FromSlash(uri)))
+	if !assert.Nil(err, uri) {
+		return
+	}
+	var schema struct {
+		Self struct {
+			Vendor  string `json:"vendor"`
+			Name    string `json:"name"`
+			Format  string `json:"format"`
+			Version string `json:"version"`
+		} `json:"self"`
+		Properties map[string]struct {
+			Type string `json:"type"`
+		} `json:"properties"`
+		Required []string `json:"required"`
+	}
+	if !assert.Nil(json.Unmarshal(b, &schema), uri) {
+		return
+	}
+	assert.Equal(uri, schema.Self.Vendor+"/"+schema.Self.Name+"/"+schema.Self.Format+"/"+schema.Self.Version)
+
+	var data map[string]interface{}
+	assert.Nil(json.Unmarshal([]byte(common.MapToJson(entity.data)), &data))
+	for _, key := range schema.Required {
+		assert.Contains(data, key, uri)
+	}
+	for key, value := range data {
+		property, ok := schema.Properties[key]
+		if !assert.True(ok, uri+" has no property "+key) {
+			continue
+		}
+		switch value.(type) {
+		case string:
+			assert.Equal("string", property.Type, uri+" "+key)
+		case float64:
+			assert.Contains([]string{"number", "integer"}, property.Type, uri+" "+key)
+		case bool:
+			assert.Equal("boolean", property.Type, uri+" "+key)
+		}
+	}
+}
@@ -30,6 +30,22 @@ const (
 	SCHEMA_UNSTRUCT_EVENT = "iglu:com.snowplowanalytics.snowplow/unstruct_event/jsonschema/1-0-0"
 	SCHEMA_SCREEN_VIEW    = "iglu:com.snowplowanalytics.snowplow/screen_view/jsonschema/1-0-0"
 	SCHEMA_USER_TIMINGS   = "iglu:com.snowplowanalytics.snowplow/timing/jsonschema/1-0-0"
+	SCHEMA_SAMPLING       = "iglu:com.snowplowanalytics.golang/sampling/jsonschema/1-0-0"
+	SCHEMA_CLIENT_SESSION = "iglu:com.snowplowanalytics.snowplow/client_session/jsonschema/1-0-2"
+	SCHEMA_HOST           = "iglu:com.snowplowanalytics.golang/host/jsonschema/1-0-0"
+	SCHEMA_RUNTIME        = "iglu:com.snowplowanalytics.golang/runtime/jsonschema/1-0-0"
+	SCHEMA_APPLICATION    = "iglu:com.snowplowanalytics.golang/application/jsonschema/1-0-0"
+	SCHEMA_KUBERNETES     = "iglu:com.snowplowanalytics.golang/kubernetes/jsonschema/1-0-0"
+	SCHEMA_CONTAINER      = "iglu:com.snowplowanalytics.golang/container/jsonschema/1-0-0"
+
+	// Snowplow Ecommerce Schema Versions
+	SCHEMA_ECOMMERCE_ACTION        = "iglu:com.snowplowanalytics.snowplow.ecommerce/snowplow_ecommerce_action/jsonschema/1-0-2"
+	SCHEMA_ECOMMERCE_PRODUCT       = "iglu:com.snowplowanalytics.snowplow.ecommerce/product/jsonschema/1-0-0"
+	SCHEMA_ECOMMERCE_CART          = "iglu:com.snowplowanalytics.snowplow.ecommerce/cart/jsonschema/1-0-0"
+	SCHEMA_ECOMMERCE_TRANSACTION   = "iglu:com.snowplowanalytics.snowplow.ecommerce/transaction/jsonschema/1-0-0"
+	SCHEMA_ECOMMERCE_REFUND        = "iglu:com.snowplowanalytics.snowplow.ecommerce/refund/jsonschema/1-0-0"
+	SCHEMA_ECOMMERCE_CHECKOUT_STEP = "iglu:com.snowplowanalytics.snowplow.ecommerce/checkout_step/jsonschema/1-0-0"
+	SCHEMA_ECOMMERCE_PROMOTION     = "iglu:com.snowplowanalytics.snowplow.ecommerce/promotion/jsonschema/1-0-0"
 
 	// Event Types
 	EVENT_PAGE_VIEW    = "pv"
@@ -111,4 +127,109 @@ const (
 	UT_VARIABLE = "variable"
 	UT_TIMING   = "timing"
 	UT_LABEL    = "label"
+
+	// Sampling
+	SAMPLING_RATE = "sampleRate"
+
+	// Client Session
+	CS_USER_ID               = "userId"
+	CS_SESSION_ID            = "sessionId"
+	CS_SESSION_INDEX         = "sessionIndex"
+	CS_PREVIOUS_SESSION_ID   = "previousSessionId"
+	CS_STORAGE_MECHANISM     = "storageMechanism"
+	CS_FIRST_EVENT_ID        = "firstEventId"
+	CS_FIRST_EVENT_TIMESTAMP = "firstEventTimestamp"
+	CS_EVENT_INDEX           = "eventIndex"
+
+	// Built-in Entities
+	HOST_HOSTNAME        = "hostname"
+	RT_OS                = "os"
+	RT_ARCH              = "arch"
+	RT_GO_VERSION        = "goVersion"
+	APP_NAME             = "name"
+	APP_VERSION          = "version"
+	APP_VCS_REVISION     = "vcsRevision"
+	APP_VCS_TIME         = "vcsTime"
+	APP_VCS_MODIFIED     = "vcsModified"
+	BUILTIN_ENTITIES_TAG = "builtin-entities"
+
+	// Kubernetes Entities
+	K8S_POD_NAME            = "podName"
+	K8S_NAMESPACE           = "namespace"
+	K8S_NODE_NAME           = "nodeName"
+	K8S_CLUSTER_NAME        = "clusterName"
+	CONTAINER_NAME          = "name"
+	CONTAINER_IMAGE         = "image"
+	KUBERNETES_ENTITIES_TAG = "kubernetes-entities"
+
+	// Snowplow Ecommerce Actions
+	ECOMM_ACTION_ADD_TO_CART      = "add_to_cart"
+	ECOMM_ACTION_REMOVE_FROM_CART = "remove_from_cart"
+	ECOMM_ACTION_CHECKOUT_STEP    = "checkout_step"
+	ECOMM_ACTION_TRANSACTION      = "transaction"
+	ECOMM_ACTION_REFUND           = "refund"
+	ECOMM_ACTION_PROMO_VIEW       = "promo_view"
+	ECOMM_ACTION_PROMO_CLICK      = "promo_click"
+	ECOMM_ACTION_TYPE             = "type"
+
+	// Snowplow Ecommerce Product
+	ECOMM_PR_ID               = "id"
+	ECOMM_PR_NAME             = "name"
+	ECOMM_PR_CATEGORY         = "category"
+	ECOMM_PR_PRICE            = "price"
+	ECOMM_PR_LIST_PRICE       = "list_price"
+	ECOMM_PR_QUANTITY         = "quantity"
+	ECOMM_PR_SIZE             = "size"
+	ECOMM_PR_VARIANT          = "variant"
+	ECOMM_PR_BRAND            = "brand"
+	ECOMM_PR_INVENTORY_STATUS = "inventory_status"
+	ECOMM_PR_POSITION         = "position"
+	ECOMM_PR_CURRENCY         = "currency"
+	ECOMM_PR_CREATIVE_ID      = "creative_id"
+
+	// Snowplow Ecommerce Cart
+	ECOMM_CART_ID          = "cart_id"
+	ECOMM_CART_TOTAL_VALUE = "total_value"
+	ECOMM_CART_CURRENCY    = "currency"
+
+	// Snowplow Ecommerce Transaction
+	ECOMM_TR_ID              = "transaction_id"
+	ECOMM_TR_REVENUE         = "revenue"
+	ECOMM_TR_CURRENCY        = "currency"
+	ECOMM_TR_PAYMENT_METHOD  = "payment_method"
+	ECOMM_TR_TOTAL_QUANTITY  = "total_quantity"
+	ECOMM_TR_TAX             = "tax"
+	ECOMM_TR_SHIPPING        = "shipping"
+	ECOMM_TR_DISCOUNT_CODE   = "discount_code"
+	ECOMM_TR_DISCOUNT_AMOUNT = "discount_amount"
+	ECOMM_TR_CREDIT_ORDER    = "credit_order"
+
+	// Snowplow Ecommerce Refund
+	ECOMM_RF_TRANSACTION_ID = "transaction_id"
+	ECOMM_RF_CURRENCY       = "currency"
+	ECOMM_RF_REFUND_AMOUNT  = "refund_amount"
+	ECOMM_RF_REFUND_REASON  = "refund_reason"
+
+	// Snowplow Ecommerce Checkout Step
+	ECOMM_CS_STEP                  = "step"
+	ECOMM_CS_SHIPPING_POSTCODE     = "shipping_postcode"
+	ECOMM_CS_BILLING_POSTCODE      = "billing_postcode"
+	ECOMM_CS_SHIPPING_FULL_ADDRESS = "shipping_full_address"
+	ECOMM_CS_BILLING_FULL_ADDRESS  = "billing_full_address"
+	ECOMM_CS_DELIVERY_PROVIDER     = "delivery_provider"
+	ECOMM_CS_DELIVERY_METHOD       = "delivery_method"
+	ECOMM_CS_COUPON_CODE           = "coupon_code"
+	ECOMM_CS_ACCOUNT_TYPE          = "account_type"
+	ECOMM_CS_PAYMENT_METHOD        = "payment_method"
+	ECOMM_CS_PROOF_OF_PAYMENT      = "proof_of_payment"
+	ECOMM_CS_MARKETING_OPT_IN      = "marketing_opt_in"
+
+	// Snowplow Ecommerce Promotion
+	ECOMM_PROMO_ID          = "id"
+	ECOMM_PROMO_NAME        = "name"
+	ECOMM_PROMO_PRODUCT_IDS = "product_ids"
+	ECOMM_PROMO_POSITION    = "position"
+	ECOMM_PROMO_CREATIVE_ID = "creative_id"
+	ECOMM_PROMO_TYPE        = "type"
+	ECOMM_PROMO_SLOT        = "slot"
 )
@@ -0,0 +1,408 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+// --- Snowplow Ecommerce Entities
+
+type Product struct {
+	Id              *string  // Required
+	Category        *string  // Required
+	Price           *float64 // Required
+	Currency        *string  // Required, ISO 4217
+	Name            *string  // Optional
+	ListPrice       *float64 // Optional
+	Quantity        *int64   // Optional
+	Size            *string  // Optional
+	Variant         *string  // Optional
+	Brand           *string  // Optional
+	InventoryStatus *string  // Optional
+	Position        *int64   // Optional
+	CreativeId      *string  // Optional
+}
+
+// Init checks and validates the struct.
+func (p Product) Init() {
+	checkString(p.Id, "Product Id")
+	checkString(p.Category, "Product Category")
+	checkNumber(p.Price, "Product Price")
+	checkString(p.Currency, "Product Currency")
+}
+
+// Get returns the product entity.
+func (p Product) Get() SelfDescribingJson {
+	data := ecommerceData{}
+	data.add(ECOMM_PR_ID, p.Id)
+	data.add(ECOMM_PR_NAME, p.Name)
+	data.add(ECOMM_PR_CATEGORY, p.Category)
+	data.add(ECOMM_PR_PRICE, p.Price)
+	data.add(ECOMM_PR_LIST_PRICE, p.ListPrice)
+	data.add(ECOMM_PR_QUANTITY, p.Quantity)
+	data.add(ECOMM_PR_SIZE, p.Size)
+	data.add(ECOMM_PR_VARIANT, p.Variant)
+	data.add(ECOMM_PR_BRAND, p.Brand)
+	data.add(ECOMM_PR_INVENTORY_STATUS, p.InventoryStatus)
+	data.add(ECOMM_PR_POSITION, p.Position)
+	data.add(ECOMM_PR_CURRENCY, p.Currency)
+	data.add(ECOMM_PR_CREATIVE_ID, p.CreativeId)
+	return *InitSelfDescribingJson(SCHEMA_ECOMMERCE_PRODUCT, map[string]interface{}(data))
+}
+
+type Cart struct {
+	TotalValue *float64 // Required
+	Currency   *string  // Required, ISO 4217
+	CartId     *string  // Optional
+}
+
+// Init checks and validates the struct.
+func (c Cart) Init() {
+	checkNumber(c.TotalValue, "Cart TotalValue")
+	checkString(c.Currency, "Cart Currency")
+}
+
+// Get returns the cart entity.
+func (c Cart) Get() SelfDescribingJson {
+	data := ecommerceData{}
+	data.add(ECOMM_CART_ID, c.CartId)
+	data.add(ECOMM_CART_TOTAL_VALUE, c.TotalValue)
+	data.add(ECOMM_CART_CURRENCY, c.Currency)
+	return *InitSelfDescribingJson(SCHEMA_ECOMMERCE_CART, map[string]interface{}(data))
+}
+
+type Transaction struct {
+	TransactionId  *string  // Required
+	Revenue        *float64 // Required
+	Currency       *string  // Required, ISO 4217
+	PaymentMethod  *string  // Required
+	TotalQuantity  *int64   // Optional
+	Tax            *float64 // Optional
+	Shipping       *float64 // Optional
+	DiscountCode   *string  // Optional
+	DiscountAmount *float64 // Optional
+	CreditOrder    *bool    // Optional
+}
+
+// Init checks and validates the struct.
+func (tr Transaction) Init() {
+	checkString(tr.TransactionId, "Transaction TransactionId")
+	checkNumber(tr.Revenue, "Transaction Revenue")
+	checkString(tr.Currency, "Transaction Currency")
+	checkString(tr.PaymentMethod, "Transaction PaymentMethod")
+}
+
+// Get returns the transaction entity.
+func (tr Transaction) Get() SelfDescribingJson {
+	data := ecommerceData{}
+	data.add(ECOMM_TR_ID, tr.TransactionId)
+	data.add(ECOMM_TR_REVENUE, tr.Revenue)
+	data.add(ECOMM_TR_CURRENCY, tr.Currency)
+	data.add(ECOMM_TR_PAYMENT_METHOD, tr.PaymentMethod)
+	data.add(ECOMM_TR_TOTAL_QUANTITY, tr.TotalQuantity)
+	data.add(ECOMM_TR_TAX, tr.Tax)
+	data.add(ECOMM_TR_SHIPPING, tr.Shipping)
+	data.add(ECOMM_TR_DISCOUNT_CODE, tr.DiscountCode)
+	data.add(ECOMM_TR_DISCOUNT_AMOUNT, tr.DiscountAmount)
+	data.add(ECOMM_TR_CREDIT_ORDER, tr.CreditOrder)
+	return *InitSelfDescribingJson(SCHEMA_ECOMMERCE_TRANSACTION, map[string]interface{}(data))
+}
+
+type Refund struct {
+	TransactionId *string  // Required
+	RefundAmount  *float64 // Required
+	Currency      *string  // Required, ISO 4217
+	RefundReason  *string  // Optional
+}
+
+// Init checks and validates the struct.
+func (r Refund) Init() {
+	checkString(r.TransactionId, "Refund TransactionId")
+	checkNumber(r.RefundAmount, "Refund RefundAmount")
+	checkString(r.Currency, "Refund Currency")
+}
+
+// Get returns the refund entity.
+func (r Refund) Get() SelfDescribingJson {
+	data := ecommerceData{}
+	data.add(ECOMM_RF_TRANSACTION_ID, r.TransactionId)
+	data.add(ECOMM_RF_CURRENCY, r.Currency)
+	data.add(ECOMM_RF_REFUND_AMOUNT, r.RefundAmount)
+	data.add(ECOMM_RF_REFUND_REASON, r.RefundReason)
+	return *InitSelfDescribingJson(SCHEMA_ECOMMERCE_REFUND, map[string]interface{}(data))
+}
+
+type CheckoutStep struct {
+	Step                *int64  // Required, starting at 1
+	ShippingPostcode    *string // Optional
+	BillingPostcode     *string // Optional
+	ShippingFullAddress *string // Optional
+	BillingFullAddress  *string // Optional
+	DeliveryProvider    *string // Optional
+	DeliveryMethod      *string // Optional
+	CouponCode          *string // Optional
+	AccountType         *string // Optional
+	PaymentMethod       *string // Optional
+	ProofOfPayment      *string // Optional
+	MarketingOptIn      *bool   // Optional
+}
+
+// Init checks and validates the struct.
+func (c CheckoutStep) Init() {
+	if c.Step == nil || *c.Step < 1 {
+		panic("CheckoutStep Step cannot be nil or less than 1.")
+	}
+}
+
+// Get returns the checkout step entity.
+func (c CheckoutStep) Get() SelfDescribingJson {
+	data := ecommerceData{}
+	data.add(ECOMM_CS_STEP, c.Step)
+	data.add(ECOMM_CS_SHIPPING_POSTCODE, c.ShippingPostcode)
+	data.add(ECOMM_CS_BILLING_POSTCODE, c.BillingPostcode)
+	data.add(ECOMM_CS_SHIPPING_FULL_ADDRESS, c.ShippingFullAddress)
+	data.add(ECOMM_CS_BILLING_FULL_ADDRESS, c.BillingFullAddress)
+	data.add(ECOMM_CS_DELIVERY_PROVIDER, c.DeliveryProvider)
+	data.add(ECOMM_CS_DELIVERY_METHOD, c.DeliveryMethod)
+	data.add(ECOMM_CS_COUPON_CODE, c.CouponCode)
+	data.add(ECOMM_CS_ACCOUNT_TYPE, c.AccountType)
+	data.add(ECOMM_CS_PAYMENT_METHOD, c.PaymentMethod)
+	data.add(ECOMM_CS_PROOF_OF_PAYMENT, c.ProofOfPayment)
+	data.add(ECOMM_CS_MARKETING_OPT_IN, c.MarketingOptIn)
+	return *InitSelfDescribingJson(SCHEMA_ECOMMERCE_CHECKOUT_STEP, map[string]interface{}(data))
+}
+
+type Promotion struct {
+	Id         *string  // Required
+	Name       *string  // Optional
+	ProductIds []string // Optional
+	Position   *int64   // Optional
+	CreativeId *string  // Optional
+	Type       *string  // Optional
+	Slot       *string  // Optional
+}
+
+// Init checks and validates the struct.
+func (p Promotion) Init() {
+	checkString(p.Id, "Promotion Id")
+}
+
+// Get returns the promotion entity.
+func (p Promotion) Get() SelfDescribingJson {
+	data := ecommerceData{}
+	data.add(ECOMM_PROMO_ID, p.Id)
+	data.add(ECOMM_PROMO_NAME, p.Name)
+	data.add(ECOMM_PROMO_PRODUCT_IDS, p.ProductIds)
+	data.add(ECOMM_PROMO_POSITION, p.Position)
+	data.add(ECOMM_PROMO_CREATIVE_ID, p.CreativeId)
+	data.add(ECOMM_PROMO_TYPE, p.Type)
+	data.add(ECOMM_PROMO_SLOT, p.Slot)
+	return *InitSelfDescribingJson(SCHEMA_ECOMMERCE_PROMOTION, map[string]interface{}(data))
+}
+
+// --- Snowplow Ecommerce Events
+
+// CartEvent is sent when products are added to or removed from a cart.
+type CartEvent struct {
+	Products      []Product            // Required
+	Cart          *Cart                // Required
+	Timestamp     *int64               // Optional
+	EventId       *string              // Optional
+	TrueTimestamp *int64               // Optional
+	Contexts      []SelfDescribingJson // Optional
+	Subject       *Subject             // Optional
+}
+
+// Init checks and validates the struct.
+func (e *CartEvent) Init() {
+	if len(e.Products) == 0 {
+		panic("Products cannot be empty.")
+	}
+	for _, product := range e.Products {
+		product.Init()
+	}
+	if e.Cart == nil {
+		panic("Cart cannot be nil.")
+	}
+	e.Cart.Init()
+}
+
+// Get returns the event as a self-describing event with the action.
+func (e CartEvent) Get(action string) SelfDescribingEvent {
+	entities := productEntities(e.Products)
+	entities = append(entities, e.Cart.Get())
+	return ecommerceEvent(action, entities, e.Timestamp, e.EventId, e.TrueTimestamp, e.Contexts, e.Subject)
+}
+
+type CheckoutStepEvent struct {
+	CheckoutStep  *CheckoutStep        // Required
+	Timestamp     *int64               // Optional
+	EventId       *string              // Optional
+	TrueTimestamp *int64               // Optional
+	Contexts      []SelfDescribingJson // Optional
+	Subject       *Subject             // Optional
+}
+
+// Init checks and validates the struct.
+func (e *CheckoutStepEvent) Init() {
+	if e.CheckoutStep == nil {
+		panic("CheckoutStep cannot be nil.")
+	}
+	e.CheckoutStep.Init()
+}
+
+// Get returns the event as a self-describing event.
+func (e CheckoutStepEvent) Get() SelfDescribingEvent {
+	entities := []SelfDescribingJson{e.CheckoutStep.Get()}
+	return ecommerceEvent(ECOMM_ACTION_CHECKOUT_STEP, entities, e.Timestamp, e.EventId, e.TrueTimestamp, e.Contexts, e.Subject)
+}
+
+type TransactionEvent struct {
+	Transaction   *Transaction         // Required
+	Products      []Product            // Optional
+	Timestamp     *int64               // Optional
+	EventId       *string              // Optional
+	TrueTimestamp *int64               // Optional
+	Contexts      []SelfDescribingJson // Optional
+	Subject       *Subject             // Optional
+}
+
+// Init checks and validates the struct.
+func (e *TransactionEvent) Init() {
+	if e.Transaction == nil {
+		panic("Transaction cannot be nil.")
+	}
+	e.Transaction.Init()
+	for _, product := range e.Products {
+		product.Init()
+	}
+}
+
+// Get returns the event as a self-describing event.
+func (e TransactionEvent) Get() SelfDescribingEvent {
+	entities := append([]SelfDescribingJson{e.Transaction.Get()}, productEntities(e.Products)...)
+	return ecommerceEvent(ECOMM_ACTION_TRANSACTION, entities, e.Timestamp, e.EventId, e.TrueTimestamp, e.Contexts, e.Subject)
+}
+
+type RefundEvent struct {
+	Refund        *Refund              // Required
+	Products      []Product            // Optional, the products refunded
+	Timestamp     *int64               // Optional
+	EventId       *string              // Optional
+	TrueTimestamp *int64               // Optional
+	Contexts      []SelfDescribingJson // Optional
+	Subject       *Subject             // Optional
+}
+
+// Init checks and validates the struct.
+func (e *RefundEvent) Init() {
+	if e.Refund == nil {
+		panic("Refund cannot be nil.")
+	}
+	e.Refund.Init()
+	for _, product := range e.Products {
+		product.Init()
+	}
+}
+
+// Get returns the event as a self-describing event.
+func (e RefundEvent) Get() SelfDescribingEvent {
+	entities := append([]SelfDescribingJson{e.Refund.Get()}, productEntities(e.Products)...)
+	return ecommerceEvent(ECOMM_ACTION_REFUND, entities, e.Timestamp, e.EventId, e.TrueTimestamp, e.Contexts, e.Subject)
+}
+
+// PromotionEvent is sent when a promotion is viewed or clicked.
+type PromotionEvent struct {
+	Promotion     *Promotion           // Required
+	Timestamp     *int64               // Optional
+	EventId       *string              // Optional
+	TrueTimestamp *int64               // Optional
+	Contexts      []SelfDescribingJson // Optional
+	Subject       *Subject             // Optional
+}
+
+// Init checks and validates the struct.
+func (e *PromotionEvent) Init() {
+	if e.Promotion == nil {
+		panic("Promotion cannot be nil.")
+	}
+	e.Promotion.Init()
+}
+
+// Get returns the event as a self-describing event with the action.
+func (e PromotionEvent) Get(action string) SelfDescribingEvent {
+	entities := []SelfDescribingJson{e.Promotion.Get()}
+	return ecommerceEvent(action, entities, e.Timestamp, e.EventId, e.TrueTimestamp, e.Contexts, e.Subject)
+}
+
+// --- Helpers
+
+// ecommerceEvent builds a SCHEMA_ECOMMERCE_ACTION event with the ecommerce
+// entities attached before the contexts of the event.
+func ecommerceEvent(action string, entities []SelfDescribingJson, timestamp *int64, eventId *string, trueTimestamp *int64, contexts []SelfDescribingJson, subject *Subject) SelfDescribingEvent {
+	sdj := InitSelfDescribingJson(SCHEMA_ECOMMERCE_ACTION, map[string]interface{}{ECOMM_ACTION_TYPE: action})
+	return SelfDescribingEvent{
+		Event:         sdj,
+		Timestamp:     timestamp,
+		EventId:       eventId,
+		TrueTimestamp: trueTimestamp,
+		Contexts:      append(entities, contexts...),
+		Subject:       subject,
+	}
+}
+
+func productEntities(products []Product) []SelfDescribingJson {
+	entities := []SelfDescribingJson{}
+	for _, product := range products {
+		entities = append(entities, product.Get())
+	}
+	return entities
+}
+
+// ecommerceData is the data of an entity, which leaves out unset fields.
+type ecommerceData map[string]interface{}
+
+func (d ecommerceData) add(key string, value interface{}) {
+	switch v := value.(type) {
+	case *string:
+		if v != nil && *v != "" {
+			d[key] = *v
+		}
+	case *float64:
+		if v != nil {
+			d[key] = *v
+		}
+	case *int64:
+		if v != nil {
+			d[key] = *v
+		}
+	case *bool:
+		if v != nil {
+			d[key] = *v
+		}
+	case []string:
+		if len(v) > 0 {
+			d[key] = v
+		}
+	}
+}
+
+func checkString(value *string, name string) {
+	if value == nil || *value == "" {
+		panic(name + " cannot be nil or empty.")
+	}
+}
+
+func checkNumber(value *float64, name string) {
+	if value == nil {
+		panic(name + " cannot be nil.")
+	}
+}
@@ -0,0 +1,142 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+)
+
+func TestEcommerceEntities(t *testing.T) {
+	assert := assert.New(t)
+
+	product := Product{
+		Id:        common.NewString("sku-1"),
+		Category:  common.NewString("shoes"),
+		Price:     common.NewFloat64(59.99),
+		Currency:  common.NewString("EUR"),
+		Quantity:  common.NewInt64(2),
+		Brand:     common.NewString(""),
+		ListPrice: common.NewFloat64(0),
+	}
+	product.Init()
+	assert.Equal(`{"data":{"category":"shoes","currency":"EUR","id":"sku-1","list_price":0,"price":59.99,"quantity":2},"schema":"`+SCHEMA_ECOMMERCE_PRODUCT+`"}`, product.Get().String())
+
+	cart := Cart{TotalValue: common.NewFloat64(119.98), Currency: common.NewString("EUR")}
+	assert.Equal(`{"data":{"currency":"EUR","total_value":119.98},"schema":"`+SCHEMA_ECOMMERCE_CART+`"}`, cart.Get().String())
+
+	transaction := Transaction{
+		TransactionId: common.NewString("order-1"),
+		Revenue:       common.NewFloat64(119.98),
+		Currency:      common.NewString("EUR"),
+		PaymentMethod: common.NewString("card"),
+		CreditOrder:   common.NewBool(false),
+	}
+	assert.Equal(`{"data":{"credit_order":false,"currency":"EUR","payment_method":"card","revenue":119.98,"transaction_id":"order-1"},"schema":"`+SCHEMA_ECOMMERCE_TRANSACTION+`"}`, transaction.Get().String())
+
+	refund := Refund{TransactionId: common.NewString("order-1"), RefundAmount: common.NewFloat64(59.99), Currency: common.NewString("EUR"), RefundReason: common.NewString("damaged")}
+	assert.Equal(`{"data":{"currency":"EUR","refund_amount":59.99,"refund_reason":"damaged","transaction_id":"order-1"},"schema":"`+SCHEMA_ECOMMERCE_REFUND+`"}`, refund.Get().String())
+
+	step := CheckoutStep{Step: common.NewInt64(2), DeliveryMethod: common.NewString("express"), MarketingOptIn: common.NewBool(true)}
+	assert.Equal(`{"data":{"delivery_method":"express","marketing_opt_in":true,"step":2},"schema":"`+SCHEMA_ECOMMERCE_CHECKOUT_STEP+`"}`, step.Get().String())
+
+	promotion := Promotion{Id: common.NewString("summer"), ProductIds: []string{"sku-1", "sku-2"}, Position: common.NewInt64(1)}
+	assert.Equal(`{"data":{"id":"summer","position":1,"product_ids":["sku-1","sku-2"]},"schema":"`+SCHEMA_ECOMMERCE_PROMOTION+`"}`, promotion.Get().String())
+}
+
+func TestEcommerceInitPanics(t *testing.T) {
+	assert := assert.New(t)
+	product := Product{Id: common.NewString("sku-1"), Category: common.NewString("shoes"), Price: common.NewFloat64(59.99), Currency: common.NewString("EUR")}
+	cart := &Cart{TotalValue: common.NewFloat64(59.99), Currency: common.NewString("EUR")}
+
+	assert.PanicsWithValue("Product Id cannot be nil or empty.", func() { Product{}.Init() })
+	assert.PanicsWithValue("Product Price cannot be nil.", func() {
+		Product{Id: common.NewString("sku-1"), Category: common.NewString("shoes")}.Init()
+	})
+	assert.PanicsWithValue("Cart Currency cannot be nil or empty.", func() { Cart{TotalValue: common.NewFloat64(1)}.Init() })
+	assert.PanicsWithValue("Transaction PaymentMethod cannot be nil or empty.", func() {
+		Transaction{TransactionId: common.NewString("order-1"), Revenue: common.NewFloat64(1), Currency: common.NewString("EUR")}.Init()
+	})
+	assert.PanicsWithValue("Refund RefundAmount cannot be nil.", func() { Refund{TransactionId: common.NewString("order-1")}.Init() })
+	assert.PanicsWithValue("CheckoutStep Step cannot be nil or less than 1.", func() { CheckoutStep{Step: common.NewInt64(0)}.Init() })
+	assert.PanicsWithValue("Promotion Id cannot be nil or empty.", func() { Promotion{}.Init() })
+
+	assert.PanicsWithValue("Products cannot be empty.", func() { (&CartEvent{Cart: cart}).Init() })
+	assert.PanicsWithValue("Cart cannot be nil.", func() { (&CartEvent{Products: []Product{product}}).Init() })
+	assert.PanicsWithValue("Product Category cannot be nil or empty.", func() {
+		(&TransactionEvent{
+			Transaction: &Transaction{TransactionId: common.NewString("order-1"), Revenue: common.NewFloat64(1), Currency: common.NewString("EUR"), PaymentMethod: common.NewString("card")},
+			Products:    []Product{{Id: common.NewString("sku-2")}},
+		}).Init()
+	})
+	assert.PanicsWithValue("CheckoutStep cannot be nil.", func() { (&CheckoutStepEvent{}).Init() })
+	assert.PanicsWithValue("Transaction cannot be nil.", func() { (&TransactionEvent{}).Init() })
+	assert.PanicsWithValue("Refund cannot be nil.", func() { (&RefundEvent{}).Init() })
+	assert.PanicsWithValue("Promotion cannot be nil.", func() { (&PromotionEvent{}).Init() })
+}
+
+func TestTrackEcommerceActions(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	tracker, sent := initCapturingTracker()
+	product := Product{Id: common.NewString("sku-1"), Category: common.NewString("shoes"), Price: common.NewFloat64(59.99), Currency: common.NewString("EUR")}
+	cart := &Cart{TotalValue: common.NewFloat64(59.99), Currency: common.NewString("EUR")}
+	transaction := &Transaction{TransactionId: common.NewString("order-1"), Revenue: common.NewFloat64(59.99), Currency: common.NewString("EUR"), PaymentMethod: common.NewString("card")}
+	refund := &Refund{TransactionId: common.NewString("order-1"), RefundAmount: common.NewFloat64(59.99), Currency: common.NewString("EUR")}
+	promotion := &Promotion{Id: common.NewString("summer")}
+	user := *InitSelfDescribingJson("iglu:com.acme/user/jsonschema/1-0-0", map[string]interface{}{"name": "jane"})
+
+	assert.Nil(tracker.TrackAddToCart(CartEvent{Products: []Product{product}, Cart: cart, Contexts: []SelfDescribingJson{user}}))
+	assert.Nil(tracker.TrackRemoveFromCart(CartEvent{Products: []Product{product, product}, Cart: cart}))
+	assert.Nil(tracker.TrackCheckoutStep(CheckoutStepEvent{CheckoutStep: &CheckoutStep{Step: common.NewInt64(1)}}))
+	assert.Nil(tracker.TrackTransaction(TransactionEvent{Transaction: transaction, Products: []Product{product}}))
+	assert.Nil(tracker.TrackRefund(RefundEvent{Refund: refund}))
+	assert.Nil(tracker.TrackPromotionView(PromotionEvent{Promotion: promotion}))
+	assert.Nil(tracker.TrackPromotionClick(PromotionEvent{Promotion: promotion, EventId: common.NewString("event-1")}))
+
+	expected := []struct {
+		action   string
+		entities []string
+	}{
+		{ECOMM_ACTION_ADD_TO_CART, []string{SCHEMA_ECOMMERCE_PRODUCT, SCHEMA_ECOMMERCE_CART, "iglu:com.acme/user/jsonschema/1-0-0"}},
+		{ECOMM_ACTION_REMOVE_FROM_CART, []string{SCHEMA_ECOMMERCE_PRODUCT, SCHEMA_ECOMMERCE_PRODUCT, SCHEMA_ECOMMERCE_CART}},
+		{ECOMM_ACTION_CHECKOUT_STEP, []string{SCHEMA_ECOMMERCE_CHECKOUT_STEP}},
+		{ECOMM_ACTION_TRANSACTION, []string{SCHEMA_ECOMMERCE_TRANSACTION, SCHEMA_ECOMMERCE_PRODUCT}},
+		{ECOMM_ACTION_REFUND, []string{SCHEMA_ECOMMERCE_REFUND}},
+		{ECOMM_ACTION_PROMO_VIEW, []string{SCHEMA_ECOMMERCE_PROMOTION}},
+		{ECOMM_ACTION_PROMO_CLICK, []string{SCHEMA_ECOMMERCE_PROMOTION}},
+	}
+	assert.Equal(len(expected), len(*sent))
+	for i, event := range *sent {
+		assert.Equal(EVENT_UNSTRUCTURED, event[EVENT])
+		var unstructured struct {
+			Data struct {
+				Schema string            `json:"schema"`
+				Data   map[string]string `json:"data"`
+			} `json:"data"`
+		}
+		assert.Nil(json.Unmarshal([]byte(event[UNSTRUCTURED]), &unstructured))
+		assert.Equal(SCHEMA_ECOMMERCE_ACTION, unstructured.Data.Schema)
+		assert.Equal(map[string]string{ECOMM_ACTION_TYPE: expected[i].action}, unstructured.Data.Data)
+		assert.Equal(expected[i].entities, contextSchemas(t, event))
+	}
+	assert.Equal("event-1", (*sent)[6][EID])
+}
@@ -14,7 +14,7 @@
 package tracker
 
 import (
-	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -36,10 +36,12 @@ const (
 	DEFAULT_BYTE_LIMIT_GET  = 40000
 	DEFAULT_BYTE_LIMIT_POST = 40000
 	DEFAULT_DB_NAME         = "events.db"
-	POST_WRAPPER_BYTES      = 88 // "schema":"iglu:com.snowplowanalytics.snowplow/payload_data/jsonschema/1-0-3","data":[]
-	POST_STM_BYTES          = 22 // "stm":"1443452851000"
+	POST_WRAPPER_BYTES      = 88 // {"data":[],"schema":"iglu:com.snowplowanalytics.snowplow/payload_data/jsonschema/1-0-4"}
 )
 
+// ErrStorageAdd is returned when the Storage fails to add an event.
+var ErrStorageAdd = errors.New("event could not be added to storage")
+
 type SendResult struct {
 	ids    []int
 	status int
@@ -62,6 +64,25 @@ type Emitter struct {
 	SendChannel   chan bool
 	Callback      func(successCount []CallbackResult, failureCount []CallbackResult)
 	HttpClient    *http.Client
+
+	// Back-pressure, applied once the Emitter holds MaxQueueSize events.
+	// A MaxQueueSize of 0 leaves the queue unbounded.
+	MaxQueueSize      int
+	QueuePolicy       QueuePolicy
+	QueueBlockTimeout time.Duration
+	queue             *queue
+
+	// Events are buffered and written to Storage in the background once
+	// AsyncBufferSize is above 0.
+	AsyncBufferSize int
+	ingest          *ingest
+	sender          *sender
+
+	// Events are sent straight away on the caller's goroutine, without
+	// Storage, when Synchronous is true.
+	Synchronous  bool
+	MaxRetries   int
+	RetryBackoff time.Duration
 }
 
 // InitEmitter creates a new Emitter object which handles
@@ -75,6 +96,10 @@ func InitEmitter(options ...func(*Emitter)) *Emitter {
 	e.SendLimit = DEFAULT_SEND_LIMIT
 	e.ByteLimitGet = DEFAULT_BYTE_LIMIT_GET
 	e.ByteLimitPost = DEFAULT_BYTE_LIMIT_POST
+	e.QueuePolicy = DEFAULT_QUEUE_POLICY
+	e.QueueBlockTimeout = DEFAULT_QUEUE_BLOCK_TIMEOUT
+	e.MaxRetries = DEFAULT_MAX_RETRIES
+	e.RetryBackoff = DEFAULT_RETRY_BACKOFF
 
 	// Option parameters
 	for _, op := range options {
@@ -94,9 +119,37 @@ func InitEmitter(options ...func(*Emitter)) *Emitter {
 	}
 
 	// Setup default event storage
-	if e.Storage == nil {
+	if e.Storage == nil && !e.Synchronous {
 		panic("FATAL: Storage must be defined.")
 	}
+	if e.Synchronous && (e.MaxQueueSize != 0 || e.AsyncBufferSize != 0) {
+		panic("FATAL: Synchronous cannot be combined with MaxQueueSize or AsyncBufferSize.")
+	}
+	if e.MaxRetries < 0 {
+		panic("FATAL: MaxRetries cannot be negative.")
+	}
+
+	// Count the events already queued so the bound holds across restarts
+	if e.MaxQueueSize < 0 {
+		panic("FATAL: MaxQueueSize cannot be negative.")
+	} else if e.MaxQueueSize > 0 {
+		if e.QueuePolicy < QUEUE_POLICY_BLOCK || e.QueuePolicy > QUEUE_POLICY_DROP {
+			panic("FATAL: QueuePolicy did not match BLOCK, ERROR or DROP.")
+		}
+		if e.QueueBlockTimeout <= 0 {
+			panic("FATAL: QueueBlockTimeout must be above 0.")
+		}
+		e.queue = newQueue(countEventRows(e.Storage))
+	}
+
+	if e.AsyncBufferSize < 0 {
+		panic("FATAL: AsyncBufferSize cannot be negative.")
+	}
+	e.sender = &sender{}
+	e.ingest = newIngest(e.AsyncBufferSize)
+	if e.AsyncBufferSize > 0 {
+		go e.writeLoop()
+	}
 
 	// Setup HttpClient
 	if e.HttpClient == nil {
@@ -106,13 +159,13 @@ func InitEmitter(options ...func(*Emitter)) *Emitter {
 		if !ok {
 			panic(fmt.Sprintf("defaultRoundTripper not an *http.Transport"))
 		}
-		defaultTransport := *defaultTransportPointer
+		defaultTransport := defaultTransportPointer.Clone()
 		defaultTransport.MaxIdleConns = 100
 		defaultTransport.MaxIdleConnsPerHost = 100
 		timeout := time.Duration(5 * time.Second)
 		e.HttpClient = &http.Client{
 			Timeout:   timeout,
-			Transport: &defaultTransport,
+			Transport: defaultTransport,
 		}
 	}
 
@@ -168,12 +221,82 @@ func OptionHttpClient(client *http.Client) func(e *Emitter) {
 	return func(e *Emitter) { e.HttpClient = client }
 }
 
+// OptionMaxQueueSize sets how many events the Emitter may hold before the QueuePolicy is applied.
+func OptionMaxQueueSize(maxQueueSize int) func(e *Emitter) {
+	return func(e *Emitter) { e.MaxQueueSize = maxQueueSize }
+}
+
+// OptionQueuePolicy sets what happens to events added while the queue is full.
+func OptionQueuePolicy(queuePolicy QueuePolicy) func(e *Emitter) {
+	return func(e *Emitter) { e.QueuePolicy = queuePolicy }
+}
+
+// OptionQueueBlockTimeout sets how long QUEUE_POLICY_BLOCK waits for space
+// before giving up, DEFAULT_QUEUE_BLOCK_TIMEOUT unless set. It must be above 0
+// so that a collector outage cannot block Add forever; AddContext can give up
+// sooner.
+func OptionQueueBlockTimeout(timeout time.Duration) func(e *Emitter) {
+	return func(e *Emitter) { e.QueueBlockTimeout = timeout }
+}
+
+// OptionAsync makes Add buffer up to bufferSize events and return straight
+// away, leaving a background goroutine to write them to Storage in batches.
+// Close must be called before exiting so that buffered events are not lost.
+func OptionAsync(bufferSize int) func(e *Emitter) {
+	return func(e *Emitter) { e.AsyncBufferSize = bufferSize }
+}
+
+// OptionSynchronous makes Add send each event to the collector before
+// returning, reporting whether it was accepted. Storage is not used, so
+// events which still fail after MaxRetries are reported and discarded, as
+// are events which exceed the byte limits.
+func OptionSynchronous(synchronous bool) func(e *Emitter) {
+	return func(e *Emitter) { e.Synchronous = synchronous }
+}
+
+// OptionMaxRetries sets how many times a synchronous send is retried.
+func OptionMaxRetries(maxRetries int) func(e *Emitter) {
+	return func(e *Emitter) { e.MaxRetries = maxRetries }
+}
+
+// OptionRetryBackoff sets how long to wait before the first retry of a synchronous send,
+// and between attempts to send the remaining events during Shutdown.
+func OptionRetryBackoff(backoff time.Duration) func(e *Emitter) {
+	return func(e *Emitter) { e.RetryBackoff = backoff }
+}
+
 // --- Event Handlers
 
 // Add will push an event to the database and will then initiate a sending loop.
-func (e *Emitter) Add(payload payload.Payload) {
-	e.Storage.AddEventRow(payload)
-	e.start()
+//
+// If the queue is full the QueuePolicy is applied: the error is ErrQueueFull
+// if the event was rejected or could not be queued within the block timeout.
+// The error is ErrEmitterClosed once Close has been called.
+//
+// A synchronous Emitter returns a *SendError if the collector did not accept the
+// event, or ErrEventTooLarge without sending it if it exceeds the byte limits.
+func (e *Emitter) Add(payload payload.Payload) error {
+	return e.AddContext(context.Background(), payload)
+}
+
+// AddContext is Add with a context which can end the QUEUE_POLICY_BLOCK wait
+// for space before QueueBlockTimeout, or how long a synchronous send keeps retrying.
+func (e *Emitter) AddContext(ctx context.Context, payload payload.Payload) error {
+	if e.Synchronous {
+		return e.sendNow(ctx, payload)
+	}
+
+	ok, err := e.acquire(ctx)
+	if !ok {
+		return err
+	}
+	if err := e.write(ctx, payload); err != nil {
+		if e.queue != nil {
+			e.queue.release(1)
+		}
+		return err
+	}
+	return nil
 }
 
 // Flush will attempt to start the send loop regardless of an event coming in.
@@ -183,101 +306,52 @@ func (e *Emitter) Flush() {
 
 // Stop waits for the send channel to have a value and then resets it to nil.
 func (e *Emitter) Stop() {
-	<-e.SendChannel
-	e.SendChannel = nil
-}
-
-// start will begin the sending loop.
-func (e *Emitter) start() {
-	if e.SendChannel == nil || !e.IsSending() {
-		e.SendChannel = make(chan bool, 1)
-		go func() {
-			var done bool
-			defer func() {
-				e.SendChannel <- done
-			}()
-
-			for {
-				eventRows := e.Storage.GetEventRowsWithinRange(e.SendLimit)
-
-				// If there are no events in the database exit
-				if len(eventRows) == 0 {
-					break
-				}
-				results := e.doSend(eventRows)
-
-				// Process results
-				ids := []int{}
-				successes := []CallbackResult{}
-				failures := []CallbackResult{}
-
-				for _, res := range results {
-
-					count := len(res.ids)
-					status := res.status
-
-					if status >= 200 && status < 400 {
-						ids = append(ids, res.ids...)
-						successes = append(successes, CallbackResult{count, status})
-					} else {
-						failures = append(failures, CallbackResult{count, status})
-					}
-				}
-
-				if e.Callback != nil {
-					e.Callback(successes, failures)
-				}
-
-				// If all the events failed to be sent exit
-				if len(successes) == 0 && len(failures) > 0 {
-					break
-				}
-
-				e.Storage.DeleteEventRows(ids)
-			}
-			done = true
-		}()
+	e.sender.lock.Lock()
+	sendChannel := e.SendChannel
+	e.sender.lock.Unlock()
+	if sendChannel == nil {
+		return
 	}
+
+	<-sendChannel
+	e.sender.lock.Lock()
+	if e.SendChannel == sendChannel {
+		e.SendChannel = nil
+	}
+	e.sender.lock.Unlock()
 }
 
 // doSend will send all of the eventsRows it is given.
-func (e *Emitter) doSend(eventRows []storageiface.EventRow) []SendResult {
+func (e *Emitter) doSend(ctx context.Context, eventRows []storageiface.EventRow) []SendResult {
 	futures := []<-chan SendResult{}
 	url := e.GetCollectorUrl()
 
 	if e.RequestType == "POST" {
-		ids := []int{}
-		payloads := []payload.Payload{}
-		totalByteSize := 0
-
+		// Measure the events exactly as they will be encoded; the sent
+		// timestamp is written with the same length when the body is built
+		events := []payload.Payload{}
 		for _, val := range eventRows {
-			byteSize := common.CountBytesInString(val.Event.String()) + POST_STM_BYTES
-			if byteSize+POST_WRAPPER_BYTES > e.ByteLimitPost {
-				// A single payload has exceeded the Byte Limit
-				futures = append(futures, e.sendPostRequest(url, []int{val.Id}, []payload.Payload{val.Event}, true))
-			} else if (totalByteSize + byteSize + POST_WRAPPER_BYTES + (len(payloads) - 1)) > e.ByteLimitPost {
-				// Byte limit reached
-				futures = append(futures, e.sendPostRequest(url, ids, payloads, false))
-
-				// Reset accumulators
-				ids = []int{val.Id}
-				payloads = []payload.Payload{val.Event}
-				totalByteSize = byteSize
-			} else {
-				ids = append(ids, val.Id)
-				payloads = append(payloads, val.Event)
-				totalByteSize += byteSize
-			}
+			events = append(events, val.Event)
 		}
-		if len(payloads) > 0 {
-			futures = append(futures, e.sendPostRequest(url, ids, payloads, false))
+		sizes := eventSizes(events, common.GetTimestampString())
+
+		requests, oversize := packPostRequests(sizes, e.ByteLimitPost)
+		for _, i := range oversize {
+			// A single payload has exceeded the Byte Limit
+			futures = append(futures, e.sendPostRequest(ctx, url, []int{eventRows[i].Id}, []payload.Payload{eventRows[i].Event}, true))
+		}
+		for _, request := range requests {
+			ids := []int{}
+			payloads := []payload.Payload{}
+			for _, i := range request {
+				ids = append(ids, eventRows[i].Id)
+				payloads = append(payloads, eventRows[i].Event)
+			}
+			futures = append(futures, e.sendPostRequest(ctx, url, ids, payloads, false))
 		}
 	} else if e.RequestType == "GET" {
 		for _, val := range eventRows {
-			val.Event.Add(SENT_TIMESTAMP, common.NewString(common.GetTimestampString()))
-			queryString := common.MapToQueryParams(val.Event.Get()).Encode()
-			oversize := common.CountBytesInString(queryString) > e.ByteLimitGet
-			futures = append(futures, e.sendGetRequest(url+"?"+queryString, []int{val.Id}, oversize))
+			futures = append(futures, e.sendGetEventRequest(ctx, url, []int{val.Id}, val.Event))
 		}
 	}
 
@@ -290,85 +364,140 @@ func (e *Emitter) doSend(eventRows []storageiface.EventRow) []SendResult {
 	return results
 }
 
-// SendGetRequest sends a payload to the collector endpoint via GET.
-func (e *Emitter) sendGetRequest(url string, ids []int, oversize bool) <-chan SendResult {
-	c := make(chan SendResult, 1)
-	go func() {
-		var result SendResult
-		defer func() {
-			c <- result
-		}()
-
-		status := -1
-		if oversize {
-			status = 200
+// readEventRows returns up to SendLimit events from Storage which can be sent.
+// Rows whose payload is empty, such as rows written with a codec this build
+// cannot decode, are logged and read past; they are left in Storage so that a
+// build which can decode them may still send them.
+func (e *Emitter) readEventRows() []storageiface.EventRow {
+	want := e.SendLimit
+	for {
+		eventRows := e.Storage.GetEventRowsWithinRange(want)
+		kept := []storageiface.EventRow{}
+		skipped := []int{}
+		for _, row := range eventRows {
+			if len(row.Event.Get()) == 0 {
+				skipped = append(skipped, row.Id)
+			} else if len(kept) < e.SendLimit {
+				kept = append(kept, row)
+			}
 		}
-
-		req, _ := http.NewRequest("GET", url, nil)
-
-		resp, err := e.HttpClient.Do(req)
-		if err != nil {
-			log.Println(err.Error())
-			result = SendResult{ids: ids, status: status}
-			return
+		if len(kept) == e.SendLimit || len(eventRows) < want {
+			if len(skipped) > 0 {
+				log.Printf("Skipping %d stored events with an empty payload %v", len(skipped), skipped)
+			}
+			return kept
 		}
-		io.CopyN(ioutil.Discard, resp.Body, 512)
-		resp.Body.Close()
+		want += e.SendLimit - len(kept)
+	}
+}
 
-		status = resp.StatusCode
-		if oversize {
-			status = 200
-		}
-		result = SendResult{ids: ids, status: status}
-	}()
+// SendGetRequest sends a payload to the collector endpoint via GET.
+func (e *Emitter) sendGetRequest(ctx context.Context, url string, ids []int, oversize bool) <-chan SendResult {
+	c := make(chan SendResult, 1)
+	go func() { c <- e.getRequest(ctx, url, ids, oversize) }()
+	return c
+}
+
+// sendGetEventRequest sends a single event to the collector endpoint via GET,
+// falling back to POST if the event is too large for GET.
+func (e *Emitter) sendGetEventRequest(ctx context.Context, url string, ids []int, event payload.Payload) <-chan SendResult {
+	c := make(chan SendResult, 1)
+	go func() { c <- e.getEventRequest(ctx, url, ids, event) }()
 	return c
 }
 
 // SendPostRequest sends an array of Payloads together to the collector endpoint via POST.
-func (e *Emitter) sendPostRequest(url string, ids []int, body []payload.Payload, oversize bool) <-chan SendResult {
+func (e *Emitter) sendPostRequest(ctx context.Context, url string, ids []int, body []payload.Payload, oversize bool) <-chan SendResult {
 	c := make(chan SendResult, 1)
-	go func() {
-		var result SendResult
-		defer func() {
-			c <- result
-		}()
-
-		status := -1
-		if oversize {
-			status = 200
-		}
+	go func() { c <- e.postRequest(ctx, url, ids, body, oversize) }()
+	return c
+}
 
-		postEnvelope := map[string]interface{}{
-			SCHEMA: SCHEMA_PAYLOAD_DATA,
-			DATA:   addSentTimeToEvents(body),
-		}
+// getEventRequest sends a single event to the collector endpoint via GET on the calling goroutine.
+//
+// If the query string exceeds ByteLimitGet the event is sent on its own via
+// POST to the tp2 endpoint instead, subject to ByteLimitPost.
+func (e *Emitter) getEventRequest(ctx context.Context, url string, ids []int, event payload.Payload) SendResult {
+	params := common.MapToQueryParams(event.Get())
+	params.Set(SENT_TIMESTAMP, common.GetTimestampString())
+	queryString := params.Encode()
+	if common.CountBytesInString(queryString) <= e.ByteLimitGet {
+		return e.getRequest(ctx, url+"?"+queryString, ids, false)
+	}
+
+	postUrl, err := returnCollectorUrl("POST", e.Protocol, e.CollectorUri)
+	if err != nil {
+		log.Println(err.Error())
+		return SendResult{ids: ids, status: -1}
+	}
+	events := []payload.Payload{event}
+	oversize := postBytes(eventSizes(events, common.GetTimestampString())...) > e.ByteLimitPost
+	return e.postRequest(ctx, postUrl.String(), ids, events, oversize)
+}
 
-		req, _ := http.NewRequest("POST", url, bytes.NewBufferString(common.MapToJson(postEnvelope)))
-		req.Header.Set("Content-Type", POST_CONTENT_TYPE)
+// getRequest sends a payload to the collector endpoint via GET on the calling goroutine.
+func (e *Emitter) getRequest(ctx context.Context, url string, ids []int, oversize bool) SendResult {
+	status := -1
+	if oversize {
+		status = 200
+	}
 
-		resp, err := e.HttpClient.Do(req)
-		if err != nil {
-			log.Println(err.Error())
-			result = SendResult{ids: ids, status: status}
-			return
-		}
-		io.CopyN(ioutil.Discard, resp.Body, 512)
-		resp.Body.Close()
+	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
 
-		status = resp.StatusCode
-		if oversize {
-			status = 200
-		}
-		result = SendResult{ids: ids, status: status}
-	}()
-	return c
+	resp, err := e.HttpClient.Do(req)
+	if err != nil {
+		log.Println(err.Error())
+		return SendResult{ids: ids, status: status}
+	}
+	io.CopyN(ioutil.Discard, resp.Body, 512)
+	resp.Body.Close()
+
+	status = resp.StatusCode
+	if oversize {
+		status = 200
+	}
+	return SendResult{ids: ids, status: status}
+}
+
+// postRequest sends an array of Payloads together to the collector endpoint via POST on the calling goroutine.
+func (e *Emitter) postRequest(ctx context.Context, url string, ids []int, events []payload.Payload, oversize bool) SendResult {
+	status := -1
+	if oversize {
+		status = 200
+	}
+
+	body := newPostBody(events, common.GetTimestampString())
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		body.Close()
+		log.Println(err.Error())
+		return SendResult{ids: ids, status: status}
+	}
+	req.ContentLength = int64(body.Len())
+	req.Header.Set("Content-Type", POST_CONTENT_TYPE)
+
+	resp, err := e.HttpClient.Do(req)
+	if err != nil {
+		log.Println(err.Error())
+		return SendResult{ids: ids, status: status}
+	}
+	io.CopyN(ioutil.Discard, resp.Body, 512)
+	resp.Body.Close()
+
+	status = resp.StatusCode
+	if oversize {
+		status = 200
+	}
+	return SendResult{ids: ids, status: status}
 }
 
 // --- Helpers
 
-// IsSending checks whether the send channel has finished.
+// IsSending checks whether the send loop is running.
 func (e Emitter) IsSending() bool {
-	return len(e.SendChannel) == 0
+	e.sender.lock.Lock()
+	defer e.sender.lock.Unlock()
+	return e.sender.running
 }
 
 // returnCollectorUrl builds and returns the full collector URL to be used.
@@ -385,17 +514,6 @@ func returnCollectorUrl(requestType string, protocol string, collectorUri string
 	return url.Parse(rawUrl)
 }
 
-// addSentTimeToEvents ranges over an array of events and appends the same timestamp to them all.
-func addSentTimeToEvents(events []payload.Payload) []map[string]string {
-	eventMaps := []map[string]string{}
-	stm := common.NewString(common.GetTimestampString())
-	for _, p := range events {
-		p.Add(SENT_TIMESTAMP, stm)
-		eventMaps = append(eventMaps, p.Get())
-	}
-	return eventMaps
-}
-
 // --- Getters & Setters
 
 // GetCollectorUrl returns the stringified collector URL.
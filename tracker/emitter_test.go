@@ -14,14 +14,24 @@
 package tracker
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"log"
+	"net/http"
+	"path/filepath"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/jarcoal/httpmock"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
 	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/encrypted"
 	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/memory"
 	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/sqlite3"
 	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storageiface"
@@ -75,6 +85,15 @@ func TestEmitterInit(t *testing.T) {
 	assert.NotNil(emitter.HttpClient)
 	assert.NotNil(emitter.Storage)
 	assert.Equal("sqlite3.StorageSQLite3", reflect.TypeOf(emitter.Storage).String())
+	assert.Equal(0, emitter.MaxQueueSize)
+	assert.Equal(QUEUE_POLICY_BLOCK, emitter.QueuePolicy)
+	assert.Equal(DEFAULT_QUEUE_BLOCK_TIMEOUT, emitter.QueueBlockTimeout)
+	assert.Equal(-1, emitter.QueueSize())
+	assert.Equal(uint64(0), emitter.DroppedCount())
+	assert.Equal(0, emitter.AsyncBufferSize)
+	assert.False(emitter.Synchronous)
+	assert.Equal(2, emitter.MaxRetries)
+	assert.Equal(100*time.Millisecond, emitter.RetryBackoff)
 
 	// Assert the set functions
 	emitter.SetCollectorUri("com.snplow")
@@ -140,7 +159,7 @@ func TestSingleRowOversize(t *testing.T) {
 	payload0 := *payload.Init()
 	payload0.Add("e", common.NewString("abcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyz"))
 	eventRows := []storageiface.EventRow{{Id: -1, Event: payload0}}
-	results := emitter.doSend(eventRows)
+	results := emitter.doSend(context.Background(), eventRows)
 	assert.NotNil(results)
 	assert.True(len(results) == 1)
 	assert.Equal(-1, results[0].ids[0])
@@ -150,14 +169,15 @@ func TestSingleRowOversize(t *testing.T) {
 		RequireCollectorUri("localhost"),
 		OptionRequestType("GET"),
 		OptionByteLimitGet(1),
+		OptionByteLimitPost(1),
 		RequireStorage(*memory.Init()),
 	)
 
-	// Single Row > than byte limit GET
+	// Single Row > than byte limit GET and POST
 	payload1 := *payload.Init()
 	payload1.Add("e", common.NewString("abcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyz"))
 	eventRows2 := []storageiface.EventRow{{Id: -1, Event: payload1}}
-	results = emitter.doSend(eventRows2)
+	results = emitter.doSend(context.Background(), eventRows2)
 	assert.NotNil(results)
 	assert.True(len(results) == 1)
 	assert.Equal(-1, results[0].ids[0])
@@ -182,7 +202,7 @@ func TestThreeRowsOversize(t *testing.T) {
 	payload3.Add("e", common.NewString("abcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyz"))
 
 	eventRows := []storageiface.EventRow{{Id: -1, Event: payload1}, {Id: -1, Event: payload2}, {Id: -1, Event: payload3}}
-	results := emitter.doSend(eventRows)
+	results := emitter.doSend(context.Background(), eventRows)
 	assert.NotNil(results)
 	assert.True(len(results) == 2)
 	for _, val := range results {
@@ -193,6 +213,100 @@ func TestThreeRowsOversize(t *testing.T) {
 	}
 }
 
+func TestEmitterEncryptedStorageMissingKey(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("POST", "http://com.acme.collector/com.snowplowanalytics.snowplow/tp2",
+		httpmock.NewStringResponder(200, ""))
+
+	k1 := []byte("0123456789abcdef")
+	k2 := []byte("fedcba9876543210")
+	inner := *memory.Init()
+	assert.True(encrypted.Init(inner, "k1", k1).AddEventRow(queuePayload()))
+
+	// Reopened without the key the first event was encrypted with
+	storage := *encrypted.Init(inner, "k2", k2)
+	assert.True(storage.AddEventRow(queuePayload()))
+
+	var successes []CallbackResult
+	emitter := InitEmitter(
+		RequireCollectorUri("com.acme.collector"),
+		RequireStorage(storage),
+		OptionHttpClient(http.DefaultClient),
+		OptionCallback(func(g []CallbackResult, b []CallbackResult) { successes = append(successes, g...) }),
+	)
+	assert.NotPanics(func() {
+		emitter.Flush()
+		emitter.Stop()
+	})
+
+	// Only the readable event is sent; the other stays stored for when its key is supplied
+	assert.Equal(1, httpmock.GetTotalCallCount())
+	assert.Equal([]CallbackResult{{Count: 1, Status: 200}}, successes)
+	assert.Equal(1, len(inner.GetAllEventRows()))
+	assert.Equal(1, len(encrypted.Init(inner, "k2", k2, encrypted.OptionDecryptionKey("k1", k1)).GetAllEventRows()))
+}
+
+func TestEmitterSkipsEmptyPayloads(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("POST", "http://com.acme.collector/com.snowplowanalytics.snowplow/tp2",
+		httpmock.NewStringResponder(200, ""))
+
+	// Rows a storage could not decode sit ahead of a whole batch of events
+	storage := *memory.Init()
+	assert.True(storage.AddEventRow(payload.Payload{}))
+	assert.True(storage.AddEventRow(payload.Payload{}))
+	assert.True(storage.AddEventRow(queuePayload()))
+	assert.True(storage.AddEventRow(queuePayload()))
+
+	var successes []CallbackResult
+	emitter := InitEmitter(
+		RequireCollectorUri("com.acme.collector"),
+		RequireStorage(storage),
+		OptionHttpClient(http.DefaultClient),
+		OptionSendLimit(2),
+		OptionCallback(func(g []CallbackResult, b []CallbackResult) { successes = append(successes, g...) }),
+	)
+	assert.NotPanics(func() {
+		emitter.Flush()
+		emitter.Stop()
+	})
+
+	assert.Equal(1, httpmock.GetTotalCallCount())
+	assert.Equal([]CallbackResult{{Count: 2, Status: 200}}, successes)
+
+	// The empty rows are kept in case they can be decoded later
+	eventRows := storage.GetAllEventRows()
+	assert.Equal(2, len(eventRows))
+	assert.Equal(0, len(eventRows[0].Event.Get()))
+	assert.Equal(0, len(eventRows[1].Event.Get()))
+}
+
+func TestEmitterDoesNotModifyStoredEvents(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterNoResponder(httpmock.NewStringResponder(200, ""))
+
+	for _, requestType := range []string{"GET", "POST"} {
+		emitter := InitEmitter(
+			RequireCollectorUri("com.acme.collector"),
+			RequireStorage(*memory.Init()),
+			OptionHttpClient(http.DefaultClient),
+			OptionRequestType(requestType),
+		)
+		event := queuePayload()
+		results := emitter.doSend(context.Background(), []storageiface.EventRow{{Id: 1, Event: event}})
+		assert.Equal(200, results[0].status)
+		_, stamped := event.Get()[SENT_TIMESTAMP]
+		assert.False(stamped, requestType)
+	}
+	assert.Equal(2, httpmock.GetTotalCallCount())
+}
+
 func TestBadInputToGET(t *testing.T) {
 	assert := assert.New(t)
 	emitter := InitEmitter(
@@ -202,12 +316,12 @@ func TestBadInputToGET(t *testing.T) {
 	)
 
 	// Bad URL
-	result := <-emitter.sendGetRequest("", []int{}, false)
+	result := <-emitter.sendGetRequest(context.Background(), "", []int{}, false)
 	assert.NotNil(result)
 	assert.Equal(-1, result.status)
 
 	// Non-Active Collector
-	result = <-emitter.sendGetRequest("http://localhost/", []int{}, false)
+	result = <-emitter.sendGetRequest(context.Background(), "http://localhost/", []int{}, false)
 	assert.NotNil(result)
 	assert.Equal(-1, result.status)
 }
@@ -221,12 +335,587 @@ func TestBadInputToPOST(t *testing.T) {
 	)
 
 	// Bad URL
-	result := <-emitter.sendPostRequest("", []int{}, nil, false)
+	result := <-emitter.sendPostRequest(context.Background(), "", []int{}, nil, false)
 	assert.NotNil(result)
 	assert.Equal(-1, result.status)
 
 	// Non-Active Collector
-	result = <-emitter.sendPostRequest("http://localhost/", []int{}, []payload.Payload{}, false)
+	result = <-emitter.sendPostRequest(context.Background(), "http://localhost/", []int{}, []payload.Payload{}, false)
 	assert.NotNil(result)
 	assert.Equal(-1, result.status)
 }
+
+func TestEmitterBadQueueOptions(t *testing.T) {
+	assert := assert.New(t)
+	assert.PanicsWithValue("FATAL: MaxQueueSize cannot be negative.", func() {
+		InitEmitter(RequireCollectorUri("com.acme"), RequireStorage(*memory.Init()), OptionMaxQueueSize(-1))
+	})
+	assert.PanicsWithValue("FATAL: QueuePolicy did not match BLOCK, ERROR or DROP.", func() {
+		InitEmitter(RequireCollectorUri("com.acme"), RequireStorage(*memory.Init()), OptionMaxQueueSize(1), OptionQueuePolicy(QueuePolicy(7)))
+	})
+	assert.PanicsWithValue("FATAL: QueueBlockTimeout must be above 0.", func() {
+		InitEmitter(RequireCollectorUri("com.acme"), RequireStorage(*memory.Init()), OptionMaxQueueSize(1), OptionQueueBlockTimeout(0))
+	})
+}
+
+func TestEmitterQueuePolicyError(t *testing.T) {
+	assert := assert.New(t)
+	emitter := initFailingQueueEmitter(t, OptionQueuePolicy(QUEUE_POLICY_ERROR))
+
+	assert.Nil(emitter.Add(queuePayload()))
+	assert.Nil(emitter.Add(queuePayload()))
+	assert.Equal(ErrQueueFull, emitter.Add(queuePayload()))
+	assert.Equal(2, emitter.QueueSize())
+	assert.Equal(uint64(0), emitter.DroppedCount())
+	emitter.Stop()
+	assert.Equal(2, len(emitter.Storage.GetAllEventRows()))
+}
+
+func TestEmitterQueuePolicyDrop(t *testing.T) {
+	assert := assert.New(t)
+	emitter := initFailingQueueEmitter(t, OptionQueuePolicy(QUEUE_POLICY_DROP))
+
+	for i := 0; i < 5; i++ {
+		assert.Nil(emitter.Add(queuePayload()))
+	}
+	assert.Equal(2, emitter.QueueSize())
+	assert.Equal(uint64(3), emitter.DroppedCount())
+	emitter.Stop()
+	assert.Equal(2, len(emitter.Storage.GetAllEventRows()))
+}
+
+func TestEmitterQueuePolicyBlockTimeout(t *testing.T) {
+	assert := assert.New(t)
+	emitter := initFailingQueueEmitter(t, OptionQueueBlockTimeout(20*time.Millisecond))
+
+	assert.Nil(emitter.Add(queuePayload()))
+	assert.Nil(emitter.Add(queuePayload()))
+	start := time.Now()
+	err := emitter.Add(queuePayload())
+	assert.True(errors.Is(err, ErrQueueFull))
+	assert.GreaterOrEqual(time.Since(start), 20*time.Millisecond)
+
+	// A cancelled context gives up straight away
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.True(errors.Is(emitter.AddContext(ctx, queuePayload()), ErrQueueFull))
+	assert.Equal(2, emitter.QueueSize())
+}
+
+func TestEmitterQueuePolicyBlockUntilSent(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	release := make(chan struct{})
+	httpmock.RegisterResponder("POST", "http://com.acme.collector/com.snowplowanalytics.snowplow/tp2",
+		func(req *http.Request) (*http.Response, error) {
+			<-release
+			return httpmock.NewStringResponse(200, ""), nil
+		},
+	)
+
+	emitter := InitEmitter(
+		RequireCollectorUri("com.acme.collector"),
+		RequireStorage(*memory.Init()),
+		OptionHttpClient(http.DefaultClient),
+		OptionMaxQueueSize(1),
+	)
+	assert.Nil(emitter.Add(queuePayload()))
+
+	added := make(chan error, 1)
+	go func() { added <- emitter.Add(queuePayload()) }()
+	select {
+	case <-added:
+		assert.Fail("Add did not block while the queue was full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case err := <-added:
+		assert.Nil(err)
+	case <-time.After(2 * time.Second):
+		assert.Fail("Add stayed blocked after the queue was sent")
+	}
+	emitter.Stop()
+}
+
+func TestEmitterSingleSendLoop(t *testing.T) {
+	assertSingleSendLoop(t)
+}
+
+func TestEmitterAsyncSingleSendLoop(t *testing.T) {
+	assertSingleSendLoop(t, OptionAsync(10))
+}
+
+// assertSingleSendLoop checks that events added and flushed from many
+// goroutines at once are each sent exactly once.
+func assertSingleSendLoop(t *testing.T, options ...func(*Emitter)) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	var lock sync.Mutex
+	sent := map[string]int{}
+	httpmock.RegisterResponder("POST", "http://com.acme.collector/com.snowplowanalytics.snowplow/tp2",
+		func(req *http.Request) (*http.Response, error) {
+			var body struct {
+				Data []map[string]string `json:"data"`
+			}
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				return nil, err
+			}
+			time.Sleep(time.Millisecond)
+			lock.Lock()
+			defer lock.Unlock()
+			for _, event := range body.Data {
+				sent[event[EID]]++
+			}
+			return httpmock.NewStringResponse(200, ""), nil
+		},
+	)
+
+	emitter := InitEmitter(append([]func(*Emitter){
+		RequireCollectorUri("com.acme.collector"),
+		RequireStorage(*memory.Init()),
+		OptionHttpClient(http.DefaultClient),
+		OptionSendLimit(5),
+	}, options...)...)
+
+	// Producers and flushes race to start the send loop
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				p := queuePayload()
+				p.Add(EID, common.NewString(common.IntToString(i*10+j)))
+				assert.Nil(emitter.Add(p))
+				emitter.Flush()
+			}
+		}(i)
+	}
+	wg.Wait()
+	emitter.Close()
+	emitter.Flush()
+	for emitter.IsSending() {
+		emitter.Stop()
+	}
+
+	assert.Equal(100, len(sent))
+	for eid, count := range sent {
+		assert.Equal(1, count, eid)
+	}
+}
+
+func TestEmitterQueueCountsStoredEvents(t *testing.T) {
+	assert := assert.New(t)
+	storage := *memory.Init()
+	storage.AddEventRow(queuePayload())
+	storage.AddEventRow(queuePayload())
+
+	emitter := InitEmitter(
+		RequireCollectorUri("com.acme.collector"),
+		RequireStorage(storage),
+		OptionMaxQueueSize(2),
+		OptionQueuePolicy(QUEUE_POLICY_ERROR),
+	)
+	assert.Equal(2, emitter.QueueSize())
+	assert.Equal(ErrQueueFull, emitter.Add(queuePayload()))
+
+	// A storage which can count its events is not read
+	counting := &countingStorage{StorageMemory: storage}
+	emitter = InitEmitter(
+		RequireCollectorUri("com.acme.collector"),
+		RequireStorage(counting),
+		OptionMaxQueueSize(5),
+	)
+	assert.Equal(2, emitter.QueueSize())
+	assert.Equal(0, counting.reads)
+
+	// Otherwise the events are read to count them
+	emitter = InitEmitter(
+		RequireCollectorUri("com.acme.collector"),
+		RequireStorage(&batchStorage{Storage: storage}),
+		OptionMaxQueueSize(5),
+	)
+	assert.Equal(2, emitter.QueueSize())
+}
+
+// countingStorage is a CountingStorage which counts how often all of its events are read.
+type countingStorage struct {
+	memory.StorageMemory
+	reads int
+}
+
+func (s *countingStorage) GetAllEventRows() []storageiface.EventRow {
+	s.reads++
+	return s.StorageMemory.GetAllEventRows()
+}
+
+// initFailingQueueEmitter returns an Emitter holding at most two events whose collector always fails.
+func initFailingQueueEmitter(t *testing.T, options ...func(*Emitter)) *Emitter {
+	httpmock.Activate()
+	t.Cleanup(httpmock.DeactivateAndReset)
+	httpmock.RegisterResponder("POST", "http://com.acme.collector/com.snowplowanalytics.snowplow/tp2",
+		httpmock.NewStringResponder(500, ""))
+
+	emitter := InitEmitter(append([]func(*Emitter){
+		RequireCollectorUri("com.acme.collector"),
+		RequireStorage(*memory.Init()),
+		OptionHttpClient(http.DefaultClient),
+		OptionMaxQueueSize(2),
+	}, options...)...)
+	t.Cleanup(emitter.Stop)
+	return emitter
+}
+
+func queuePayload() payload.Payload {
+	p := *payload.Init()
+	p.Add("e", common.NewString("pv"))
+	return p
+}
+
+func TestEmitterAsync(t *testing.T) {
+	assert := assert.New(t)
+	emitter := initFailingQueueEmitter(t, OptionMaxQueueSize(0), OptionAsync(10))
+	assert.Equal(10, emitter.AsyncBufferSize)
+
+	for i := 0; i < 25; i++ {
+		assert.Nil(emitter.Add(queuePayload()))
+	}
+	emitter.Close()
+	emitter.Close()
+	assert.Equal(25, len(emitter.Storage.GetAllEventRows()))
+	assert.Equal(ErrEmitterClosed, emitter.Add(queuePayload()))
+}
+
+func TestEmitterAsyncGroupCommit(t *testing.T) {
+	assert := assert.New(t)
+	storage := &batchStorage{Storage: *memory.Init(), release: make(chan struct{})}
+	emitter := initFailingQueueEmitter(t, OptionMaxQueueSize(0), OptionAsync(20), RequireStorage(storage))
+
+	// The first batch holds up the writer so that the rest are committed together
+	for i := 0; i < 10; i++ {
+		assert.Nil(emitter.Add(queuePayload()))
+	}
+	close(storage.release)
+	emitter.Close()
+
+	assert.Equal(10, len(emitter.Storage.GetAllEventRows()))
+	assert.Less(len(storage.batches), 10)
+	total := 0
+	for _, size := range storage.batches {
+		total += size
+	}
+	assert.Equal(10, total)
+}
+
+func TestEmitterAsyncBulkFailure(t *testing.T) {
+	assert := assert.New(t)
+	storage := &batchStorage{Storage: *memory.Init(), release: make(chan struct{}), fail: true}
+	close(storage.release)
+	emitter := initFailingQueueEmitter(t, OptionMaxQueueSize(5), OptionQueuePolicy(QUEUE_POLICY_ERROR), OptionAsync(5), RequireStorage(storage))
+
+	for i := 0; i < 5; i++ {
+		assert.Nil(emitter.Add(queuePayload()))
+	}
+	emitter.Close()
+	assert.Equal(5, len(emitter.Storage.GetAllEventRows()))
+	assert.Equal(5, emitter.QueueSize())
+}
+
+func TestEmitterAsyncDurableOnClose(t *testing.T) {
+	assert := assert.New(t)
+	dbName := filepath.Join(t.TempDir(), "test.db")
+	storage := *sqlite3.Init(dbName)
+	emitter := initFailingQueueEmitter(t, OptionMaxQueueSize(0), OptionAsync(100), RequireStorage(storage))
+
+	for i := 0; i < 250; i++ {
+		assert.Nil(emitter.Add(queuePayload()))
+	}
+	emitter.Close()
+	emitter.Stop()
+	assert.Nil(storage.Close())
+
+	storage = *sqlite3.Init(dbName)
+	defer storage.Close()
+	assert.Equal(250, len(storage.GetAllEventRows()))
+}
+
+func TestEmitterBadAsyncBufferSize(t *testing.T) {
+	assert := assert.New(t)
+	assert.PanicsWithValue("FATAL: AsyncBufferSize cannot be negative.", func() {
+		InitEmitter(RequireCollectorUri("com.acme"), RequireStorage(*memory.Init()), OptionAsync(-1))
+	})
+}
+
+func BenchmarkEmitterAddSQLite3(b *testing.B) {
+	benchmarkEmitterAdd(b)
+}
+
+func BenchmarkEmitterAddSQLite3Async(b *testing.B) {
+	benchmarkEmitterAdd(b, OptionAsync(10000))
+}
+
+func benchmarkEmitterAdd(b *testing.B, options ...func(*Emitter)) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("POST", "http://com.acme.collector/com.snowplowanalytics.snowplow/tp2",
+		httpmock.NewStringResponder(500, ""))
+
+	storage := *sqlite3.Init(filepath.Join(b.TempDir(), "test.db"))
+	defer storage.Close()
+	emitter := InitEmitter(append([]func(*Emitter){
+		RequireCollectorUri("com.acme.collector"),
+		RequireStorage(storage),
+		OptionHttpClient(http.DefaultClient),
+	}, options...)...)
+
+	p := queuePayload()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		emitter.Add(p)
+	}
+	b.StopTimer()
+	emitter.Close()
+	emitter.Stop()
+}
+
+// batchStorage records the size of each batch, holding up the first until released.
+type batchStorage struct {
+	storageiface.Storage
+	lock    sync.Mutex
+	batches []int
+	release chan struct{}
+	fail    bool
+}
+
+func (s *batchStorage) AddEventRows(payloads []payload.Payload) bool {
+	<-s.release
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.batches = append(s.batches, len(payloads))
+	if s.fail {
+		return false
+	}
+	for _, p := range payloads {
+		s.Storage.AddEventRow(p)
+	}
+	return true
+}
+
+func TestEmitterSynchronous(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("POST", "http://com.acme.collector/com.snowplowanalytics.snowplow/tp2",
+		httpmock.NewStringResponder(200, ""))
+
+	var successes []CallbackResult
+	emitter := InitEmitter(
+		RequireCollectorUri("com.acme.collector"),
+		OptionHttpClient(http.DefaultClient),
+		OptionSynchronous(true),
+		OptionCallback(func(g []CallbackResult, b []CallbackResult) { successes = append(successes, g...) }),
+	)
+	assert.True(emitter.Synchronous)
+	assert.Nil(emitter.Storage)
+
+	assert.Nil(emitter.Add(queuePayload()))
+	assert.Equal(1, httpmock.GetTotalCallCount())
+	assert.Equal([]CallbackResult{{Count: 1, Status: 200}}, successes)
+	assert.Nil(emitter.SendChannel)
+
+	emitter.Flush()
+	emitter.Close()
+	assert.Equal(ErrEmitterClosed, emitter.Add(queuePayload()))
+	assert.Equal(1, httpmock.GetTotalCallCount())
+}
+
+func TestEmitterSynchronousGET(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", "http://com.acme.collector/i",
+		func(req *http.Request) (*http.Response, error) {
+			assert.Equal("pv", req.URL.Query().Get("e"))
+			assert.NotEmpty(req.URL.Query().Get(SENT_TIMESTAMP))
+			return httpmock.NewStringResponse(200, ""), nil
+		},
+	)
+
+	emitter := InitEmitter(
+		RequireCollectorUri("com.acme.collector"),
+		OptionHttpClient(http.DefaultClient),
+		OptionRequestType("GET"),
+		OptionSynchronous(true),
+	)
+	assert.Nil(emitter.Add(queuePayload()))
+	assert.Equal(1, httpmock.GetTotalCallCount())
+}
+
+func TestEmitterSynchronousRetries(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	statuses := []int{503, 429, 200}
+	httpmock.RegisterResponder("POST", "http://com.acme.collector/com.snowplowanalytics.snowplow/tp2",
+		func(req *http.Request) (*http.Response, error) {
+			status := statuses[0]
+			statuses = statuses[1:]
+			return httpmock.NewStringResponse(status, ""), nil
+		},
+	)
+
+	emitter := initSynchronousEmitter()
+	assert.Nil(emitter.Add(queuePayload()))
+	assert.Equal(3, httpmock.GetTotalCallCount())
+}
+
+func TestEmitterSynchronousFailure(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("POST", "http://com.acme.collector/com.snowplowanalytics.snowplow/tp2",
+		httpmock.NewStringResponder(500, ""))
+
+	// Retryable failures are retried until MaxRetries is reached
+	emitter := initSynchronousEmitter()
+	err := emitter.Add(queuePayload())
+	assert.Equal(&SendError{Status: 500, Attempts: 3}, err)
+	assert.Equal("collector responded with status 500 after 3 attempts", err.Error())
+	assert.Equal(3, httpmock.GetTotalCallCount())
+
+	// Other failures are not retried
+	httpmock.RegisterResponder("POST", "http://com.acme.collector/com.snowplowanalytics.snowplow/tp2",
+		httpmock.NewStringResponder(400, ""))
+	assert.Equal(&SendError{Status: 400, Attempts: 1}, emitter.Add(queuePayload()))
+	assert.Equal(4, httpmock.GetTotalCallCount())
+
+	// A done context stops retries
+	httpmock.RegisterResponder("POST", "http://com.acme.collector/com.snowplowanalytics.snowplow/tp2",
+		httpmock.NewErrorResponder(errors.New("connection refused")))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = emitter.AddContext(ctx, queuePayload())
+	assert.Equal(&SendError{Status: -1, Attempts: 1}, err)
+	assert.Equal("collector could not be reached after 1 attempts", err.Error())
+}
+
+func TestEmitterSynchronousTooLarge(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("POST", "http://com.acme.collector/com.snowplowanalytics.snowplow/tp2",
+		httpmock.NewStringResponder(500, ""))
+
+	var failures []CallbackResult
+	callback := OptionCallback(func(g []CallbackResult, b []CallbackResult) { failures = append(failures, b...) })
+
+	emitter := InitEmitter(
+		RequireCollectorUri("com.acme.collector"),
+		OptionHttpClient(http.DefaultClient),
+		OptionSynchronous(true),
+		OptionByteLimitPost(10),
+		callback,
+	)
+	assert.Equal(ErrEventTooLarge, emitter.Add(queuePayload()))
+	assert.Equal(0, httpmock.GetTotalCallCount())
+
+	// Too large for GET but within ByteLimitPost, so the real status is reported
+	emitter = InitEmitter(
+		RequireCollectorUri("com.acme.collector"),
+		OptionHttpClient(http.DefaultClient),
+		OptionRequestType("GET"),
+		OptionSynchronous(true),
+		OptionByteLimitGet(10),
+		OptionMaxRetries(0),
+		callback,
+	)
+	assert.Equal(&SendError{Status: 500, Attempts: 1}, emitter.Add(queuePayload()))
+	assert.Equal(1, httpmock.GetTotalCallCount())
+	assert.Equal([]CallbackResult{{Count: 1, Status: 500}}, failures)
+}
+
+func TestEmitterSynchronousBadOptions(t *testing.T) {
+	assert := assert.New(t)
+	assert.PanicsWithValue("FATAL: Synchronous cannot be combined with MaxQueueSize or AsyncBufferSize.", func() {
+		InitEmitter(RequireCollectorUri("com.acme"), OptionSynchronous(true), OptionAsync(10))
+	})
+	assert.PanicsWithValue("FATAL: Synchronous cannot be combined with MaxQueueSize or AsyncBufferSize.", func() {
+		InitEmitter(RequireCollectorUri("com.acme"), OptionSynchronous(true), OptionMaxQueueSize(10))
+	})
+	assert.PanicsWithValue("FATAL: MaxRetries cannot be negative.", func() {
+		InitEmitter(RequireCollectorUri("com.acme"), OptionSynchronous(true), OptionMaxRetries(-1))
+	})
+}
+
+func initSynchronousEmitter() *Emitter {
+	return InitEmitter(
+		RequireCollectorUri("com.acme.collector"),
+		OptionHttpClient(http.DefaultClient),
+		OptionSynchronous(true),
+		OptionRetryBackoff(time.Millisecond),
+	)
+}
+
+func TestEmitterOversizeGetFallsBackToPost(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("GET", "http://com.acme.collector/i",
+		httpmock.NewStringResponder(200, ""))
+	var posted []map[string]interface{}
+	httpmock.RegisterResponder("POST", "http://com.acme.collector/com.snowplowanalytics.snowplow/tp2",
+		func(req *http.Request) (*http.Response, error) {
+			var envelope map[string]interface{}
+			assert.Nil(json.NewDecoder(req.Body).Decode(&envelope))
+			assert.Equal(SCHEMA_PAYLOAD_DATA, envelope[SCHEMA])
+			for _, event := range envelope[DATA].([]interface{}) {
+				posted = append(posted, event.(map[string]interface{}))
+			}
+			return httpmock.NewStringResponse(200, ""), nil
+		},
+	)
+
+	emitter := InitEmitter(
+		RequireCollectorUri("com.acme.collector"),
+		RequireStorage(*memory.Init()),
+		OptionHttpClient(http.DefaultClient),
+		OptionRequestType("GET"),
+		OptionByteLimitGet(100),
+	)
+
+	small := queuePayload()
+	large := queuePayload()
+	large.Add("url", common.NewString(strings.Repeat("a", 200)))
+	results := emitter.doSend(context.Background(), []storageiface.EventRow{{Id: 1, Event: small}, {Id: 2, Event: large}})
+
+	assert.Equal(2, len(results))
+	for _, result := range results {
+		assert.Equal(200, result.status)
+	}
+	info := httpmock.GetCallCountInfo()
+	assert.Equal(1, info["GET http://com.acme.collector/i"])
+	assert.Equal(1, info["POST http://com.acme.collector/com.snowplowanalytics.snowplow/tp2"])
+	assert.Equal(1, len(posted))
+	assert.Equal(strings.Repeat("a", 200), posted[0]["url"])
+	assert.NotEmpty(posted[0][SENT_TIMESTAMP])
+
+	// Synchronous emitters fall back in the same way
+	emitter = InitEmitter(
+		RequireCollectorUri("com.acme.collector"),
+		OptionHttpClient(http.DefaultClient),
+		OptionRequestType("GET"),
+		OptionByteLimitGet(100),
+		OptionSynchronous(true),
+	)
+	assert.Nil(emitter.Add(large))
+	assert.Equal(2, len(posted))
+}
@@ -0,0 +1,189 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
+)
+
+const hexDigits = "0123456789abcdef"
+
+// postBodyPool holds the buffers POST request bodies are encoded into.
+var postBodyPool = sync.Pool{
+	New: func() interface{} { return new(postBody) },
+}
+
+// postBody is a pooled buffer along with the scratch space used to sort keys.
+type postBody struct {
+	buf  bytes.Buffer
+	keys []string
+}
+
+// postBodyReader reads an encoded POST body, returning its buffer to
+// postBodyPool when closed. The transport closes a request body once it has
+// finished with it, which may be after the request has returned.
+type postBodyReader struct {
+	bytes.Reader
+	body *postBody
+}
+
+// newPostBody encodes the events, each with the sent timestamp, into a
+// pooled buffer. The output is byte for byte what json.Marshal produces for
+// the equivalent envelope, so it matches the sizes used to pack requests.
+func newPostBody(events []payload.Payload, stm string) *postBodyReader {
+	b := postBodyPool.Get().(*postBody)
+	b.buf.Reset()
+
+	b.buf.WriteString(`{"` + DATA + `":[`)
+	for i, event := range events {
+		if i > 0 {
+			b.buf.WriteByte(',')
+		}
+		b.writeEvent(event.Get(), stm)
+	}
+	b.buf.WriteString(`],"` + SCHEMA + `":"` + SCHEMA_PAYLOAD_DATA + `"}`)
+
+	r := &postBodyReader{body: b}
+	r.Reset(b.buf.Bytes())
+	return r
+}
+
+// eventSizes returns the number of bytes each event takes in a POST body
+// sent with stm, measured with the same encoder without modifying the events.
+func eventSizes(events []payload.Payload, stm string) []int {
+	b := postBodyPool.Get().(*postBody)
+	defer postBodyPool.Put(b)
+
+	sizes := make([]int, len(events))
+	for i, event := range events {
+		b.buf.Reset()
+		b.writeEvent(event.Get(), stm)
+		sizes[i] = b.buf.Len()
+	}
+	return sizes
+}
+
+func (r *postBodyReader) Close() error {
+	if r.body != nil {
+		postBodyPool.Put(r.body)
+		r.body = nil
+	}
+	return nil
+}
+
+var _ io.ReadCloser = (*postBodyReader)(nil)
+
+// writeEvent writes the pairs as a JSON object with sorted keys, replacing
+// any sent timestamp with stm.
+func (b *postBody) writeEvent(pairs map[string]string, stm string) {
+	b.keys = b.keys[:0]
+	for key := range pairs {
+		if key != SENT_TIMESTAMP {
+			b.keys = append(b.keys, key)
+		}
+	}
+	b.keys = append(b.keys, SENT_TIMESTAMP)
+	sort.Strings(b.keys)
+
+	b.buf.WriteByte('{')
+	for i, key := range b.keys {
+		if i > 0 {
+			b.buf.WriteByte(',')
+		}
+		writeJsonString(&b.buf, key)
+		b.buf.WriteByte(':')
+		if key == SENT_TIMESTAMP {
+			writeJsonString(&b.buf, stm)
+		} else {
+			writeJsonString(&b.buf, pairs[key])
+		}
+	}
+	b.buf.WriteByte('}')
+}
+
+// controlEscapes and invalidUtf8 are how encoding/json writes control
+// characters and invalid UTF-8 bytes, which differs between Go releases.
+var (
+	controlEscapes [' ']string
+	invalidUtf8    = marshalString("\xff")
+)
+
+func init() {
+	for c := 0; c < ' '; c++ {
+		controlEscapes[c] = marshalString(string(rune(c)))
+	}
+}
+
+// marshalString returns s as escaped by json.Marshal without its quotes.
+func marshalString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b[1 : len(b)-1])
+}
+
+// writeJsonString writes s as a JSON string, escaped in the same way as
+// json.Marshal including its HTML escaping.
+func writeJsonString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && c != '<' && c != '>' && c != '&' {
+				i++
+				continue
+			}
+			buf.WriteString(s[start:i])
+			switch c {
+			case '"', '\\':
+				buf.WriteByte('\\')
+				buf.WriteByte(c)
+			case '<', '>', '&':
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hexDigits[c>>4])
+				buf.WriteByte(hexDigits[c&0xF])
+			default:
+				buf.WriteString(controlEscapes[c])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf.WriteString(s[start:i])
+			buf.WriteString(invalidUtf8)
+			i += size
+			start = i
+			continue
+		}
+		// U+2028 and U+2029 are valid JSON but not valid JavaScript
+		if r == '\u2028' || r == '\u2029' {
+			buf.WriteString(s[start:i])
+			buf.WriteString(`\u202`)
+			buf.WriteByte(hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf.WriteString(s[start:])
+	buf.WriteByte('"')
+}
@@ -0,0 +1,183 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/memory"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storageiface"
+)
+
+func TestPostBodyMatchesJsonMarshal(t *testing.T) {
+	assert := assert.New(t)
+	p := *payload.Init()
+	p.Add(EVENT, common.NewString(EVENT_PAGE_VIEW))
+	p.Add(PAGE_URL, common.NewString("https://acme.com/?a=<b>&c=\"d\"\\e"))
+	p.Add(PAGE_TITLE, common.NewString("tab\tnew\nline\rnul\x00bell\x07back\bfeed\f    😀 \xff"))
+	p.Add(SENT_TIMESTAMP, common.NewString("0"))
+
+	assert.Equal(marshalPostBody([]payload.Payload{p}, "1443452851000"), readPostBody(t, []payload.Payload{p}, "1443452851000"))
+	assert.Equal(marshalPostBody(nil, "1443452851000"), readPostBody(t, nil, "1443452851000"))
+}
+
+// TestPostBodyMatchesJsonMarshalProperty asserts for random events that the
+// encoded body is exactly what json.Marshal produces.
+func TestPostBodyMatchesJsonMarshalProperty(t *testing.T) {
+	property := func(pairs []map[string]string, stm string) bool {
+		events := []payload.Payload{}
+		for _, m := range pairs {
+			events = append(events, payload.Payload{Pairs: m})
+		}
+		expected := marshalPostBody(events, stm)
+		return bytes.Equal(expected, readPostBody(t, events, stm)) && postBytes(eventSizes(events, stm)...) == len(expected)
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEventSizes(t *testing.T) {
+	assert := assert.New(t)
+	events := benchmarkEvents(3)
+	sizes := eventSizes(events, "1443452851000")
+	assert.Equal(3, len(sizes))
+	assert.Equal(len(marshalPostBody(events, "1443452851000")), postBytes(sizes...))
+	for _, event := range events {
+		_, stamped := event.Get()[SENT_TIMESTAMP]
+		assert.False(stamped, "the events are not modified")
+	}
+}
+
+func TestPostBodyClose(t *testing.T) {
+	assert := assert.New(t)
+	body := newPostBody([]payload.Payload{queuePayload()}, "1443452851000")
+	assert.Nil(body.Close())
+	assert.Nil(body.Close())
+	assert.Nil(body.body)
+}
+
+func BenchmarkPostBodyMapToJson(b *testing.B) {
+	events := benchmarkEvents(100)
+	benchmarkPostBody(b, len(events), func() {
+		// The envelope as it was built before bodies were streamed into pooled buffers
+		eventMaps := []map[string]string{}
+		stm := common.NewString(common.GetTimestampString())
+		for _, p := range events {
+			p.Add(SENT_TIMESTAMP, stm)
+			eventMaps = append(eventMaps, p.Get())
+		}
+		postEnvelope := map[string]interface{}{
+			SCHEMA: SCHEMA_PAYLOAD_DATA,
+			DATA:   eventMaps,
+		}
+		io.Copy(io.Discard, bytes.NewBufferString(common.MapToJson(postEnvelope)))
+	})
+}
+
+func BenchmarkPostBodyStreaming(b *testing.B) {
+	events := benchmarkEvents(100)
+	benchmarkPostBody(b, len(events), func() {
+		body := newPostBody(events, common.GetTimestampString())
+		io.Copy(io.Discard, body)
+		body.Close()
+	})
+}
+
+// BenchmarkEmitterDoSend measures a whole send of stored events: sizing,
+// packing and encoding them into requests to a collector which discards them.
+func BenchmarkEmitterDoSend(b *testing.B) {
+	emitter := InitEmitter(
+		RequireCollectorUri("com.acme.collector"),
+		RequireStorage(*memory.Init()),
+		OptionHttpClient(&http.Client{Transport: discardTransport{}}),
+	)
+	eventRows := []storageiface.EventRow{}
+	for i, event := range benchmarkEvents(100) {
+		eventRows = append(eventRows, storageiface.EventRow{Id: i, Event: event})
+	}
+	benchmarkPostBody(b, len(eventRows), func() { emitter.doSend(context.Background(), eventRows) })
+}
+
+// discardTransport reads and discards requests, responding with a 200.
+type discardTransport struct{}
+
+func (discardTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	io.Copy(io.Discard, req.Body)
+	req.Body.Close()
+	return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+}
+
+func benchmarkPostBody(b *testing.B, events int, encode func()) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		encode()
+	}
+	b.StopTimer()
+	b.ReportMetric(testing.AllocsPerRun(100, encode)/float64(events), "allocs/event")
+}
+
+func benchmarkEvents(count int) []payload.Payload {
+	events := []payload.Payload{}
+	for i := 0; i < count; i++ {
+		p := *payload.Init()
+		p.Add(EVENT, common.NewString(EVENT_PAGE_VIEW))
+		p.Add(EID, common.NewString("c6ef3124-b53a-4b13-a233-0088f79dcbcb"))
+		p.Add(TIMESTAMP, common.NewString("1443452851000"))
+		p.Add(T_VERSION, common.NewString(TRACKER_VERSION))
+		p.Add(PLATFORM, common.NewString(DEFAULT_PLATFORM))
+		p.Add(APP_ID, common.NewString("app-id"))
+		p.Add(NAMESPACE, common.NewString("namespace"))
+		p.Add(PAGE_URL, common.NewString("https://acme.com/products?id="+common.IntToString(i)))
+		p.Add(PAGE_TITLE, common.NewString("Products & Offers"))
+		p.Add(USERAGENT, common.NewString("Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko)"))
+		events = append(events, p)
+	}
+	return events
+}
+
+func marshalPostBody(events []payload.Payload, stm string) []byte {
+	eventMaps := []map[string]string{}
+	for _, p := range events {
+		m := map[string]string{}
+		for key, value := range p.Get() {
+			m[key] = value
+		}
+		m[SENT_TIMESTAMP] = stm
+		eventMaps = append(eventMaps, m)
+	}
+	b, _ := json.Marshal(map[string]interface{}{SCHEMA: SCHEMA_PAYLOAD_DATA, DATA: eventMaps})
+	return b
+}
+
+func readPostBody(t *testing.T, events []payload.Payload, stm string) []byte {
+	body := newPostBody(events, stm)
+	defer body.Close()
+	b, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
@@ -0,0 +1,167 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"path"
+	"sync"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
+)
+
+// EventInfo describes an event as it is being tracked.
+type EventInfo struct {
+	EventType string          // The event type, e.g. "pv", "se" or "ue"
+	Schema    string          // The schema of a self-describing event, otherwise empty
+	Payload   payload.Payload // The event payload without its contexts
+}
+
+// ContextRuleset decides which events a GlobalContext is attached to. An
+// empty ruleset matches every event.
+//
+// Schemas are matched with path.Match, so "iglu:com.acme/*/jsonschema/1-*-*"
+// matches every version 1 schema from the com.acme vendor.
+type ContextRuleset struct {
+	EventTypes        []string // Only attach to these event types
+	ExcludeEventTypes []string // Never attach to these event types
+	Schemas           []string // Only attach to self-describing events with a matching schema
+	ExcludeSchemas    []string // Never attach to self-describing events with a matching schema
+}
+
+// GlobalContext is a set of entities which the Tracker attaches to every
+// event matched by its Ruleset.
+type GlobalContext struct {
+	Tag       string                                     // Optional, used to remove the GlobalContext
+	Contexts  []SelfDescribingJson                       // Optional, attached as they are
+	Generator func(event EventInfo) []SelfDescribingJson // Optional, evaluated for each event
+	Ruleset   ContextRuleset                             // Optional
+}
+
+// globalContexts is allocated by InitTracker so that it is shared by every copy
+// of the Tracker. A Tracker built without InitTracker allocates it when the
+// first GlobalContext is added.
+type globalContexts struct {
+	lock     sync.RWMutex
+	contexts []GlobalContext
+}
+
+// OptionGlobalContexts sets the Tracker GlobalContexts
+func OptionGlobalContexts(contexts ...GlobalContext) func(t *Tracker) {
+	return func(t *Tracker) { t.AddGlobalContexts(contexts...) }
+}
+
+// AddGlobalContexts adds contexts which are attached to every matching event
+// after the event's own contexts.
+func (t *Tracker) AddGlobalContexts(contexts ...GlobalContext) {
+	for _, context := range contexts {
+		context.Ruleset.check()
+	}
+	if t.globalContexts == nil {
+		t.globalContexts = &globalContexts{}
+	}
+	t.globalContexts.lock.Lock()
+	defer t.globalContexts.lock.Unlock()
+	t.globalContexts.contexts = append(t.globalContexts.contexts, contexts...)
+}
+
+// RemoveGlobalContexts removes every GlobalContext with one of the tags.
+func (t *Tracker) RemoveGlobalContexts(tags ...string) {
+	if t.globalContexts == nil {
+		return
+	}
+	t.globalContexts.lock.Lock()
+	defer t.globalContexts.lock.Unlock()
+	kept := []GlobalContext{}
+	for _, context := range t.globalContexts.contexts {
+		if !contains(tags, context.Tag) {
+			kept = append(kept, context)
+		}
+	}
+	t.globalContexts.contexts = kept
+}
+
+// ClearGlobalContexts removes every GlobalContext.
+func (t *Tracker) ClearGlobalContexts() {
+	if t.globalContexts == nil {
+		return
+	}
+	t.globalContexts.lock.Lock()
+	defer t.globalContexts.lock.Unlock()
+	t.globalContexts.contexts = nil
+}
+
+// get returns the entities of every GlobalContext which matches the event.
+func (g *globalContexts) get(event EventInfo) []SelfDescribingJson {
+	if g == nil {
+		return nil
+	}
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	entities := []SelfDescribingJson{}
+	for _, context := range g.contexts {
+		if !context.Ruleset.matches(event) {
+			continue
+		}
+		entities = append(entities, context.Contexts...)
+		if context.Generator != nil {
+			entities = append(entities, context.Generator(event)...)
+		}
+	}
+	return entities
+}
+
+// check panics if any of the schema patterns are malformed.
+func (r ContextRuleset) check() {
+	for _, pattern := range append(append([]string{}, r.Schemas...), r.ExcludeSchemas...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			panic("FATAL: Schema pattern " + pattern + " is malformed.")
+		}
+	}
+}
+
+// matches returns whether the event passes the ruleset.
+func (r ContextRuleset) matches(event EventInfo) bool {
+	if len(r.EventTypes) > 0 && !contains(r.EventTypes, event.EventType) {
+		return false
+	}
+	if contains(r.ExcludeEventTypes, event.EventType) {
+		return false
+	}
+	if len(r.Schemas) > 0 && !matchesSchema(r.Schemas, event.Schema) {
+		return false
+	}
+	return !matchesSchema(r.ExcludeSchemas, event.Schema)
+}
+
+// matchesSchema returns whether the schema matches any of the patterns.
+func matchesSchema(patterns []string, schema string) bool {
+	if schema == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, schema); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,186 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+)
+
+func TestGlobalContexts(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	generated := []EventInfo{}
+	tracker, sent := initCapturingTracker(
+		OptionGlobalContexts(
+			GlobalContext{
+				Tag:      "deployment",
+				Contexts: []SelfDescribingJson{*InitSelfDescribingJson("iglu:com.acme/deployment/jsonschema/1-0-0", map[string]interface{}{"env": "prod"})},
+			},
+			GlobalContext{
+				Tag: "request",
+				Generator: func(event EventInfo) []SelfDescribingJson {
+					generated = append(generated, event)
+					return []SelfDescribingJson{*InitSelfDescribingJson("iglu:com.acme/request/jsonschema/1-0-0", map[string]interface{}{"id": len(generated)})}
+				},
+				Ruleset: ContextRuleset{ExcludeEventTypes: []string{EVENT_STRUCTURED}},
+			},
+		),
+	)
+
+	entity := *InitSelfDescribingJson("iglu:com.acme/user/jsonschema/1-0-0", map[string]interface{}{"name": "jane"})
+	contexts := make([]SelfDescribingJson, 1, 10)
+	contexts[0] = entity
+	assert.Nil(tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com"), Contexts: contexts}))
+	assert.Nil(tracker.TrackStructEvent(StructuredEvent{Category: common.NewString("shop"), Action: common.NewString("add-to-basket")}))
+	assert.Nil(tracker.TrackScreenView(ScreenViewEvent{Name: common.NewString("home")}))
+
+	assert.Equal([]string{"iglu:com.acme/user/jsonschema/1-0-0", "iglu:com.acme/deployment/jsonschema/1-0-0", "iglu:com.acme/request/jsonschema/1-0-0"}, contextSchemas(t, (*sent)[0]))
+	assert.Equal([]string{"iglu:com.acme/deployment/jsonschema/1-0-0"}, contextSchemas(t, (*sent)[1]))
+	assert.Equal([]string{"iglu:com.acme/deployment/jsonschema/1-0-0", "iglu:com.acme/request/jsonschema/1-0-0"}, contextSchemas(t, (*sent)[2]))
+	assert.Equal(1, len(contexts), "the event contexts are not modified")
+
+	assert.Equal(2, len(generated))
+	assert.Equal(EVENT_PAGE_VIEW, generated[0].EventType)
+	assert.Equal("", generated[0].Schema)
+	assert.Equal("acme.com", generated[0].Payload.Get()[PAGE_URL])
+	assert.Equal(EVENT_UNSTRUCTURED, generated[1].EventType)
+	assert.Equal(SCHEMA_SCREEN_VIEW, generated[1].Schema)
+
+	tracker.RemoveGlobalContexts("request")
+	assert.Nil(tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com")}))
+	assert.Equal([]string{"iglu:com.acme/deployment/jsonschema/1-0-0"}, contextSchemas(t, (*sent)[3]))
+
+	tracker.ClearGlobalContexts()
+	assert.Nil(tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com")}))
+	assert.Equal("", (*sent)[4][CONTEXT])
+}
+
+func TestGlobalContextsSharedByCopies(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	tracker, sent := initCapturingTracker()
+	copied := *tracker
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tracker.AddGlobalContexts(GlobalContext{Contexts: []SelfDescribingJson{*InitSelfDescribingJson("iglu:com.acme/deployment/jsonschema/1-0-0", map[string]interface{}{"env": "prod"})}})
+		}()
+	}
+	wg.Wait()
+
+	assert.Nil(copied.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com")}))
+	assert.Equal(10, len(contextSchemas(t, (*sent)[0])))
+}
+
+func TestGlobalContextsWithoutInit(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	initialised, sent := initCapturingTracker()
+	tracker := Tracker{Emitter: initialised.Emitter, Base64Encode: false}
+	tracker.RemoveGlobalContexts("deployment")
+	tracker.ClearGlobalContexts()
+	assert.Nil(tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com")}))
+	assert.Equal("", (*sent)[0][CONTEXT])
+
+	tracker.AddGlobalContexts(GlobalContext{Contexts: []SelfDescribingJson{*InitSelfDescribingJson("iglu:com.acme/deployment/jsonschema/1-0-0", map[string]interface{}{"env": "prod"})}})
+	assert.Nil(tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com")}))
+	assert.Equal([]string{"iglu:com.acme/deployment/jsonschema/1-0-0"}, contextSchemas(t, (*sent)[1]))
+}
+
+func TestContextRuleset(t *testing.T) {
+	assert := assert.New(t)
+	pageView := EventInfo{EventType: EVENT_PAGE_VIEW}
+	screenView := EventInfo{EventType: EVENT_UNSTRUCTURED, Schema: SCHEMA_SCREEN_VIEW}
+	acme := EventInfo{EventType: EVENT_UNSTRUCTURED, Schema: "iglu:com.acme/checkout/jsonschema/1-0-2"}
+
+	assert.True(ContextRuleset{}.matches(pageView))
+	assert.True(ContextRuleset{}.matches(acme))
+
+	ruleset := ContextRuleset{EventTypes: []string{EVENT_PAGE_VIEW, EVENT_STRUCTURED}}
+	assert.True(ruleset.matches(pageView))
+	assert.False(ruleset.matches(acme))
+
+	ruleset = ContextRuleset{Schemas: []string{"iglu:com.acme/*/jsonschema/1-*-*"}}
+	assert.False(ruleset.matches(pageView))
+	assert.False(ruleset.matches(screenView))
+	assert.True(ruleset.matches(acme))
+
+	ruleset = ContextRuleset{ExcludeSchemas: []string{"iglu:com.acme/*/jsonschema/2-*-*", "iglu:com.snowplowanalytics.*/*/jsonschema/*"}}
+	assert.True(ruleset.matches(pageView))
+	assert.False(ruleset.matches(screenView))
+	assert.True(ruleset.matches(acme))
+
+	assert.PanicsWithValue("FATAL: Schema pattern iglu:com.acme/[ is malformed.", func() {
+		InitTracker(
+			RequireEmitter(initSynchronousEmitter()),
+			OptionGlobalContexts(GlobalContext{Ruleset: ContextRuleset{ExcludeSchemas: []string{"iglu:com.acme/["}}}),
+		)
+	})
+}
+
+// initCapturingTracker returns a Tracker with a synchronous Emitter and the
+// events it has sent. It expects httpmock to be active.
+func initCapturingTracker(options ...func(*Tracker)) (*Tracker, *[]map[string]string) {
+	sent := []map[string]string{}
+	httpmock.RegisterResponder(
+		"POST",
+		"http://com.acme.collector/com.snowplowanalytics.snowplow/tp2",
+		func(req *http.Request) (*http.Response, error) {
+			var body struct {
+				Data []map[string]string `json:"data"`
+			}
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				return nil, err
+			}
+			sent = append(sent, body.Data...)
+			return httpmock.NewStringResponse(200, ""), nil
+		},
+	)
+	options = append([]func(*Tracker){RequireEmitter(initSynchronousEmitter()), OptionBase64Encode(false)}, options...)
+	return InitTracker(options...), &sent
+}
+
+// contextSchemas returns the schemas of the contexts sent with an event.
+func contextSchemas(t *testing.T, event map[string]string) []string {
+	var contexts struct {
+		Data []struct {
+			Schema string `json:"schema"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(event[CONTEXT]), &contexts); err != nil {
+		t.Fatal(err)
+	}
+	schemas := []string{}
+	for _, context := range contexts.Data {
+		schemas = append(schemas, context.Schema)
+	}
+	return schemas
+}
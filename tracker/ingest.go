@@ -0,0 +1,145 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storageiface"
+)
+
+const (
+	ASYNC_BATCH_SIZE = 500 // Most events the async writer commits to Storage at once
+)
+
+// ErrEmitterClosed is returned when an event is added after the Emitter has been closed.
+var ErrEmitterClosed = errors.New("emitter is closed")
+
+// ingest hands events from Add to Storage. Unless the Emitter is async
+// events are written on the caller's goroutine and buffer is nil.
+type ingest struct {
+	lock    sync.RWMutex
+	closed  bool
+	buffer  chan payload.Payload
+	written chan struct{} // Closed once the writer has committed every buffered event
+}
+
+func newIngest(bufferSize int) *ingest {
+	i := &ingest{}
+	if bufferSize > 0 {
+		i.buffer = make(chan payload.Payload, bufferSize)
+		i.written = make(chan struct{})
+	}
+	return i
+}
+
+// --- Emitter
+
+// write hands the event to the writer goroutine, or writes it to Storage
+// directly if the Emitter is not async.
+func (e *Emitter) write(ctx context.Context, payload payload.Payload) error {
+	e.ingest.lock.RLock()
+	defer e.ingest.lock.RUnlock()
+
+	if e.ingest.closed {
+		return ErrEmitterClosed
+	}
+
+	if e.ingest.buffer == nil {
+		if !e.Storage.AddEventRow(payload) {
+			return ErrStorageAdd
+		}
+		e.start()
+		return nil
+	}
+
+	select {
+	case e.ingest.buffer <- payload:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// writeLoop commits buffered events to Storage in batches until the buffer
+// is closed and drained.
+func (e *Emitter) writeLoop() {
+	defer close(e.ingest.written)
+
+	for p := range e.ingest.buffer {
+		payloads := []payload.Payload{p}
+	batch:
+		for len(payloads) < ASYNC_BATCH_SIZE {
+			select {
+			case p, ok := <-e.ingest.buffer:
+				if !ok {
+					break batch
+				}
+				payloads = append(payloads, p)
+			default:
+				break batch
+			}
+		}
+
+		if failed := e.commit(payloads); failed > 0 {
+			log.Printf("%d events could not be added to storage", failed)
+			if e.queue != nil {
+				e.queue.release(failed)
+			}
+		}
+		// Safe to call while a send loop runs, which reads Storage again
+		// rather than a second loop being started.
+		e.start()
+	}
+}
+
+// commit writes the events to Storage in a single operation if the Storage
+// supports it, falling back to adding them one at a time. It returns how
+// many events could not be added.
+func (e *Emitter) commit(payloads []payload.Payload) int {
+	if bulk, ok := e.Storage.(storageiface.BulkStorage); ok && bulk.AddEventRows(payloads) {
+		return 0
+	}
+	failed := 0
+	for _, p := range payloads {
+		if !e.Storage.AddEventRow(p) {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Close stops the Emitter accepting events and, if it is async, waits until
+// every buffered event has been written to Storage. Events already in Storage
+// are left there to be sent by Flush or a later Emitter.
+func (e *Emitter) Close() {
+	e.ingest.lock.Lock()
+	if e.ingest.closed {
+		e.ingest.lock.Unlock()
+		return
+	}
+	e.ingest.closed = true
+	if e.ingest.buffer != nil {
+		close(e.ingest.buffer)
+	}
+	e.ingest.lock.Unlock()
+
+	if e.ingest.written != nil {
+		<-e.ingest.written
+	}
+}
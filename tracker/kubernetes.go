@@ -0,0 +1,197 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_POD_INFO_DIR        = "/etc/podinfo"
+	DEFAULT_SERVICE_ACCOUNT_DIR = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+	// Environment variables set through the downward API in the pod spec
+	K8S_ENV_POD_NAME        = "POD_NAME"
+	K8S_ENV_POD_NAMESPACE   = "POD_NAMESPACE"
+	K8S_ENV_NODE_NAME       = "NODE_NAME"
+	K8S_ENV_CLUSTER_NAME    = "CLUSTER_NAME"
+	K8S_ENV_CONTAINER_NAME  = "CONTAINER_NAME"
+	K8S_ENV_CONTAINER_IMAGE = "CONTAINER_IMAGE"
+
+	// Set by Kubernetes in every container
+	K8S_ENV_SERVICE_HOST = "KUBERNETES_SERVICE_HOST"
+)
+
+// KubernetesProvider describes the pod and container the process runs in as
+// SCHEMA_KUBERNETES and SCHEMA_CONTAINER entities.
+//
+// Each value is read from its K8S_ENV environment variable, falling back to
+// the file of the same name in lower case within the downward API volume
+// mounted at PodInfoDir, e.g. /etc/podinfo/pod_name. The namespace also falls
+// back to that of the service account, and the pod name to the hostname,
+// which Kubernetes sets to it.
+//
+// The values are read once and cached, or again after RefreshInterval if it
+// is set. The zero value reads from the default directories. An entity is
+// only attached if at least one of its values is known, so outside of
+// Kubernetes none are.
+type KubernetesProvider struct {
+	PodInfoDir        string
+	ServiceAccountDir string
+	RefreshInterval   time.Duration
+
+	lock     sync.Mutex
+	entities []SelfDescribingJson
+	readAt   time.Time
+	now      func() time.Time
+}
+
+// InitKubernetesProvider creates a new KubernetesProvider.
+func InitKubernetesProvider(options ...func(*KubernetesProvider)) *KubernetesProvider {
+	p := &KubernetesProvider{}
+
+	// Set Defaults
+	p.PodInfoDir = DEFAULT_POD_INFO_DIR
+	p.ServiceAccountDir = DEFAULT_SERVICE_ACCOUNT_DIR
+	p.now = time.Now
+
+	// Option parameters
+	for _, op := range options {
+		op(p)
+	}
+
+	if p.RefreshInterval < 0 {
+		panic("FATAL: RefreshInterval cannot be negative.")
+	}
+
+	return p
+}
+
+// --- Option
+
+// OptionPodInfoDir sets the directory the downward API volume is mounted at
+func OptionPodInfoDir(dir string) func(p *KubernetesProvider) {
+	return func(p *KubernetesProvider) { p.PodInfoDir = dir }
+}
+
+// OptionServiceAccountDir sets the directory the service account is mounted at
+func OptionServiceAccountDir(dir string) func(p *KubernetesProvider) {
+	return func(p *KubernetesProvider) { p.ServiceAccountDir = dir }
+}
+
+// OptionKubernetesRefreshInterval sets how long the values are cached for
+func OptionKubernetesRefreshInterval(interval time.Duration) func(p *KubernetesProvider) {
+	return func(p *KubernetesProvider) { p.RefreshInterval = interval }
+}
+
+// OptionKubernetesEntities attaches the entities of the provider to every
+// event as a GlobalContext tagged KUBERNETES_ENTITIES_TAG.
+func OptionKubernetesEntities(provider *KubernetesProvider) func(t *Tracker) {
+	return OptionGlobalContexts(GlobalContext{
+		Tag:       KUBERNETES_ENTITIES_TAG,
+		Generator: func(event EventInfo) []SelfDescribingJson { return provider.Entities() },
+	})
+}
+
+// Entities returns the cached entities, reading them first if needed.
+func (p *KubernetesProvider) Entities() []SelfDescribingJson {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.setDefaults()
+	now := p.now()
+	if p.entities == nil || (p.RefreshInterval > 0 && now.Sub(p.readAt) >= p.RefreshInterval) {
+		p.entities = p.read()
+		p.readAt = now
+	}
+	return p.entities
+}
+
+// setDefaults sets the fields of a KubernetesProvider which was not created
+// with InitKubernetesProvider.
+func (p *KubernetesProvider) setDefaults() {
+	if p.PodInfoDir == "" {
+		p.PodInfoDir = DEFAULT_POD_INFO_DIR
+	}
+	if p.ServiceAccountDir == "" {
+		p.ServiceAccountDir = DEFAULT_SERVICE_ACCOUNT_DIR
+	}
+	if p.now == nil {
+		p.now = time.Now
+	}
+}
+
+// read builds the entities from the environment and the mounted files.
+func (p *KubernetesProvider) read() []SelfDescribingJson {
+	entities := []SelfDescribingJson{}
+
+	podName := p.value(K8S_ENV_POD_NAME)
+	if podName == "" && os.Getenv(K8S_ENV_SERVICE_HOST) != "" {
+		podName, _ = os.Hostname()
+	}
+	namespace := p.value(K8S_ENV_POD_NAMESPACE)
+	if namespace == "" {
+		namespace = readFile(filepath.Join(p.ServiceAccountDir, "namespace"))
+	}
+	if data := nonEmpty(map[string]string{
+		K8S_POD_NAME:     podName,
+		K8S_NAMESPACE:    namespace,
+		K8S_NODE_NAME:    p.value(K8S_ENV_NODE_NAME),
+		K8S_CLUSTER_NAME: p.value(K8S_ENV_CLUSTER_NAME),
+	}); len(data) > 0 {
+		entities = append(entities, *InitSelfDescribingJson(SCHEMA_KUBERNETES, data))
+	}
+
+	if data := nonEmpty(map[string]string{
+		CONTAINER_NAME:  p.value(K8S_ENV_CONTAINER_NAME),
+		CONTAINER_IMAGE: p.value(K8S_ENV_CONTAINER_IMAGE),
+	}); len(data) > 0 {
+		entities = append(entities, *InitSelfDescribingJson(SCHEMA_CONTAINER, data))
+	}
+
+	return entities
+}
+
+// value returns the environment variable, falling back to its file in the
+// downward API volume.
+func (p *KubernetesProvider) value(env string) string {
+	if value := os.Getenv(env); value != "" {
+		return value
+	}
+	return readFile(filepath.Join(p.PodInfoDir, strings.ToLower(env)))
+}
+
+// readFile returns the trimmed contents of the file, or an empty string if it
+// cannot be read.
+func readFile(name string) string {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// nonEmpty returns the entries of the map which have a value.
+func nonEmpty(values map[string]string) map[string]interface{} {
+	data := map[string]interface{}{}
+	for key, value := range values {
+		if value != "" {
+			data[key] = value
+		}
+	}
+	return data
+}
@@ -0,0 +1,136 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+)
+
+func TestKubernetesProvider(t *testing.T) {
+	assert := assert.New(t)
+	unsetKubernetesEnv(t)
+	t.Setenv(K8S_ENV_CLUSTER_NAME, "eu-west-1")
+	t.Setenv(K8S_ENV_NODE_NAME, "ip-10-0-9-9.ec2.internal")
+
+	provider := initTestKubernetesProvider()
+	entities := provider.Entities()
+	assert.Equal(2, len(entities))
+	assert.Equal(map[string]interface{}{
+		SCHEMA: SCHEMA_KUBERNETES,
+		DATA: map[string]interface{}{
+			K8S_POD_NAME:     "shop-7d4b9c6f8-x2kqp",
+			K8S_NAMESPACE:    "production",
+			K8S_NODE_NAME:    "ip-10-0-9-9.ec2.internal",
+			K8S_CLUSTER_NAME: "eu-west-1",
+		},
+	}, entities[0].Get())
+	assert.Equal(map[string]interface{}{
+		SCHEMA: SCHEMA_CONTAINER,
+		DATA: map[string]interface{}{
+			CONTAINER_NAME:  "shop",
+			CONTAINER_IMAGE: "ghcr.io/acme/shop:1.2.3",
+		},
+	}, entities[1].Get())
+
+	for _, entity := range entities {
+		assertMatchesPublishedSchema(t, assert, entity)
+	}
+}
+
+func TestKubernetesProviderCaches(t *testing.T) {
+	assert := assert.New(t)
+	unsetKubernetesEnv(t)
+	t.Setenv(K8S_ENV_POD_NAMESPACE, "staging")
+
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	provider := initTestKubernetesProvider(OptionKubernetesRefreshInterval(time.Minute))
+	provider.now = func() time.Time { return now }
+	assert.Equal("staging", provider.Entities()[0].data.(map[string]interface{})[K8S_NAMESPACE])
+
+	t.Setenv(K8S_ENV_POD_NAMESPACE, "production")
+	assert.Equal("staging", provider.Entities()[0].data.(map[string]interface{})[K8S_NAMESPACE])
+
+	now = now.Add(time.Minute)
+	assert.Equal("production", provider.Entities()[0].data.(map[string]interface{})[K8S_NAMESPACE])
+}
+
+func TestKubernetesProviderZeroValue(t *testing.T) {
+	assert := assert.New(t)
+	unsetKubernetesEnv(t)
+	t.Setenv(K8S_ENV_POD_NAMESPACE, "staging")
+
+	provider := &KubernetesProvider{RefreshInterval: time.Minute}
+	assert.NotPanics(func() { provider.Entities() })
+	assert.Equal("staging", provider.Entities()[0].data.(map[string]interface{})[K8S_NAMESPACE])
+	assert.Equal(DEFAULT_POD_INFO_DIR, provider.PodInfoDir)
+	assert.Equal(DEFAULT_SERVICE_ACCOUNT_DIR, provider.ServiceAccountDir)
+}
+
+func TestKubernetesProviderOutsideKubernetes(t *testing.T) {
+	assert := assert.New(t)
+	unsetKubernetesEnv(t)
+	dir := t.TempDir()
+	provider := InitKubernetesProvider(OptionPodInfoDir(dir), OptionServiceAccountDir(dir))
+	assert.Equal(0, len(provider.Entities()))
+
+	// Kubernetes sets the hostname to the pod name
+	t.Setenv(K8S_ENV_SERVICE_HOST, "10.96.0.1")
+	hostname, _ := os.Hostname()
+	provider = InitKubernetesProvider(OptionPodInfoDir(dir), OptionServiceAccountDir(dir))
+	assert.Equal(map[string]interface{}{K8S_POD_NAME: hostname}, provider.Entities()[0].data)
+
+	assert.PanicsWithValue("FATAL: RefreshInterval cannot be negative.", func() {
+		InitKubernetesProvider(OptionKubernetesRefreshInterval(-time.Second))
+	})
+}
+
+func TestTrackWithKubernetesEntities(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	unsetKubernetesEnv(t)
+
+	tracker, sent := initCapturingTracker(OptionKubernetesEntities(initTestKubernetesProvider()))
+	assert.Nil(tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com")}))
+	assert.Equal([]string{SCHEMA_KUBERNETES, SCHEMA_CONTAINER}, contextSchemas(t, (*sent)[0]))
+}
+
+func initTestKubernetesProvider(options ...func(*KubernetesProvider)) *KubernetesProvider {
+	fixtures := filepath.Join("testdata", "kubernetes")
+	options = append([]func(*KubernetesProvider){
+		OptionPodInfoDir(filepath.Join(fixtures, "podinfo")),
+		OptionServiceAccountDir(filepath.Join(fixtures, "serviceaccount")),
+	}, options...)
+	return InitKubernetesProvider(options...)
+}
+
+// unsetKubernetesEnv clears the environment variables read by the provider
+// for the duration of the test.
+func unsetKubernetesEnv(t *testing.T) {
+	for _, env := range []string{K8S_ENV_POD_NAME, K8S_ENV_POD_NAMESPACE, K8S_ENV_NODE_NAME, K8S_ENV_CLUSTER_NAME, K8S_ENV_CONTAINER_NAME, K8S_ENV_CONTAINER_IMAGE, K8S_ENV_SERVICE_HOST} {
+		env := env
+		if value, ok := os.LookupEnv(env); ok {
+			os.Unsetenv(env)
+			t.Cleanup(func() { os.Setenv(env, value) })
+		}
+	}
+}
@@ -0,0 +1,79 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"sort"
+)
+
+// postBytes returns the exact size of a POST request body holding events
+// whose serialised sizes, including their sent timestamp, are given.
+func postBytes(sizes ...int) int {
+	total := POST_WRAPPER_BYTES
+	for i, size := range sizes {
+		if i > 0 {
+			total++ // Separating comma
+		}
+		total += size
+	}
+	return total
+}
+
+// packPostRequests groups events into as few POST requests as possible
+// without any request body exceeding byteLimit, using first fit decreasing.
+//
+// It returns the indexes of the events in each request, in their original
+// order, and the indexes of events which are too large to send with
+// anything else.
+func packPostRequests(sizes []int, byteLimit int) ([][]int, []int) {
+	oversize := []int{}
+	order := []int{}
+	for i, size := range sizes {
+		if postBytes(size) > byteLimit {
+			oversize = append(oversize, i)
+		} else {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool { return sizes[order[a]] > sizes[order[b]] })
+
+	// Each event costs its size plus a separating comma, and the wrapper
+	// around the first event has no comma
+	capacity := byteLimit - POST_WRAPPER_BYTES + 1
+	requests := [][]int{}
+	used := []int{}
+	for _, i := range order {
+		cost := sizes[i] + 1
+		placed := false
+		for r := range requests {
+			if used[r]+cost <= capacity {
+				requests[r] = append(requests[r], i)
+				used[r] += cost
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			requests = append(requests, []int{i})
+			used = append(used, cost)
+		}
+	}
+
+	for _, request := range requests {
+		sort.Ints(request)
+	}
+	sort.Slice(requests, func(a, b int) bool { return requests[a][0] < requests[b][0] })
+
+	return requests, oversize
+}
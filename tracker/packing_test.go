@@ -0,0 +1,180 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"testing/quick"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/memory"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storageiface"
+)
+
+func TestPostWrapperBytes(t *testing.T) {
+	assert := assert.New(t)
+	envelope := map[string]interface{}{
+		SCHEMA: SCHEMA_PAYLOAD_DATA,
+		DATA:   []map[string]string{},
+	}
+	assert.Equal(POST_WRAPPER_BYTES, len(common.MapToJson(envelope)))
+}
+
+func TestPackPostRequests(t *testing.T) {
+	assert := assert.New(t)
+
+	// Packing in arrival order would need three requests
+	requests, oversize := packPostRequests([]int{50, 60, 50, 40}, POST_WRAPPER_BYTES+101)
+	assert.Equal([][]int{{0, 2}, {1, 3}}, requests)
+	assert.Equal([]int{}, oversize)
+	assert.Equal(POST_WRAPPER_BYTES+101, postBytes(50, 50))
+	assert.Equal(POST_WRAPPER_BYTES+101, postBytes(60, 40))
+
+	// Events too large for any request are sent on their own
+	requests, oversize = packPostRequests([]int{10, 500, 20}, 200)
+	assert.Equal([][]int{{0, 2}}, requests)
+	assert.Equal([]int{1}, oversize)
+
+	requests, oversize = packPostRequests([]int{}, 200)
+	assert.Equal(0, len(requests))
+	assert.Equal(0, len(oversize))
+}
+
+// TestPackPostRequestsProperties asserts for random sizes and limits that
+// every event is sent exactly once, that no request exceeds the limit and
+// that no two requests could have been sent as one.
+func TestPackPostRequestsProperties(t *testing.T) {
+	property := func(rawSizes []uint16, rawLimit uint16) bool {
+		limit := POST_WRAPPER_BYTES + int(rawLimit)%4000
+		sizes := []int{}
+		for _, size := range rawSizes {
+			sizes = append(sizes, int(size)%3000)
+		}
+
+		requests, oversize := packPostRequests(sizes, limit)
+
+		seen := append([]int{}, oversize...)
+		for _, i := range oversize {
+			if postBytes(sizes[i]) <= limit {
+				return false
+			}
+		}
+		totals := []int{}
+		for _, request := range requests {
+			if len(request) == 0 || !sort.IntsAreSorted(request) {
+				return false
+			}
+			requestSizes := []int{}
+			for _, i := range request {
+				requestSizes = append(requestSizes, sizes[i])
+			}
+			if postBytes(requestSizes...) > limit {
+				return false
+			}
+			totals = append(totals, postBytes(requestSizes...)-POST_WRAPPER_BYTES+1)
+			seen = append(seen, request...)
+		}
+		for a := range totals {
+			for b := a + 1; b < len(totals); b++ {
+				if POST_WRAPPER_BYTES-1+totals[a]+totals[b] <= limit {
+					return false
+				}
+			}
+		}
+
+		sort.Ints(seen)
+		for i := range seen {
+			if seen[i] != i {
+				return false
+			}
+		}
+		return len(seen) == len(sizes)
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 1000}); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestPostRequestsNeverExceedByteLimit asserts against the bodies actually
+// sent that only single oversize events ever exceed ByteLimitPost.
+func TestPostRequestsNeverExceedByteLimit(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	var lock sync.Mutex
+	var bodies [][]byte
+	httpmock.RegisterResponder("POST", "http://com.acme.collector/com.snowplowanalytics.snowplow/tp2",
+		func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			lock.Lock()
+			bodies = append(bodies, body)
+			lock.Unlock()
+			return httpmock.NewStringResponse(200, ""), nil
+		},
+	)
+
+	property := func(values []string, rawLimit uint16) bool {
+		limit := 150 + int(rawLimit)%3000
+		emitter := InitEmitter(
+			RequireCollectorUri("com.acme.collector"),
+			RequireStorage(*memory.Init()),
+			OptionHttpClient(http.DefaultClient),
+			OptionByteLimitPost(limit),
+		)
+
+		eventRows := []storageiface.EventRow{}
+		for i, value := range values {
+			p := *payload.Init()
+			p.Add(EVENT, common.NewString(EVENT_PAGE_VIEW))
+			p.Add(PAGE_URL, common.NewString("https://acme.com/?q=<"+value+">&\"quoted\""))
+			eventRows = append(eventRows, storageiface.EventRow{Id: i, Event: p})
+		}
+
+		bodies = nil
+		results := emitter.doSend(context.Background(), eventRows)
+
+		sent := 0
+		for _, result := range results {
+			sent += len(result.ids)
+		}
+		if sent != len(values) {
+			return false
+		}
+		for _, body := range bodies {
+			var envelope struct {
+				Data []map[string]string `json:"data"`
+			}
+			if err := json.Unmarshal(body, &envelope); err != nil {
+				return false
+			}
+			if len(body) > limit && len(envelope.Data) != 1 {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 200}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,121 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
+)
+
+// Plugin inspects, enriches, rewrites or drops events before the Tracker
+// hands them to the Emitter.
+type Plugin interface {
+	// BeforeTrack is called with the complete event payload and its contexts,
+	// including global contexts, which it may modify. Returning false drops
+	// the event without error.
+	BeforeTrack(payload payload.Payload, contexts *[]SelfDescribingJson) (keep bool, err error)
+
+	// AfterTrack is called once the event has been handed to the Emitter with
+	// the error of Emitter.Add.
+	AfterTrack(payload payload.Payload, err error)
+}
+
+// PluginFuncs is a Plugin built from optional functions.
+type PluginFuncs struct {
+	Before func(payload payload.Payload, contexts *[]SelfDescribingJson) (bool, error) // Optional
+	After  func(payload payload.Payload, err error)                                    // Optional
+}
+
+// BeforeTrack calls Before if it is set.
+func (p PluginFuncs) BeforeTrack(payload payload.Payload, contexts *[]SelfDescribingJson) (bool, error) {
+	if p.Before == nil {
+		return true, nil
+	}
+	return p.Before(payload, contexts)
+}
+
+// AfterTrack calls After if it is set.
+func (p PluginFuncs) AfterTrack(payload payload.Payload, err error) {
+	if p.After != nil {
+		p.After(payload, err)
+	}
+}
+
+// PluginError is returned by the Track functions when a plugin fails or
+// panics, in which case the event is not tracked.
+type PluginError struct {
+	Index int   // The position of the plugin in the chain
+	Err   error // The error returned by the plugin or recovered from its panic
+}
+
+func (e *PluginError) Error() string {
+	return fmt.Sprintf("plugin %d failed: %v", e.Index, e.Err)
+}
+
+func (e *PluginError) Unwrap() error {
+	return e.Err
+}
+
+// OptionPlugins sets the Tracker Plugins, which are called in order
+func OptionPlugins(plugins ...Plugin) func(t *Tracker) {
+	return func(t *Tracker) { t.Plugins = plugins }
+}
+
+// AddPlugins appends plugins to the end of the Tracker chain.
+func (t *Tracker) AddPlugins(plugins ...Plugin) {
+	t.Plugins = append(t.Plugins, plugins...)
+}
+
+// beforeTrack runs the chain of BeforeTrack hooks, stopping at the first
+// plugin which drops the event or fails.
+func (t Tracker) beforeTrack(payload payload.Payload, contexts *[]SelfDescribingJson) (bool, error) {
+	for index, plugin := range t.Plugins {
+		keep, err := callBeforeTrack(plugin, payload, contexts)
+		if err != nil {
+			return false, &PluginError{Index: index, Err: err}
+		}
+		if !keep {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// afterTrack runs the chain of AfterTrack hooks. A plugin which panics does
+// not stop the others from being called.
+func (t Tracker) afterTrack(payload payload.Payload, err error) {
+	for _, plugin := range t.Plugins {
+		callAfterTrack(plugin, payload, err)
+	}
+}
+
+func callBeforeTrack(plugin Plugin, payload payload.Payload, contexts *[]SelfDescribingJson) (keep bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			keep, err = false, fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return plugin.BeforeTrack(payload, contexts)
+}
+
+func callAfterTrack(plugin Plugin, payload payload.Payload, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("Plugin panicked in AfterTrack:", r)
+		}
+	}()
+	plugin.AfterTrack(payload, err)
+}
@@ -0,0 +1,121 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
+)
+
+func TestPlugins(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	calls := []string{}
+	tracker, sent := initCapturingTracker(
+		OptionGlobalContexts(GlobalContext{
+			Contexts: []SelfDescribingJson{*InitSelfDescribingJson("iglu:com.acme/deployment/jsonschema/1-0-0", map[string]interface{}{"env": "prod"})},
+		}),
+		OptionPlugins(
+			PluginFuncs{
+				Before: func(payload payload.Payload, contexts *[]SelfDescribingJson) (bool, error) {
+					calls = append(calls, "filter:"+payload.Get()[EVENT])
+					return !strings.HasSuffix(payload.Get()[PAGE_URL], "/health"), nil
+				},
+			},
+			PluginFuncs{
+				Before: func(payload payload.Payload, contexts *[]SelfDescribingJson) (bool, error) {
+					calls = append(calls, "enrich:"+payload.Get()[EVENT])
+					delete(payload.Pairs, PAGE_REFR)
+					*contexts = append(*contexts, *InitSelfDescribingJson("iglu:com.acme/request/jsonschema/1-0-0", map[string]interface{}{"id": "abc"}))
+					return true, nil
+				},
+				After: func(payload payload.Payload, err error) {
+					calls = append(calls, "after:"+payload.Get()[EVENT])
+				},
+			},
+		),
+	)
+
+	assert.Nil(tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com/health")}))
+	assert.Equal(0, len(*sent))
+	assert.Equal([]string{"filter:pv"}, calls)
+
+	calls = []string{}
+	assert.Nil(tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com"), Referrer: common.NewString("acme.com/login?token=secret")}))
+	assert.Equal(1, len(*sent))
+	assert.Equal("", (*sent)[0][PAGE_REFR])
+	assert.Equal([]string{"iglu:com.acme/deployment/jsonschema/1-0-0", "iglu:com.acme/request/jsonschema/1-0-0"}, contextSchemas(t, (*sent)[0]))
+	assert.Equal([]string{"filter:pv", "enrich:pv", "after:pv"}, calls)
+
+	calls = []string{}
+	assert.Nil(tracker.TrackEcommerceTransaction(EcommerceTransactionEvent{
+		OrderId:    common.NewString("order-1"),
+		TotalValue: common.NewFloat64(10),
+		Items: []EcommerceTransactionItemEvent{
+			{Sku: common.NewString("sku-1"), Price: common.NewFloat64(5), Quantity: common.NewInt64(2)},
+		},
+	}))
+	assert.Equal([]string{"filter:tr", "enrich:tr", "after:tr", "filter:ti", "enrich:ti", "after:ti"}, calls)
+	assert.Equal(3, len(*sent))
+}
+
+func TestPluginErrors(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	failure := errors.New("failure")
+	var trackErr error
+	tracker, sent := initCapturingTracker(
+		OptionPlugins(PluginFuncs{
+			After: func(payload payload.Payload, err error) { trackErr = err },
+		}),
+	)
+	tracker.AddPlugins(PluginFuncs{
+		Before: func(payload payload.Payload, contexts *[]SelfDescribingJson) (bool, error) {
+			switch payload.Get()[PAGE_URL] {
+			case "error":
+				return true, failure
+			case "panic":
+				panic("boom")
+			}
+			return true, nil
+		},
+		After: func(payload payload.Payload, err error) { panic("boom") },
+	})
+
+	err := tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("error")})
+	assert.Equal(&PluginError{Index: 1, Err: failure}, err)
+	assert.True(errors.Is(err, failure))
+	assert.Equal("plugin 1 failed: failure", err.Error())
+
+	err = tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("panic")})
+	assert.Equal("plugin 1 failed: panic: boom", err.Error())
+	assert.Equal(0, len(*sent))
+
+	httpmock.RegisterResponder("POST", "http://com.acme.collector/com.snowplowanalytics.snowplow/tp2",
+		httpmock.NewStringResponder(404, ""))
+	err = tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com")})
+	assert.Equal(&SendError{Status: 404, Attempts: 1}, err)
+	assert.Equal(err, trackErr)
+}
@@ -0,0 +1,164 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/storageiface"
+)
+
+// QueuePolicy decides what happens to an event added to an Emitter whose queue is full.
+type QueuePolicy int
+
+const (
+	QUEUE_POLICY_BLOCK QueuePolicy = iota // Wait for space to free up
+	QUEUE_POLICY_ERROR                    // Return ErrQueueFull
+	QUEUE_POLICY_DROP                     // Discard the event and count it as dropped
+)
+
+const (
+	DEFAULT_QUEUE_POLICY         = QUEUE_POLICY_BLOCK
+	DEFAULT_QUEUE_BLOCK_TIMEOUT  = 5 * time.Second // How long a blocked Add waits for space
+	DEFAULT_QUEUE_RETRY_INTERVAL = time.Second     // How often a blocked Add retries sending
+)
+
+// ErrQueueFull is returned when an event cannot be queued because the queue is full.
+var ErrQueueFull = errors.New("event queue is full")
+
+// queue tracks how many events the Emitter holds in storage so that Add can
+// push back once MaxQueueSize is reached.
+type queue struct {
+	lock    sync.Mutex
+	size    int
+	freed   chan struct{} // Closed and replaced whenever events are removed
+	dropped uint64
+}
+
+func newQueue(size int) *queue {
+	return &queue{size: size, freed: make(chan struct{})}
+}
+
+// reserve takes a slot for an event. If the queue is full it returns false and
+// a channel which is closed once slots are freed.
+func (q *queue) reserve(max int) (bool, <-chan struct{}) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.size >= max {
+		return false, q.freed
+	}
+	q.size++
+	return true, nil
+}
+
+// release frees count slots and wakes any blocked producers.
+func (q *queue) release(count int) {
+	if count <= 0 {
+		return
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.size -= count
+	if q.size < 0 {
+		q.size = 0
+	}
+	close(q.freed)
+	q.freed = make(chan struct{})
+}
+
+// drop counts an event discarded by QUEUE_POLICY_DROP.
+func (q *queue) drop() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.dropped++
+}
+
+// --- Emitter
+
+// acquire reserves space in the queue for an event, applying the QueuePolicy
+// if the queue is full. It returns false if the event should be discarded
+// without an error.
+func (e *Emitter) acquire(ctx context.Context) (bool, error) {
+	if e.MaxQueueSize == 0 {
+		return true, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.QueueBlockTimeout)
+	defer cancel()
+
+	for {
+		ok, freed := e.queue.reserve(e.MaxQueueSize)
+		if ok {
+			return true, nil
+		}
+
+		switch e.QueuePolicy {
+		case QUEUE_POLICY_ERROR:
+			return false, ErrQueueFull
+		case QUEUE_POLICY_DROP:
+			e.queue.drop()
+			return false, nil
+		}
+
+		// Keep trying to send so that space frees up once the collector recovers
+		e.start()
+		select {
+		case <-freed:
+		case <-time.After(DEFAULT_QUEUE_RETRY_INTERVAL):
+		case <-ctx.Done():
+			return false, fmt.Errorf("%w: %v", ErrQueueFull, ctx.Err())
+		}
+	}
+}
+
+// DroppedCount returns how many events have been discarded by QUEUE_POLICY_DROP.
+func (e *Emitter) DroppedCount() uint64 {
+	if e.queue == nil {
+		return 0
+	}
+	e.queue.lock.Lock()
+	defer e.queue.lock.Unlock()
+
+	return e.queue.dropped
+}
+
+// QueueSize returns how many events the Emitter is holding in storage, or -1
+// if the queue is unbounded and so not counted.
+func (e *Emitter) QueueSize() int {
+	if e.queue == nil {
+		return -1
+	}
+	e.queue.lock.Lock()
+	defer e.queue.lock.Unlock()
+
+	return e.queue.size
+}
+
+// --- Helpers
+
+// countEventRows counts the events in the storage, without reading them if
+// the storage implements CountingStorage.
+func countEventRows(storage storageiface.Storage) int {
+	if counting, ok := storage.(storageiface.CountingStorage); ok {
+		return counting.CountEventRows()
+	}
+	return len(storage.GetAllEventRows())
+}
@@ -0,0 +1,174 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
+)
+
+// RedactionMode selects which query parameters a Redactor redacts.
+type RedactionMode int
+
+const (
+	// REDACTION_MODE_DENY_LIST redacts the listed Parameters
+	REDACTION_MODE_DENY_LIST RedactionMode = iota
+
+	// REDACTION_MODE_ALLOW_LIST redacts every parameter which is not listed
+	REDACTION_MODE_ALLOW_LIST
+)
+
+// Redactor removes or masks sensitive query parameters, and optionally the
+// fragment, of the url and refr fields and of the named string fields inside
+// the contexts of every event.
+//
+// Parameter names are matched case-insensitively. The rest of the URL is left
+// exactly as it was, including the order and encoding of the parameters which
+// are kept.
+type Redactor struct {
+	Mode           RedactionMode // Optional, defaults to REDACTION_MODE_DENY_LIST
+	Parameters     []string      // Optional, the query parameters which are denied or allowed
+	Mask           string        // Optional, replaces redacted values instead of removing the parameters
+	RemoveFragment bool          // Optional, removes the fragment of every URL
+	ContextFields  []string      // Optional, the names of the context fields holding URLs
+}
+
+// OptionRedactor sets the Tracker Redactor
+func OptionRedactor(redactor Redactor) func(t *Tracker) {
+	return func(t *Tracker) { t.Redactor = &redactor }
+}
+
+// check panics if the Redactor is misconfigured.
+func (r Redactor) check() {
+	if r.Mode != REDACTION_MODE_DENY_LIST && r.Mode != REDACTION_MODE_ALLOW_LIST {
+		panic("FATAL: RedactionMode did not match DENY_LIST or ALLOW_LIST.")
+	}
+}
+
+// redact rewrites the URLs of the payload in place and returns the contexts
+// with their URLs redacted. The contexts passed in are not modified. A nil
+// Redactor returns the contexts as they are.
+func (r *Redactor) redact(p payload.Payload, contexts []SelfDescribingJson) []SelfDescribingJson {
+	if r == nil {
+		return contexts
+	}
+	for _, field := range []string{PAGE_URL, PAGE_REFR} {
+		if value, ok := p.Pairs[field]; ok {
+			p.Pairs[field] = r.redactUrl(value)
+		}
+	}
+	if len(r.ContextFields) == 0 || len(contexts) == 0 {
+		return contexts
+	}
+	redacted := make([]SelfDescribingJson, len(contexts))
+	for i, context := range contexts {
+		redacted[i] = SelfDescribingJson{schema: context.schema, data: r.redactValue(context.data)}
+	}
+	return redacted
+}
+
+// redactValue returns a copy of the context data with the URLs in the
+// ContextFields redacted at any depth.
+func (r *Redactor) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, element := range v {
+			if s, ok := element.(string); ok && r.isContextField(key) {
+				redacted[key] = r.redactUrl(s)
+			} else {
+				redacted[key] = r.redactValue(element)
+			}
+		}
+		return redacted
+	case map[string]string:
+		redacted := make(map[string]string, len(v))
+		for key, element := range v {
+			if r.isContextField(key) {
+				element = r.redactUrl(element)
+			}
+			redacted[key] = element
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, element := range v {
+			redacted[i] = r.redactValue(element)
+		}
+		return redacted
+	case []map[string]interface{}:
+		redacted := make([]map[string]interface{}, len(v))
+		for i, element := range v {
+			redacted[i] = r.redactValue(element).(map[string]interface{})
+		}
+		return redacted
+	}
+	return value
+}
+
+// redactUrl redacts the query parameters and fragment of a URL.
+func (r *Redactor) redactUrl(value string) string {
+	fragment := ""
+	if i := strings.IndexByte(value, '#'); i >= 0 {
+		value, fragment = value[:i], value[i:]
+		if r.RemoveFragment {
+			fragment = ""
+		}
+	}
+	i := strings.IndexByte(value, '?')
+	if i < 0 {
+		return value + fragment
+	}
+	base, query := value[:i], value[i+1:]
+
+	kept := []string{}
+	for _, parameter := range strings.Split(query, "&") {
+		name := parameter
+		if j := strings.IndexByte(parameter, '='); j >= 0 {
+			name = parameter[:j]
+		}
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		switch {
+		case !r.isRedacted(name):
+			kept = append(kept, parameter)
+		case r.Mask != "":
+			kept = append(kept, url.QueryEscape(name)+"="+url.QueryEscape(r.Mask))
+		}
+	}
+	if len(kept) == 0 {
+		return base + fragment
+	}
+	return base + "?" + strings.Join(kept, "&") + fragment
+}
+
+// isRedacted returns whether the query parameter is redacted.
+func (r *Redactor) isRedacted(name string) bool {
+	listed := false
+	for _, parameter := range r.Parameters {
+		if strings.EqualFold(parameter, name) {
+			listed = true
+			break
+		}
+	}
+	return listed == (r.Mode == REDACTION_MODE_DENY_LIST)
+}
+
+// isContextField returns whether the context field holds a URL to redact.
+func (r *Redactor) isContextField(key string) bool {
+	return contains(r.ContextFields, key)
+}
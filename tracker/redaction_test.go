@@ -0,0 +1,90 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+)
+
+func TestRedactUrl(t *testing.T) {
+	assert := assert.New(t)
+	deny := &Redactor{Parameters: []string{"token", "SessionId"}}
+	assert.Equal("https://acme.com/reset?lang=en&b=%2F", deny.redactUrl("https://acme.com/reset?token=abc&lang=en&sessionid=1&b=%2F"))
+	assert.Equal("https://acme.com/reset#step-2", deny.redactUrl("https://acme.com/reset?token=abc#step-2"))
+	assert.Equal("https://acme.com/?lang=en", deny.redactUrl("https://acme.com/?t%6fken=abc&lang=en"))
+	assert.Equal("https://acme.com/reset", deny.redactUrl("https://acme.com/reset"))
+	assert.Equal("acme.com?a&b=", deny.redactUrl("acme.com?a&token&b="))
+
+	masked := &Redactor{Parameters: []string{"token"}, Mask: "REDACTED", RemoveFragment: true}
+	assert.Equal("https://acme.com/reset?token=REDACTED&lang=en", masked.redactUrl("https://acme.com/reset?token=abc&lang=en#access_token=xyz"))
+	assert.Equal("https://acme.com/reset", masked.redactUrl("https://acme.com/reset#access_token=xyz"))
+
+	allow := &Redactor{Mode: REDACTION_MODE_ALLOW_LIST, Parameters: []string{"lang", "utm_source"}}
+	assert.Equal("https://acme.com/?lang=en&utm_source=mail", allow.redactUrl("https://acme.com/?lang=en&token=abc&utm_source=mail&sid=1"))
+	assert.Equal("https://acme.com/", allow.redactUrl("https://acme.com/?token=abc"))
+}
+
+func TestTrackWithRedactor(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	tracker, sent := initCapturingTracker(
+		OptionRedactor(Redactor{
+			Parameters:    []string{"token"},
+			ContextFields: []string{"link"},
+		}),
+	)
+
+	data := map[string]interface{}{
+		"link":    "https://acme.com/reset?token=abc&lang=en",
+		"comment": "https://acme.com/reset?token=abc",
+		"nested":  []interface{}{map[string]interface{}{"link": "https://acme.com/?token=def"}},
+	}
+	assert.Nil(tracker.TrackPageView(PageViewEvent{
+		PageUrl:  common.NewString("https://acme.com/reset?token=abc&lang=en"),
+		Referrer: common.NewString("https://mail.acme.com/?token=abc"),
+		Contexts: []SelfDescribingJson{*InitSelfDescribingJson("iglu:com.acme/email/jsonschema/1-0-0", data)},
+	}))
+
+	event := (*sent)[0]
+	assert.Equal("https://acme.com/reset?lang=en", event[PAGE_URL])
+	assert.Equal("https://mail.acme.com/", event[PAGE_REFR])
+
+	var contexts struct {
+		Data []struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	assert.Nil(json.Unmarshal([]byte(event[CONTEXT]), &contexts))
+	assert.Equal(map[string]interface{}{
+		"link":    "https://acme.com/reset?lang=en",
+		"comment": "https://acme.com/reset?token=abc",
+		"nested":  []interface{}{map[string]interface{}{"link": "https://acme.com/"}},
+	}, contexts.Data[0].Data)
+	assert.Equal("https://acme.com/reset?token=abc&lang=en", data["link"], "the context is not modified")
+}
+
+func TestRedactorPanics(t *testing.T) {
+	assert := assert.New(t)
+	assert.PanicsWithValue("FATAL: RedactionMode did not match DENY_LIST or ALLOW_LIST.", func() {
+		InitTracker(RequireEmitter(initSynchronousEmitter()), OptionRedactor(Redactor{Mode: RedactionMode(2)}))
+	})
+}
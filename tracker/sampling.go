@@ -0,0 +1,120 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
+)
+
+// SampleKey selects what an event is sampled on.
+type SampleKey int
+
+const (
+	// SAMPLE_KEY_USER_ID samples on the Subject user ID so that a user is
+	// consistently in or out, falling back to the event ID without one. The
+	// raw user ID is used, before any Anonymiser hashes it
+	SAMPLE_KEY_USER_ID SampleKey = iota
+
+	// SAMPLE_KEY_EVENT_ID samples each event independently
+	SAMPLE_KEY_EVENT_ID
+)
+
+// Sampler decides deterministically which events the Tracker sends.
+//
+// The rate of an event is that of its schema, otherwise that of its event
+// type, otherwise DefaultRate. Events sent at a rate below 1 carry a
+// SCHEMA_SAMPLING entity with the rate so that they can be reweighted.
+type Sampler struct {
+	DefaultRate *float64           // Optional, defaults to 1
+	EventTypes  map[string]float64 // Optional, rates by event type, e.g. "pv"
+	Schemas     map[string]float64 // Optional, rates by self-describing event schema
+	Key         SampleKey          // Optional, defaults to SAMPLE_KEY_USER_ID
+}
+
+// OptionSampler sets the Tracker Sampler
+func OptionSampler(sampler Sampler) func(t *Tracker) {
+	return func(t *Tracker) { t.Sampler = &sampler }
+}
+
+// check panics if any of the rates are outside of 0 and 1.
+func (s Sampler) check() {
+	rates := []float64{}
+	if s.DefaultRate != nil {
+		rates = append(rates, *s.DefaultRate)
+	}
+	for _, rate := range s.EventTypes {
+		rates = append(rates, rate)
+	}
+	for _, rate := range s.Schemas {
+		rates = append(rates, rate)
+	}
+	for _, rate := range rates {
+		if !(rate >= 0 && rate <= 1) {
+			panic("FATAL: Sample rates must be between 0 and 1.")
+		}
+	}
+	if s.Key != SAMPLE_KEY_USER_ID && s.Key != SAMPLE_KEY_EVENT_ID {
+		panic("FATAL: Sampler Key did not match USER_ID or EVENT_ID.")
+	}
+}
+
+// rate returns the sample rate of the event.
+func (s *Sampler) rate(event EventInfo) float64 {
+	if rate, ok := s.Schemas[event.Schema]; ok && event.Schema != "" {
+		return rate
+	}
+	if rate, ok := s.EventTypes[event.EventType]; ok {
+		return rate
+	}
+	if s.DefaultRate != nil {
+		return *s.DefaultRate
+	}
+	return 1
+}
+
+// sample returns the rate of the event and whether it is kept. A nil Sampler
+// keeps every event.
+func (s *Sampler) sample(event EventInfo) (float64, bool) {
+	if s == nil {
+		return 1, true
+	}
+	rate := s.rate(event)
+	if rate >= 1 {
+		return rate, true
+	}
+	return rate, sampleFraction(s.key(event.Payload)) < rate
+}
+
+// key returns the value the event is sampled on.
+func (s *Sampler) key(p payload.Payload) string {
+	if uid := p.Get()[UID]; s.Key == SAMPLE_KEY_USER_ID && uid != "" {
+		return "uid:" + uid
+	}
+	return "eid:" + p.Get()[EID]
+}
+
+// sampleFraction maps a key uniformly and deterministically onto [0, 1).
+func sampleFraction(key string) float64 {
+	sum := sha256.Sum256([]byte(key))
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11) / math.Exp2(53)
+}
+
+// samplingEntity returns the entity recording the rate an event was sent at.
+func samplingEntity(rate float64) SelfDescribingJson {
+	return *InitSelfDescribingJson(SCHEMA_SAMPLING, map[string]interface{}{SAMPLING_RATE: rate})
+}
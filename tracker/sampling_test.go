@@ -0,0 +1,168 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
+)
+
+func TestSamplerRate(t *testing.T) {
+	assert := assert.New(t)
+	sampler := &Sampler{
+		EventTypes: map[string]float64{EVENT_UNSTRUCTURED: 0.5, EVENT_PAGE_VIEW: 0.2},
+		Schemas:    map[string]float64{SCHEMA_USER_TIMINGS: 0.1},
+	}
+	assert.Equal(0.1, sampler.rate(EventInfo{EventType: EVENT_UNSTRUCTURED, Schema: SCHEMA_USER_TIMINGS}))
+	assert.Equal(0.5, sampler.rate(EventInfo{EventType: EVENT_UNSTRUCTURED, Schema: SCHEMA_SCREEN_VIEW}))
+	assert.Equal(0.2, sampler.rate(EventInfo{EventType: EVENT_PAGE_VIEW}))
+	assert.Equal(1.0, sampler.rate(EventInfo{EventType: EVENT_STRUCTURED}))
+
+	sampler.DefaultRate = common.NewFloat64(0.9)
+	assert.Equal(0.9, sampler.rate(EventInfo{EventType: EVENT_STRUCTURED}))
+
+	rate, keep := (*Sampler)(nil).sample(EventInfo{EventType: EVENT_STRUCTURED})
+	assert.Equal(1.0, rate)
+	assert.True(keep)
+}
+
+func TestSamplerIsDeterministic(t *testing.T) {
+	assert := assert.New(t)
+	sampler := &Sampler{DefaultRate: common.NewFloat64(0.1)}
+	eventSampler := &Sampler{DefaultRate: common.NewFloat64(0.1), Key: SAMPLE_KEY_EVENT_ID}
+
+	users, kept, eventsKept := 10000, 0, 0
+	for i := 0; i < users; i++ {
+		uid := "user-" + common.IntToString(i)
+		_, first := sampler.sample(sampleEvent(uid))
+		for j := 0; j < 3; j++ {
+			_, again := sampler.sample(sampleEvent(uid))
+			assert.Equal(first, again)
+		}
+		if first {
+			kept++
+		}
+		if _, keep := eventSampler.sample(sampleEvent(uid)); keep {
+			eventsKept++
+		}
+	}
+	assert.InDelta(0.1, float64(kept)/float64(users), 0.01)
+	assert.InDelta(0.1, float64(eventsKept)/float64(users), 0.02)
+
+	// Without a user ID the event ID is sampled on
+	assert.Equal("eid:", sampler.key(sampleEvent("").Payload)[:4])
+	assert.Equal("uid:user-1", sampler.key(sampleEvent("user-1").Payload))
+	assert.Equal("eid:", eventSampler.key(sampleEvent("user-1").Payload)[:4])
+}
+
+func TestTrackWithSampler(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	tracker, sent := initCapturingTracker(
+		OptionSampler(Sampler{
+			EventTypes: map[string]float64{EVENT_PAGE_VIEW: 0.5},
+			Schemas:    map[string]float64{SCHEMA_USER_TIMINGS: 0},
+		}),
+	)
+
+	assert.Nil(tracker.TrackTiming(TimingEvent{Category: common.NewString("db"), Variable: common.NewString("query"), Timing: common.NewInt64(10)}))
+	assert.Equal(0, len(*sent))
+
+	assert.Nil(tracker.TrackStructEvent(StructuredEvent{Category: common.NewString("shop"), Action: common.NewString("add-to-basket")}))
+	assert.Equal(1, len(*sent))
+	assert.Equal("", (*sent)[0][CONTEXT])
+
+	for i := 0; i < 100; i++ {
+		subject := InitSubject()
+		subject.SetUserId("user-" + common.IntToString(i%10))
+		assert.Nil(tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com"), Subject: subject}))
+	}
+	byUser := map[string]int{}
+	for _, event := range (*sent)[1:] {
+		byUser[event[UID]]++
+		assert.Equal([]string{SCHEMA_SAMPLING}, contextSchemas(t, event))
+		var contexts struct {
+			Data []struct {
+				Data map[string]float64 `json:"data"`
+			} `json:"data"`
+		}
+		assert.Nil(json.Unmarshal([]byte(event[CONTEXT]), &contexts))
+		assert.Equal(0.5, contexts.Data[0].Data[SAMPLING_RATE])
+	}
+	assert.NotEqual(0, len(byUser))
+	assert.NotEqual(10, len(byUser))
+	for _, count := range byUser {
+		assert.Equal(10, count, "a user is consistently in or out")
+	}
+}
+
+func TestSamplerIgnoresAnonymiserKey(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	sampledUsers := func(hashKey string) []string {
+		anonymiser := Anonymiser{HashKey: []byte(hashKey), HashFields: []string{UID}}
+		tracker, sent := initCapturingTracker(
+			OptionSampler(Sampler{DefaultRate: common.NewFloat64(0.5)}),
+			OptionAnonymiser(anonymiser),
+		)
+		users := []string{}
+		for i := 0; i < 20; i++ {
+			subject := InitSubject()
+			subject.SetUserId("user-" + common.IntToString(i))
+			assert.Nil(tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com"), Subject: subject}))
+			if len(*sent) > len(users) {
+				assert.Equal(anonymiser.hash("user-"+common.IntToString(i)), (*sent)[len(users)][UID])
+				users = append(users, "user-"+common.IntToString(i))
+			}
+		}
+		return users
+	}
+
+	// Rotating the key does not change which users are sampled
+	users := sampledUsers("2024-01")
+	assert.NotEqual(0, len(users))
+	assert.NotEqual(20, len(users))
+	assert.Equal(users, sampledUsers("2024-02"))
+}
+
+func TestSamplerPanics(t *testing.T) {
+	assert := assert.New(t)
+	assert.PanicsWithValue("FATAL: Sample rates must be between 0 and 1.", func() {
+		InitTracker(RequireEmitter(initSynchronousEmitter()), OptionSampler(Sampler{EventTypes: map[string]float64{EVENT_PAGE_VIEW: 1.5}}))
+	})
+	assert.PanicsWithValue("FATAL: Sample rates must be between 0 and 1.", func() {
+		InitTracker(RequireEmitter(initSynchronousEmitter()), OptionSampler(Sampler{DefaultRate: common.NewFloat64(-0.1)}))
+	})
+	assert.PanicsWithValue("FATAL: Sampler Key did not match USER_ID or EVENT_ID.", func() {
+		InitTracker(RequireEmitter(initSynchronousEmitter()), OptionSampler(Sampler{Key: SampleKey(2)}))
+	})
+}
+
+func sampleEvent(uid string) EventInfo {
+	p := *payload.Init()
+	p.Add(EVENT, common.NewString(EVENT_PAGE_VIEW))
+	p.Add(EID, common.NewString(common.GetUUID()))
+	p.Add(UID, common.NewString(uid))
+	return EventInfo{EventType: EVENT_PAGE_VIEW, Payload: p}
+}
@@ -0,0 +1,176 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"context"
+	"sync"
+)
+
+// sender makes sure that only one send loop runs at a time, however many
+// goroutines add events or flush the Emitter.
+type sender struct {
+	lock     sync.Mutex
+	running  bool
+	pending  bool               // Set when the loop is started again while it runs, so it reads Storage again
+	halted   bool               // Set by halt, after which no loop is started
+	inFlight int                // Events in the batch the running loop is sending
+	cancel   context.CancelFunc // Cancels the requests of the running loop
+	finished chan struct{}      // Closed once the running loop has exited
+}
+
+// next is called by the loop once Storage is empty. It reports whether the
+// loop was started again in the meantime, and otherwise marks it as stopped.
+func (s *sender) next() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.pending {
+		s.pending = false
+		return true
+	}
+	s.running = false
+	return false
+}
+
+// stop marks the loop which owns finished as stopped, unless it has already
+// stopped and another loop has been started.
+func (s *sender) stop(finished chan struct{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.finished == finished {
+		s.running = false
+		s.pending = false
+	}
+}
+
+// sending records how many events the running loop is sending.
+func (s *sender) sending(count int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.inFlight = count
+}
+
+// halt stops any more loops being started and cancels the requests of the
+// running loop. It returns a channel closed once that loop has exited and
+// how many events it was sending.
+func (s *sender) halt() (chan struct{}, int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.halted = true
+	if !s.running {
+		return nil, 0
+	}
+	s.cancel()
+	return s.finished, s.inFlight
+}
+
+// --- Emitter
+
+// start begins the send loop. If it is already running, it is asked to read
+// Storage again before exiting so that the events just added are not missed.
+func (e *Emitter) start() {
+	if e.Storage == nil {
+		return
+	}
+
+	e.sender.lock.Lock()
+	defer e.sender.lock.Unlock()
+
+	if e.sender.halted {
+		return
+	}
+	if e.sender.running {
+		e.sender.pending = true
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sendChannel, finished := make(chan bool, 1), make(chan struct{})
+	e.sender.running = true
+	e.sender.cancel = cancel
+	e.sender.finished = finished
+	e.SendChannel = sendChannel
+	go func() {
+		defer cancel()
+		e.sendLoop(ctx, sendChannel, finished)
+	}()
+}
+
+// sendLoop sends the events in Storage until it is empty or a whole batch
+// fails or the context is cancelled, then reports whether it finished on
+// the send channel.
+func (e *Emitter) sendLoop(ctx context.Context, sendChannel chan bool, finished chan struct{}) {
+	var done bool
+	defer func() {
+		e.sender.stop(finished)
+		close(finished)
+		sendChannel <- done
+	}()
+
+	for {
+		eventRows := e.readEventRows()
+
+		// If there are no events in the database exit
+		if len(eventRows) == 0 {
+			if e.sender.next() {
+				continue
+			}
+			break
+		}
+		e.sender.sending(len(eventRows))
+		results := e.doSend(ctx, eventRows)
+
+		// Process results
+		ids := []int{}
+		successes := []CallbackResult{}
+		failures := []CallbackResult{}
+
+		for _, res := range results {
+
+			count := len(res.ids)
+			status := res.status
+
+			if status >= 200 && status < 400 {
+				ids = append(ids, res.ids...)
+				successes = append(successes, CallbackResult{count, status})
+			} else {
+				failures = append(failures, CallbackResult{count, status})
+			}
+		}
+
+		if e.Callback != nil {
+			e.Callback(successes, failures)
+		}
+
+		// If all the events failed to be sent exit
+		if len(successes) == 0 && len(failures) > 0 {
+			break
+		}
+
+		deleted := e.Storage.DeleteEventRows(ids)
+		if e.queue != nil {
+			e.queue.release(int(deleted))
+		}
+		e.sender.sending(0)
+
+		// If the send was cancelled the failed events are left for later
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	done = true
+}
@@ -0,0 +1,149 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/session/sessioniface"
+)
+
+const (
+	DEFAULT_SESSION_TIMEOUT = 30 * time.Minute
+	SESSION_LOCK_STRIPES    = 64
+)
+
+// Sessions maintains a client session for every user, which is attached as a
+// SCHEMA_CLIENT_SESSION entity to each event whose Subject has a user ID.
+// A Timeout of 0 uses DEFAULT_SESSION_TIMEOUT.
+//
+// A session ends once the user has been inactive for longer than the
+// Timeout, and the next event of the user starts a new one. The schema
+// requires the userId of the entity to be a UUID, so it is derived from the
+// Subject user ID, which is still sent as the uid of the event.
+type Sessions struct {
+	Store   sessioniface.SessionStore
+	Timeout time.Duration
+
+	locks [SESSION_LOCK_STRIPES]sync.Mutex
+	now   func() time.Time
+}
+
+// OptionSessions sets the Tracker Sessions, kept in the store. A timeout of 0
+// uses DEFAULT_SESSION_TIMEOUT.
+//
+// The storageMechanism of the entity is reported by the store. The schema has
+// no value for process memory, so the memory store reports LOCAL_STORAGE.
+func OptionSessions(store sessioniface.SessionStore, timeout time.Duration) func(t *Tracker) {
+	return func(t *Tracker) { t.Sessions = &Sessions{Store: store, Timeout: timeout} }
+}
+
+// check panics if the Sessions are misconfigured and sets the defaults of
+// any fields which were left unset.
+func (s *Sessions) check() {
+	if s.Store == nil {
+		panic("FATAL: SessionStore cannot be nil.")
+	}
+	if s.Timeout < 0 {
+		panic("FATAL: Session Timeout cannot be negative.")
+	}
+	if s.Timeout == 0 {
+		s.Timeout = DEFAULT_SESSION_TIMEOUT
+	}
+	if s.now == nil {
+		s.now = time.Now
+	}
+}
+
+// entity updates the session of the user who tracked the event and returns
+// its entity. No entity is returned for an event without a user ID, or if the
+// session could not be stored.
+func (s *Sessions) entity(event EventInfo) (SelfDescribingJson, bool) {
+	if s == nil {
+		return SelfDescribingJson{}, false
+	}
+	userId := event.Payload.Get()[UID]
+	if userId == "" {
+		return SelfDescribingJson{}, false
+	}
+	session, err := s.update(userId, event.Payload.Get()[EID])
+	if err != nil {
+		log.Println("Session could not be stored: " + err.Error())
+		return SelfDescribingJson{}, false
+	}
+
+	data := map[string]interface{}{
+		CS_USER_ID:               uuid.NewSHA1(uuid.NameSpaceOID, []byte(session.UserId)).String(),
+		CS_SESSION_ID:            session.SessionId,
+		CS_SESSION_INDEX:         session.SessionIndex,
+		CS_PREVIOUS_SESSION_ID:   nil,
+		CS_STORAGE_MECHANISM:     s.Store.StorageMechanism(),
+		CS_FIRST_EVENT_ID:        session.FirstEventId,
+		CS_FIRST_EVENT_TIMESTAMP: session.FirstEventTime.UTC().Format("2006-01-02T15:04:05.000Z"),
+		CS_EVENT_INDEX:           session.EventIndex,
+	}
+	if session.PreviousSessionId != "" {
+		data[CS_PREVIOUS_SESSION_ID] = session.PreviousSessionId
+	}
+	return *InitSelfDescribingJson(SCHEMA_CLIENT_SESSION, data), true
+}
+
+// update records an event of the user, starting a new session if the last
+// one has timed out, and returns the session the event belongs to.
+func (s *Sessions) update(userId string, eventId string) (sessioniface.Session, error) {
+	lock := s.lock(userId)
+	lock.Lock()
+	defer lock.Unlock()
+
+	current, err := s.Store.GetSession(userId)
+	if err != nil {
+		return sessioniface.Session{}, err
+	}
+
+	now := s.now()
+	var session sessioniface.Session
+	if current != nil && now.Sub(current.LastActivity) <= s.Timeout {
+		session = *current
+		session.EventIndex++
+	} else {
+		session = sessioniface.Session{
+			UserId:         userId,
+			SessionId:      common.GetUUID(),
+			SessionIndex:   1,
+			FirstEventId:   eventId,
+			FirstEventTime: now,
+			EventIndex:     1,
+		}
+		if current != nil {
+			session.SessionIndex = current.SessionIndex + 1
+			session.PreviousSessionId = current.SessionId
+		}
+	}
+	session.LastActivity = now
+	return session, s.Store.SetSession(session)
+}
+
+// lock returns the lock held while the session of the user is updated, so
+// that the sessions of different users are mostly updated concurrently.
+func (s *Sessions) lock(userId string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(userId))
+	return &s.locks[h.Sum32()%SESSION_LOCK_STRIPES]
+}
@@ -0,0 +1,179 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/session/memory"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/session/sessioniface"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/session/sqlite3"
+)
+
+func TestSessions(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	tracker, sent := initCapturingTracker(OptionSessions(memory.Init(memory.OptionTTL(0)), 0))
+	assert.Equal(DEFAULT_SESSION_TIMEOUT, tracker.Sessions.Timeout)
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	tracker.Sessions.now = func() time.Time { return now }
+
+	// Events without a user ID have no session
+	assert.Nil(tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com")}))
+	assert.Equal("", (*sent)[0][CONTEXT])
+
+	trackUser := func(userId string) map[string]interface{} {
+		subject := InitSubject()
+		subject.SetUserId(userId)
+		assert.Nil(tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com"), Subject: subject}))
+		return sessionEntity(t, (*sent)[len(*sent)-1])
+	}
+
+	first := trackUser("user-1")
+	assert.Equal(float64(1), first[CS_SESSION_INDEX])
+	assert.Equal(float64(1), first[CS_EVENT_INDEX])
+	assert.Nil(first[CS_PREVIOUS_SESSION_ID])
+	assert.Equal((*sent)[1][EID], first[CS_FIRST_EVENT_ID])
+	assert.Equal("2023-06-01T12:00:00.000Z", first[CS_FIRST_EVENT_TIMESTAMP])
+	assert.Equal(sessioniface.STORAGE_MECHANISM_LOCAL_STORAGE, first[CS_STORAGE_MECHANISM])
+	assert.Regexp("^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$", first[CS_USER_ID])
+
+	now = now.Add(20 * time.Minute)
+	second := trackUser("user-1")
+	assert.Equal(first[CS_SESSION_ID], second[CS_SESSION_ID])
+	assert.Equal(first[CS_USER_ID], second[CS_USER_ID])
+	assert.Equal(first[CS_FIRST_EVENT_ID], second[CS_FIRST_EVENT_ID])
+	assert.Equal(float64(2), second[CS_EVENT_INDEX])
+
+	other := trackUser("user-2")
+	assert.NotEqual(first[CS_SESSION_ID], other[CS_SESSION_ID])
+	assert.NotEqual(first[CS_USER_ID], other[CS_USER_ID])
+	assert.Equal(float64(1), other[CS_SESSION_INDEX])
+
+	// Sessions end after the timeout without activity
+	now = now.Add(DEFAULT_SESSION_TIMEOUT + time.Second)
+	third := trackUser("user-1")
+	assert.NotEqual(first[CS_SESSION_ID], third[CS_SESSION_ID])
+	assert.Equal(first[CS_SESSION_ID], third[CS_PREVIOUS_SESSION_ID])
+	assert.Equal(float64(2), third[CS_SESSION_INDEX])
+	assert.Equal(float64(1), third[CS_EVENT_INDEX])
+	assert.Equal((*sent)[len(*sent)-1][EID], third[CS_FIRST_EVENT_ID])
+}
+
+func TestSessionsPersist(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	dbName := filepath.Join(t.TempDir(), "sessions.db")
+	subject := InitSubject()
+	subject.SetUserId("user-1")
+
+	store := sqlite3.Init(dbName)
+	tracker, sent := initCapturingTracker(OptionSubject(subject), OptionSessions(store, time.Hour))
+	assert.Nil(tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com")}))
+	store.Close()
+
+	store = sqlite3.Init(dbName)
+	defer store.Close()
+	restarted, restartedSent := initCapturingTracker(OptionSubject(subject), OptionSessions(store, time.Hour))
+	assert.Nil(restarted.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com")}))
+
+	first, second := sessionEntity(t, (*sent)[0]), sessionEntity(t, (*restartedSent)[0])
+	assert.Equal(sessioniface.STORAGE_MECHANISM_SQLITE, first[CS_STORAGE_MECHANISM])
+	assert.Equal(first[CS_SESSION_ID], second[CS_SESSION_ID])
+	assert.Equal(float64(2), second[CS_EVENT_INDEX])
+}
+
+func TestSessionsStoreFailure(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	subject := InitSubject()
+	subject.SetUserId("user-1")
+	tracker, sent := initCapturingTracker(OptionSubject(subject), OptionSessions(failingSessionStore{}, 0))
+	assert.Nil(tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com")}))
+	assert.Equal(1, len(*sent))
+	assert.Equal("", (*sent)[0][CONTEXT])
+
+	assert.PanicsWithValue("FATAL: SessionStore cannot be nil.", func() {
+		InitTracker(RequireEmitter(initSynchronousEmitter()), OptionSessions(nil, 0))
+	})
+	assert.PanicsWithValue("FATAL: Session Timeout cannot be negative.", func() {
+		InitTracker(RequireEmitter(initSynchronousEmitter()), OptionSessions(memory.Init(), -time.Second))
+	})
+}
+
+func TestSessionsDefaults(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	subject := InitSubject()
+	subject.SetUserId("user-1")
+	tracker, sent := initCapturingTracker(OptionSubject(subject), func(t *Tracker) { t.Sessions = &Sessions{Store: memory.Init()} })
+	assert.Equal(DEFAULT_SESSION_TIMEOUT, tracker.Sessions.Timeout)
+	assert.NotNil(tracker.Sessions.now)
+
+	assert.Nil(tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com")}))
+	assert.Nil(tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com")}))
+	first, second := sessionEntity(t, (*sent)[0]), sessionEntity(t, (*sent)[1])
+	assert.Equal(first[CS_SESSION_ID], second[CS_SESSION_ID])
+	assert.Equal(float64(2), second[CS_EVENT_INDEX])
+}
+
+type failingSessionStore struct{}
+
+func (failingSessionStore) GetSession(userId string) (*sessioniface.Session, error) {
+	return nil, errors.New("unavailable")
+}
+
+func (failingSessionStore) SetSession(session sessioniface.Session) error {
+	return errors.New("unavailable")
+}
+
+func (failingSessionStore) StorageMechanism() string {
+	return sessioniface.STORAGE_MECHANISM_SQLITE
+}
+
+// sessionEntity returns the data of the client session sent with an event.
+func sessionEntity(t *testing.T, event map[string]string) map[string]interface{} {
+	var contexts struct {
+		Data []struct {
+			Schema string                 `json:"schema"`
+			Data   map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(event[CONTEXT]), &contexts); err != nil {
+		t.Fatal(err)
+	}
+	for _, context := range contexts.Data {
+		if context.Schema == SCHEMA_CLIENT_SESSION {
+			return context.Data
+		}
+	}
+	t.Fatal("no client session")
+	return nil
+}
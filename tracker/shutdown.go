@@ -0,0 +1,118 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// ShutdownReport describes what happened to the queued events during a Shutdown.
+type ShutdownReport struct {
+	Sent      int // Events sent to the collector during the shutdown
+	Remaining int // Events left in Storage, which are only kept if the Storage is durable
+	InFlight  int // Events whose send was cancelled when the context was done, which are included in Remaining
+}
+
+// Shutdown stops the Emitter accepting events, writes any buffered events to
+// Storage and then keeps sending until Storage is empty or the context is
+// done. A send still in flight is then cancelled, leaving its events in
+// Storage, and once it has stopped the Storage is closed if it implements
+// io.Closer.
+//
+// The error is the context's error if events were left behind, or the error
+// from closing the Storage.
+func (e *Emitter) Shutdown(ctx context.Context) (ShutdownReport, error) {
+	e.Close()
+	if e.Storage == nil {
+		return ShutdownReport{}, nil
+	}
+
+	queued := countEventRows(e.Storage)
+	err := e.drain(ctx, queued)
+
+	// Storage must not be closed while the send loop may still delete from it
+	finished, inFlight := e.sender.halt()
+	if finished != nil {
+		<-finished
+	}
+	remaining := countEventRows(e.Storage)
+	report := ShutdownReport{Sent: queued - remaining, Remaining: remaining}
+	if err != nil {
+		report.InFlight = inFlight
+	}
+
+	if closer, ok := e.Storage.(io.Closer); ok {
+		if closeErr := closer.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	return report, err
+}
+
+// ShutdownOnSignal blocks until the process receives SIGINT or SIGTERM, or
+// the context is done, and then calls Shutdown allowing it gracePeriod to
+// send the remaining events.
+func (e *Emitter) ShutdownOnSignal(ctx context.Context, gracePeriod time.Duration) (ShutdownReport, error) {
+	signalCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	<-signalCtx.Done()
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer cancel()
+	return e.Shutdown(shutdownCtx)
+}
+
+// drain sends stored events until none remain or the context is done,
+// waiting RetryBackoff between attempts which make no progress.
+func (e *Emitter) drain(ctx context.Context, remaining int) error {
+	for remaining > 0 {
+		e.Flush()
+		if err := e.waitForSend(ctx); err != nil {
+			return err
+		}
+
+		previous := remaining
+		remaining = countEventRows(e.Storage)
+		if remaining == previous {
+			select {
+			case <-time.After(e.RetryBackoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+}
+
+// waitForSend waits for the current send loop to finish or the context to be done.
+func (e *Emitter) waitForSend(ctx context.Context) error {
+	e.sender.lock.Lock()
+	finished := e.sender.finished
+	e.sender.lock.Unlock()
+	if finished == nil {
+		return nil
+	}
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
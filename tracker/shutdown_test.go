@@ -0,0 +1,171 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/storage/sqlite3"
+)
+
+func TestEmitterShutdown(t *testing.T) {
+	assert := assert.New(t)
+	dbName := filepath.Join(t.TempDir(), "test.db")
+	emitter := initFailingQueueEmitter(t, OptionMaxQueueSize(0), OptionAsync(10), RequireStorage(*sqlite3.Init(dbName)))
+
+	for i := 0; i < 30; i++ {
+		assert.Nil(emitter.Add(queuePayload()))
+	}
+
+	emitter.Close()
+	emitter.Stop()
+
+	// The collector recovers in time for the shutdown
+	httpmock.RegisterResponder("POST", "http://com.acme.collector/com.snowplowanalytics.snowplow/tp2",
+		httpmock.NewStringResponder(200, ""))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	report, err := emitter.Shutdown(ctx)
+	assert.Nil(err)
+	assert.Equal(ShutdownReport{Sent: 30, Remaining: 0}, report)
+	assert.Equal(ErrEmitterClosed, emitter.Add(queuePayload()))
+
+	// The storage has been closed
+	assert.False(emitter.Storage.AddEventRow(queuePayload()))
+}
+
+func TestEmitterShutdownDeadline(t *testing.T) {
+	assert := assert.New(t)
+	dbName := filepath.Join(t.TempDir(), "test.db")
+	emitter := initFailingQueueEmitter(t, OptionMaxQueueSize(0), OptionRetryBackoff(5*time.Millisecond), RequireStorage(*sqlite3.Init(dbName)))
+
+	for i := 0; i < 3; i++ {
+		assert.Nil(emitter.Add(queuePayload()))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	report, err := emitter.Shutdown(ctx)
+	assert.Equal(context.DeadlineExceeded, err)
+	assert.Equal(0, report.Sent)
+	assert.Equal(3, report.Remaining)
+	assert.Contains([]int{0, 3}, report.InFlight, "the deadline may pass during a retry")
+
+	// Events left behind are kept by durable storage
+	storage := *sqlite3.Init(dbName)
+	defer storage.Close()
+	assert.Equal(3, len(storage.GetAllEventRows()))
+}
+
+func TestEmitterShutdownInFlight(t *testing.T) {
+	assert := assert.New(t)
+	dbName := filepath.Join(t.TempDir(), "test.db")
+	emitter := initFailingQueueEmitter(t, OptionMaxQueueSize(0), RequireStorage(*sqlite3.Init(dbName)))
+	emitter.Close()
+	emitter.Stop()
+
+	// The collector never answers, so the send is still in flight at the deadline
+	httpmock.RegisterResponder("POST", "http://com.acme.collector/com.snowplowanalytics.snowplow/tp2",
+		func(req *http.Request) (*http.Response, error) {
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		},
+	)
+	for i := 0; i < 3; i++ {
+		assert.True(emitter.Storage.AddEventRow(queuePayload()))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	report, err := emitter.Shutdown(ctx)
+	assert.Equal(context.DeadlineExceeded, err)
+	assert.Equal(ShutdownReport{Sent: 0, Remaining: 3, InFlight: 3}, report)
+	assert.False(emitter.IsSending())
+
+	// The cancelled events are kept for the next run
+	storage := *sqlite3.Init(dbName)
+	defer storage.Close()
+	assert.Equal(3, len(storage.GetAllEventRows()))
+}
+
+func TestEmitterShutdownSynchronous(t *testing.T) {
+	assert := assert.New(t)
+	emitter := initSynchronousEmitter()
+	report, err := emitter.Shutdown(context.Background())
+	assert.Nil(err)
+	assert.Equal(ShutdownReport{}, report)
+	assert.Equal(ErrEmitterClosed, emitter.Add(queuePayload()))
+}
+
+func TestTrackerShutdownOnSignal(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("POST", "http://com.acme.collector/com.snowplowanalytics.snowplow/tp2",
+		httpmock.NewStringResponder(200, ""))
+
+	// Keep SIGTERM from stopping the test process if it arrives before the helper is listening
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	tracker := InitTracker(RequireEmitter(InitEmitter(
+		RequireCollectorUri("com.acme.collector"),
+		RequireStorage(*sqlite3.Init(filepath.Join(t.TempDir(), "test.db"))),
+		OptionHttpClient(http.DefaultClient),
+		OptionAsync(10),
+	)))
+	assert.Nil(tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com")}))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := tracker.ShutdownOnSignal(context.Background(), time.Second)
+		done <- err
+	}()
+
+	process, _ := os.FindProcess(os.Getpid())
+	for {
+		process.Signal(syscall.SIGTERM)
+		select {
+		case err := <-done:
+			assert.Nil(err)
+			assert.Equal(ErrEmitterClosed, tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com")}))
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestTrackerShutdownOnSignalContext(t *testing.T) {
+	assert := assert.New(t)
+	tracker := InitTracker(RequireEmitter(initSynchronousEmitter()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report, err := tracker.ShutdownOnSignal(ctx, time.Second)
+	assert.Nil(err)
+	assert.Equal(ShutdownReport{}, report)
+}
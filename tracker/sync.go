@@ -0,0 +1,125 @@
+//
+// Copyright (c) 2016-2023 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package tracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
+	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/payload"
+)
+
+const (
+	DEFAULT_MAX_RETRIES   = 2
+	DEFAULT_RETRY_BACKOFF = 100 * time.Millisecond // Doubled after every failed attempt
+)
+
+// ErrEventTooLarge is returned by a synchronous Emitter for an event which
+// exceeds the byte limits. The event is not sent.
+var ErrEventTooLarge = errors.New("event exceeds the byte limit")
+
+// SendError is returned by a synchronous Emitter when the collector did not accept an event.
+type SendError struct {
+	Status   int // Status of the last attempt, or -1 if the collector could not be reached
+	Attempts int
+}
+
+func (err *SendError) Error() string {
+	if err.Status == -1 {
+		return fmt.Sprintf("collector could not be reached after %d attempts", err.Attempts)
+	}
+	return fmt.Sprintf("collector responded with status %d after %d attempts", err.Status, err.Attempts)
+}
+
+// sendNow sends the event to the collector on the calling goroutine,
+// retrying network errors, 429 and 5xx responses up to MaxRetries times.
+func (e *Emitter) sendNow(ctx context.Context, payload payload.Payload) error {
+	e.ingest.lock.RLock()
+	defer e.ingest.lock.RUnlock()
+
+	if e.ingest.closed {
+		return ErrEmitterClosed
+	}
+	if e.tooLarge(payload) {
+		return ErrEventTooLarge
+	}
+
+	var result SendResult
+	attempts := 0
+	for {
+		result = e.sendEvent(payload)
+		attempts++
+		if isSuccess(result.status) || !isRetryable(result.status) || attempts > e.MaxRetries {
+			break
+		}
+
+		// Wait before retrying, giving up early if the context is done
+		backoff := e.RetryBackoff << (attempts - 1)
+		select {
+		case <-time.After(backoff):
+			continue
+		case <-ctx.Done():
+		}
+		break
+	}
+
+	outcome := []CallbackResult{{Count: 1, Status: result.status}}
+	if isSuccess(result.status) {
+		if e.Callback != nil {
+			e.Callback(outcome, []CallbackResult{})
+		}
+		return nil
+	}
+	if e.Callback != nil {
+		e.Callback([]CallbackResult{}, outcome)
+	}
+	return &SendError{Status: result.status, Attempts: attempts}
+}
+
+// tooLarge checks whether the event exceeds the byte limits: ByteLimitPost,
+// unless it is sent via GET and fits within ByteLimitGet.
+func (e *Emitter) tooLarge(event payload.Payload) bool {
+	stm := common.GetTimestampString()
+	if e.RequestType == "GET" {
+		params := common.MapToQueryParams(event.Get())
+		params.Set(SENT_TIMESTAMP, stm)
+		if common.CountBytesInString(params.Encode()) <= e.ByteLimitGet {
+			return false
+		}
+	}
+	return postBytes(eventSizes([]payload.Payload{event}, stm)...) > e.ByteLimitPost
+}
+
+// sendEvent makes a single request to the collector for an event within
+// the byte limits, returning the status the collector responded with.
+func (e *Emitter) sendEvent(event payload.Payload) SendResult {
+	url := e.GetCollectorUrl()
+	if e.RequestType == "GET" {
+		return e.getEventRequest(context.Background(), url, nil, event)
+	}
+	return e.postRequest(context.Background(), url, nil, []payload.Payload{event}, false)
+}
+
+// isSuccess checks whether the collector accepted a request.
+func isSuccess(status int) bool {
+	return status >= 200 && status < 400
+}
+
+// isRetryable checks whether a failed request may succeed if it is sent again.
+func isRetryable(status int) bool {
+	return status == -1 || status == 429 || status >= 500
+}
@@ -14,6 +14,7 @@
 package tracker
 
 import (
+	"context"
 	"time"
 
 	"github.com/snowplow/snowplow-golang-tracker/v3/pkg/common"
@@ -32,12 +33,19 @@ type Tracker struct {
 	AppId        string
 	Platform     string
 	Base64Encode bool
+	Plugins      []Plugin
+	Sampler      *Sampler
+	Anonymiser   *Anonymiser
+	Redactor     *Redactor
+	Sessions     *Sessions
+
+	globalContexts *globalContexts
 }
 
 // InitTracker creates a new tracker instance linked to an emitter and subject.
 // Will assert that the Emitter is valid and not nil.
 func InitTracker(options ...func(*Tracker)) *Tracker {
-	t := &Tracker{}
+	t := &Tracker{globalContexts: &globalContexts{}}
 
 	// Set Defaults
 	t.Platform = DEFAULT_PLATFORM
@@ -53,6 +61,19 @@ func InitTracker(options ...func(*Tracker)) *Tracker {
 		panic("FATAL: Emitter cannot be nil.")
 	}
 
+	if t.Sampler != nil {
+		t.Sampler.check()
+	}
+	if t.Anonymiser != nil {
+		t.Anonymiser.check()
+	}
+	if t.Redactor != nil {
+		t.Redactor.check()
+	}
+	if t.Sessions != nil {
+		t.Sessions.check()
+	}
+
 	return t
 }
 
@@ -99,7 +120,7 @@ func (t Tracker) FlushEmitter() {
 
 func (t *Tracker) waitForEmitter(flushSleepTimeMs int) {
 	for {
-		if !t.Emitter.IsSending() || t.Emitter.SendChannel == nil {
+		if !t.Emitter.IsSending() {
 			break
 		}
 		time.Sleep(time.Duration(flushSleepTimeMs) * time.Millisecond)
@@ -112,11 +133,16 @@ func (t *Tracker) waitForEmitter(flushSleepTimeMs int) {
 func (t *Tracker) BlockingFlush(flushAttempts int, flushSleepTimeMs int) int {
 	t.waitForEmitter(flushSleepTimeMs)
 
+	// A synchronous Emitter has already sent everything it was given
+	if t.Emitter.Storage == nil {
+		return 0
+	}
+
 	rowCount := 0
 	attemptCount := 0
 
 	for {
-		rowCount = len(t.Emitter.Storage.GetAllEventRows())
+		rowCount = countEventRows(t.Emitter.Storage)
 		if attemptCount >= flushAttempts || rowCount == 0 {
 			break
 		} else {
@@ -129,11 +155,28 @@ func (t *Tracker) BlockingFlush(flushAttempts int, flushSleepTimeMs int) int {
 	return rowCount
 }
 
+// Shutdown stops the emitter accepting events and sends what it can before
+// the context is done. See Emitter.Shutdown.
+func (t Tracker) Shutdown(ctx context.Context) (ShutdownReport, error) {
+	return t.Emitter.Shutdown(ctx)
+}
+
+// ShutdownOnSignal shuts the emitter down once the process receives SIGINT
+// or SIGTERM. See Emitter.ShutdownOnSignal.
+func (t Tracker) ShutdownOnSignal(ctx context.Context, gracePeriod time.Duration) (ShutdownReport, error) {
+	return t.Emitter.ShutdownOnSignal(ctx, gracePeriod)
+}
+
 // --- Event Senders
 
-// track takes the event payload and context and completes the build
-// process before handing it off to the emitter.
-func (t Tracker) track(payload payload.Payload, contexts []SelfDescribingJson) {
+// track takes the event payload, the schema of a self-describing event and
+// the event contexts and completes the build process before handing it off
+// to the emitter.
+//
+// The error is either a PluginError or that of Emitter.Add, which is only
+// returned if the Emitter has a MaxQueueSize or the Storage failed to add the
+// event.
+func (t Tracker) track(payload payload.Payload, schema string, contexts []SelfDescribingJson) error {
 
 	// Add standard KV Pairs
 	payload.Add(T_VERSION, common.NewString(TRACKER_VERSION))
@@ -141,6 +184,40 @@ func (t Tracker) track(payload payload.Payload, contexts []SelfDescribingJson) {
 	payload.Add(APP_ID, common.NewString(t.AppId))
 	payload.Add(NAMESPACE, common.NewString(t.Namespace))
 
+	// Drop the events which are sampled out before doing any more work. This
+	// happens before anonymisation so that users are sampled on their raw
+	// user ID, which does not change when the Anonymiser HashKey is rotated
+	event := EventInfo{EventType: payload.Get()[EVENT], Schema: schema, Payload: payload}
+	rate, keep := t.Sampler.sample(event)
+	if !keep {
+		return nil
+	}
+
+	// Anonymise the subject fields before anything else sees them
+	t.Anonymiser.anonymise(payload)
+
+	// Attach the matching global contexts after those of the event
+	if global := t.globalContexts.get(event); len(global) > 0 {
+		contexts = append(append([]SelfDescribingJson{}, contexts...), global...)
+	}
+	if rate < 1 {
+		contexts = append(append([]SelfDescribingJson{}, contexts...), samplingEntity(rate))
+	}
+	if session, ok := t.Sessions.entity(event); ok {
+		contexts = append(append([]SelfDescribingJson{}, contexts...), session)
+	}
+
+	// Run the plugin chain, which may rewrite or drop the event
+	if len(t.Plugins) > 0 {
+		contexts = append([]SelfDescribingJson{}, contexts...)
+		if keep, err := t.beforeTrack(payload, &contexts); !keep {
+			return err
+		}
+	}
+
+	// Redact the URLs last so that those added by plugins are included
+	contexts = t.Redactor.redact(payload, contexts)
+
 	// Build the final context and add it to the payload
 	if contexts != nil && len(contexts) > 0 {
 		dataArray := []map[string]interface{}{}
@@ -152,54 +229,62 @@ func (t Tracker) track(payload payload.Payload, contexts []SelfDescribingJson) {
 	}
 
 	// Add the event to the Emitter.
-	t.Emitter.Add(payload)
+	err := t.Emitter.Add(payload)
+	t.afterTrack(payload, err)
+	return err
 }
 
 // TrackPageView sends a page view event.
-func (t Tracker) TrackPageView(e PageViewEvent) {
+func (t Tracker) TrackPageView(e PageViewEvent) error {
 	e.Init()
 	e.SetSubjectIfNil(t.Subject)
-	t.track(e.Get(), e.Contexts)
+	return t.track(e.Get(), "", e.Contexts)
 }
 
 // TrackStructEvent sends a structured event.
-func (t Tracker) TrackStructEvent(e StructuredEvent) {
+func (t Tracker) TrackStructEvent(e StructuredEvent) error {
 	e.Init()
 	e.SetSubjectIfNil(t.Subject)
-	t.track(e.Get(), e.Contexts)
+	return t.track(e.Get(), "", e.Contexts)
 }
 
 // TrackSelfDescribingEvent sends a self-described event.
-func (t Tracker) TrackSelfDescribingEvent(e SelfDescribingEvent) {
+func (t Tracker) TrackSelfDescribingEvent(e SelfDescribingEvent) error {
 	e.Init()
 	e.SetSubjectIfNil(t.Subject)
-	t.track(e.Get(t.Base64Encode), e.Contexts)
+	return t.track(e.Get(t.Base64Encode), e.Event.schema, e.Contexts)
 }
 
 // TrackScreenView sends a screen view event.
-func (t Tracker) TrackScreenView(e ScreenViewEvent) {
+func (t Tracker) TrackScreenView(e ScreenViewEvent) error {
 	e.Init()
-	t.TrackSelfDescribingEvent(e.Get())
+	return t.TrackSelfDescribingEvent(e.Get())
 }
 
 // TrackTiming sends a timing event.
-func (t Tracker) TrackTiming(e TimingEvent) {
+func (t Tracker) TrackTiming(e TimingEvent) error {
 	e.Init()
-	t.TrackSelfDescribingEvent(e.Get())
+	return t.TrackSelfDescribingEvent(e.Get())
 }
 
-// TrackEcommerceTransaction sends an ecommerce transaction event.
-func (t Tracker) TrackEcommerceTransaction(e EcommerceTransactionEvent) {
+// TrackEcommerceTransaction sends an ecommerce transaction event followed by
+// an event for each of its items, stopping at the first event which fails.
+func (t Tracker) TrackEcommerceTransaction(e EcommerceTransactionEvent) error {
 	e.Init()
 	e.SetSubjectIfNil(t.Subject)
-	t.track(e.Get(), e.Contexts)
+	if err := t.track(e.Get(), "", e.Contexts); err != nil {
+		return err
+	}
 	for _, item := range e.Items {
-		t.trackEcommerceTransationItem(item, e.OrderId, e.Currency, e.Timestamp, e.TrueTimestamp)
+		if err := t.trackEcommerceTransationItem(item, e.OrderId, e.Currency, e.Timestamp, e.TrueTimestamp); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 // trackEcommerceTransationItem tracks the individual Ecommerce Items.
-func (t Tracker) trackEcommerceTransationItem(e EcommerceTransactionItemEvent, orderId *string, currency *string, timestamp *int64, trueTimestamp *int64) {
+func (t Tracker) trackEcommerceTransationItem(e EcommerceTransactionItemEvent, orderId *string, currency *string, timestamp *int64, trueTimestamp *int64) error {
 	e.Init()
 	e.SetSubjectIfNil(t.Subject)
 	ep := e.Get()
@@ -207,7 +292,49 @@ func (t Tracker) trackEcommerceTransationItem(e EcommerceTransactionItemEvent, o
 	ep.Add(TI_ITEM_CURRENCY, currency)
 	ep.Add(TIMESTAMP, common.NewString(common.Int64ToString(timestamp)))
 	ep.Add(TRUE_TIMESTAMP, common.NewString(common.Int64ToString(trueTimestamp)))
-	t.track(ep, e.Contexts)
+	return t.track(ep, "", e.Contexts)
+}
+
+// TrackAddToCart sends a Snowplow ecommerce add to cart action.
+func (t Tracker) TrackAddToCart(e CartEvent) error {
+	e.Init()
+	return t.TrackSelfDescribingEvent(e.Get(ECOMM_ACTION_ADD_TO_CART))
+}
+
+// TrackRemoveFromCart sends a Snowplow ecommerce remove from cart action.
+func (t Tracker) TrackRemoveFromCart(e CartEvent) error {
+	e.Init()
+	return t.TrackSelfDescribingEvent(e.Get(ECOMM_ACTION_REMOVE_FROM_CART))
+}
+
+// TrackCheckoutStep sends a Snowplow ecommerce checkout step action.
+func (t Tracker) TrackCheckoutStep(e CheckoutStepEvent) error {
+	e.Init()
+	return t.TrackSelfDescribingEvent(e.Get())
+}
+
+// TrackTransaction sends a Snowplow ecommerce transaction action.
+func (t Tracker) TrackTransaction(e TransactionEvent) error {
+	e.Init()
+	return t.TrackSelfDescribingEvent(e.Get())
+}
+
+// TrackRefund sends a Snowplow ecommerce refund action.
+func (t Tracker) TrackRefund(e RefundEvent) error {
+	e.Init()
+	return t.TrackSelfDescribingEvent(e.Get())
+}
+
+// TrackPromotionView sends a Snowplow ecommerce promotion view action.
+func (t Tracker) TrackPromotionView(e PromotionEvent) error {
+	e.Init()
+	return t.TrackSelfDescribingEvent(e.Get(ECOMM_ACTION_PROMO_VIEW))
+}
+
+// TrackPromotionClick sends a Snowplow ecommerce promotion click action.
+func (t Tracker) TrackPromotionClick(e PromotionEvent) error {
+	e.Init()
+	return t.TrackSelfDescribingEvent(e.Get(ECOMM_ACTION_PROMO_CLICK))
 }
 
 // --- Setters
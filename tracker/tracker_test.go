@@ -387,3 +387,69 @@ func TestTrackFunctionsWithEventSubject(t *testing.T) {
 	tracker.Emitter.Stop()
 	tracker.BlockingFlush(5, 10)
 }
+
+func TestTrackFunctionsQueueFull(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder(
+		"POST",
+		"http://com.acme.collector/com.snowplowanalytics.snowplow/tp2",
+		httpmock.NewStringResponder(500, ""),
+	)
+
+	tracker := InitTracker(
+		RequireEmitter(InitEmitter(
+			RequireCollectorUri("com.acme.collector"),
+			RequireStorage(*memory.Init()),
+			OptionHttpClient(http.DefaultClient),
+			OptionMaxQueueSize(2),
+			OptionQueuePolicy(QUEUE_POLICY_ERROR),
+		)),
+	)
+
+	assert.Nil(tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com")}))
+	assert.Equal(ErrQueueFull, tracker.TrackEcommerceTransaction(EcommerceTransactionEvent{
+		OrderId:    common.NewString("order-id"),
+		TotalValue: common.NewFloat64(19.99),
+		Items: []EcommerceTransactionItemEvent{
+			{Sku: common.NewString("sku-1"), Price: common.NewFloat64(19.99), Quantity: common.NewInt64(1)},
+		},
+	}))
+	assert.Equal(2, tracker.Emitter.QueueSize())
+	assert.Equal(ErrQueueFull, tracker.TrackStructEvent(StructuredEvent{Category: common.NewString("shop"), Action: common.NewString("add-to-basket")}))
+	tracker.Emitter.Stop()
+}
+
+func TestTrackFunctionsSynchronous(t *testing.T) {
+	assert := assert.New(t)
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder(
+		"POST",
+		"http://com.acme.collector/com.snowplowanalytics.snowplow/tp2",
+		httpmock.NewStringResponder(200, ""),
+	)
+
+	tracker := InitTracker(
+		RequireEmitter(InitEmitter(
+			RequireCollectorUri("com.acme.collector"),
+			OptionHttpClient(http.DefaultClient),
+			OptionSynchronous(true),
+		)),
+	)
+
+	assert.Nil(tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com")}))
+	assert.Nil(tracker.TrackStructEvent(StructuredEvent{Category: common.NewString("shop"), Action: common.NewString("add-to-basket")}))
+	assert.Equal(2, httpmock.GetTotalCallCount())
+	assert.Equal(0, tracker.BlockingFlush(5, 10))
+
+	httpmock.RegisterResponder(
+		"POST",
+		"http://com.acme.collector/com.snowplowanalytics.snowplow/tp2",
+		httpmock.NewStringResponder(404, ""),
+	)
+	assert.Equal(&SendError{Status: 404, Attempts: 1}, tracker.TrackPageView(PageViewEvent{PageUrl: common.NewString("acme.com")}))
+}